@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// annotationRE matches the annotation written by -annotate at the start of a
+// file, capturing the number of stubs.
+var annotationRE = regexp.MustCompile(`^// wasmstub: (\d+) sites? stubbed \(wasmstub [^)\n]*\)\n\n?`)
+
+// countStubs returns the number of panics inserted by DefaultInsert,
+// ModeFuncBody or ModeEntry in src.
+func countStubs(src []byte) int {
+	return bytes.Count(src, []byte(stubPrefix))
+}
+
+// readAnnotation returns the number of stubs recorded by the annotation of
+// src and the length of the annotation, or ok == false if src has none.
+func readAnnotation(src []byte) (n, length int, ok bool) {
+	m := annotationRE.FindSubmatch(src)
+	if m == nil {
+		return 0, 0, false
+	}
+	n, err := strconv.Atoi(string(m[1]))
+	if err != nil {
+		return 0, 0, false
+	}
+	return n, len(m[0]), true
+}
+
+// staleAnnotation reports whether src has an annotation that does not match
+// the number of stubs it contains, as happens when a file is partly
+// regenerated upstream.
+func staleAnnotation(src []byte) bool {
+	n, _, ok := readAnnotation(src)
+	return ok && n != countStubs(src)
+}
+
+// annotate returns src with an annotation recording the number of stubs it
+// contains. An existing annotation with the right count is kept as is, even
+// if written by another version of the tool.
+func annotate(src []byte) []byte {
+	n := countStubs(src)
+	old, length, ok := readAnnotation(src)
+	if ok && old == n {
+		return src
+	}
+	out := fmt.Appendf(nil, "// wasmstub: %d sites stubbed (wasmstub %s)\n\n", n, version)
+	return append(out, src[length:]...)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAnnotate(t *testing.T) {
+	const src = "// Copyright 2009 The Go Authors.\n\npackage p\n\nfunc f() {\n\tSyscall(1, 2, 3)\n\tRawSyscall(1, 2, 3)\n}\n"
+	opts := &Options{Annotate: true}
+	res, err := Stub("p.go", []byte(src), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const header = "// wasmstub: 2 sites stubbed (wasmstub " + version + ")\n\n// Copyright"
+	if !strings.HasPrefix(string(res.Output), header) {
+		t.Fatalf("missing annotation:\n%s", res.Output)
+	}
+
+	again, err := Stub("p.go", res.Output, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Changed || again.StaleAnnotation {
+		t.Errorf("second run: Changed = %v, StaleAnnotation = %v", again.Changed, again.StaleAnnotation)
+	}
+
+	// Regenerating f upstream drops its stubs but not the annotation.
+	regenerated := strings.Replace(string(res.Output), "\tpanic(\"syscall not supported in wasm: RawSyscall(1, 2, 3)\")\n", "", 1)
+	path := writeTemp(t, "p.go", regenerated)
+	obs := &cliObserver{}
+	r := &runner{opts: &Options{Observer: obs}, check: true}
+	if err := r.processDirectory(filepath.Dir(path)); err != nil {
+		t.Fatal(err)
+	}
+	if !obs.needsChange() {
+		t.Errorf("check did not detect the stale annotation")
+	}
+
+	res, err = Stub("p.go", []byte(regenerated), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.StaleAnnotation {
+		t.Errorf("StaleAnnotation = false")
+	}
+	if !strings.HasPrefix(string(res.Output), "// wasmstub: 2 sites stubbed") {
+		t.Errorf("annotation not updated:\n%s", res.Output)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// tempPrefix starts the names of the temporary files of writeFileAtomic, so
+// that any left behind by a killed run are easy to recognize, and ignored
+// by the go command like all files starting with a dot.
+const tempPrefix = ".wasmstub-"
+
+// rename is os.Rename, replaced in tests to observe writeFileAtomic.
+var rename = os.Rename
+
+// writeFileAtomic replaces filename with data. It writes a temporary file in
+// the same directory and renames it over filename, which is atomic on the
+// same file system, so an interrupted run leaves either the old or the new
+// content but never a truncated file. The permissions of an existing file
+// are preserved.
+func writeFileAtomic(filename string, data []byte) error {
+	f, err := createAtomic(filename)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.abort()
+		return err
+	}
+	return f.commit()
+}
+
+// atomicFile is a temporary file that replaces filename once committed, for
+// content written progressively, as by writeFileAtomic.
+type atomicFile struct {
+	*os.File
+	filename string
+	perm     os.FileMode
+	done     bool // committed or aborted
+}
+
+func createAtomic(filename string) (*atomicFile, error) {
+	perm := os.FileMode(0644)
+	if fi, err := os.Stat(filename); err == nil {
+		perm = fi.Mode().Perm()
+	}
+	f, err := os.CreateTemp(filepath.Dir(filename), tempPrefix+filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &atomicFile{File: f, filename: filename, perm: perm}, nil
+}
+
+// commit renames f over its target, or removes it if that fails.
+func (f *atomicFile) commit() (err error) {
+	defer func() {
+		if err != nil {
+			f.abort()
+		}
+	}()
+	if err := f.Chmod(f.perm); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := rename(f.Name(), f.filename); err != nil {
+		return err
+	}
+	f.done = true
+	return nil
+}
+
+// abort removes f, leaving its target untouched. It does nothing once f is
+// committed, so it can be deferred.
+func (f *atomicFile) abort() {
+	if f.done {
+		return
+	}
+	f.done = true
+	f.Close()
+	os.Remove(f.Name())
+}
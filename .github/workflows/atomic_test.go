@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	path := writeTemp(t, "p.go", "old")
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { rename = os.Rename }()
+	var renamed bool
+	rename = func(oldpath, newpath string) error {
+		renamed = true
+		if filepath.Dir(oldpath) != filepath.Dir(newpath) {
+			t.Errorf("temporary file %s is not in the directory of %s", oldpath, newpath)
+		}
+		if got := readFile(t, oldpath); got != "new" {
+			t.Errorf("temporary file holds %q before rename, want %q", got, "new")
+		}
+		if got := readFile(t, newpath); got != "old" {
+			t.Errorf("target holds %q before rename, want %q", got, "old")
+		}
+		return os.Rename(oldpath, newpath)
+	}
+
+	if err := writeFileAtomic(path, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	if !renamed {
+		t.Error("file was not written through rename")
+	}
+	if got := readFile(t, path); got != "new" {
+		t.Errorf("got %q, want %q", got, "new")
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want %v", fi.Mode().Perm(), os.FileMode(0600))
+	}
+}
+
+func TestWriteFileAtomicRenameError(t *testing.T) {
+	path := writeTemp(t, "p.go", "old")
+
+	defer func() { rename = os.Rename }()
+	rename = func(oldpath, newpath string) error {
+		return errors.New("interrupted")
+	}
+
+	if err := writeFileAtomic(path, []byte("new")); err == nil {
+		t.Fatal("writeFileAtomic succeeded despite rename error")
+	}
+	if got := readFile(t, path); got != "old" {
+		t.Errorf("got %q, want the original content", got)
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("temporary file left behind: %v", entries)
+	}
+}
+
+// TestNoTempFilesAfterFormatError checks that no temporary file remains when
+// a file whose stubbed output cannot be formatted fails to be written.
+func TestNoTempFilesAfterFormatError(t *testing.T) {
+	path := writeTemp(t, "p.go", "package p\n\nfunc f() {\n\tSyscall(1, 2, 3)\n}\n")
+
+	defer func() { rename = os.Rename }()
+	rename = func(oldpath, newpath string) error {
+		if !strings.HasPrefix(filepath.Base(oldpath), tempPrefix+"p.go.") {
+			t.Errorf("temporary file %s does not start with %s", oldpath, tempPrefix)
+		}
+		return errors.New("interrupted")
+	}
+
+	r := &runner{opts: &Options{InsertFunc: unbalancedInsert}}
+	res, err := r.processFile(path)
+	if err == nil {
+		t.Fatal("processFile succeeded despite rename error")
+	}
+	if res == nil || res.FormatErr == nil {
+		t.Fatalf("got result %+v, want a format error", res)
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("temporary file left behind: %v", entries)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// readBaseline reads the records of a JSON report written by -report.
+func readBaseline(file string) ([]reportRecord, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var records []reportRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return records, nil
+}
+
+// baselineKey identifies a site across runs. Lines are left out, as they
+// shift whenever code is added above a site.
+func baselineKey(r reportRecord) string {
+	return r.File + "\x00" + r.Enclosing + "\x00" + r.Call
+}
+
+// baselineDiff is the difference between the sites of a run and a baseline.
+type baselineDiff struct {
+	added     []reportRecord
+	removed   []reportRecord
+	unchanged int
+}
+
+// compareBaseline compares current to baseline, matching sites by file,
+// enclosing function and call, as many times as they occur in each.
+func compareBaseline(baseline, current []reportRecord) baselineDiff {
+	var d baselineDiff
+	remaining := make(map[string][]reportRecord)
+	for _, r := range baseline {
+		k := baselineKey(r)
+		remaining[k] = append(remaining[k], r)
+	}
+	for _, r := range current {
+		k := baselineKey(r)
+		if len(remaining[k]) > 0 {
+			remaining[k] = remaining[k][1:]
+			d.unchanged++
+			continue
+		}
+		d.added = append(d.added, r)
+	}
+	for _, r := range baseline {
+		k := baselineKey(r)
+		if len(remaining[k]) > 0 {
+			d.removed = append(d.removed, remaining[k][0])
+			remaining[k] = remaining[k][1:]
+		}
+	}
+	return d
+}
+
+// print writes the counts of d and its added sites to w.
+func (d baselineDiff) print(w io.Writer) {
+	fmt.Fprintf(w, "Baseline: %d added, %d removed, %d unchanged\n", len(d.added), len(d.removed), d.unchanged)
+	for _, r := range d.added {
+		fmt.Fprintf(w, "+ %s:%d:%d: %s", r.File, r.Line, r.Column, r.Call)
+		if r.Enclosing != "" {
+			fmt.Fprintf(w, " in %s", r.Enclosing)
+		}
+		fmt.Fprintln(w)
+	}
+}
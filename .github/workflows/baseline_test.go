@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompareBaseline(t *testing.T) {
+	rec := func(line int, enclosing, call string) reportRecord {
+		return reportRecord{File: "a.go", Line: line, Enclosing: enclosing, Call: call, Status: "stubbed"}
+	}
+	baseline := []reportRecord{
+		rec(10, "Read", "Syscall(SYS_READ, 0, 0, 0)"),
+		rec(20, "Close", "Syscall(SYS_CLOSE, 0, 0, 0)"),
+		rec(30, "Sync", "Syscall(SYS_SYNC, 0, 0, 0)"),
+		rec(31, "Sync", "Syscall(SYS_SYNC, 0, 0, 0)"),
+	}
+	current := []reportRecord{
+		rec(12, "Read", "Syscall(SYS_READ, 0, 0, 0)"), // moved
+		rec(32, "Sync", "Syscall(SYS_SYNC, 0, 0, 0)"),
+		rec(40, "Pipe", "RawSyscall(SYS_PIPE2, 0, 0, 0)"),
+	}
+	d := compareBaseline(baseline, current)
+	if len(d.added) != 1 || len(d.removed) != 2 || d.unchanged != 2 {
+		t.Fatalf("got %d added, %d removed, %d unchanged; want 1, 2, 2", len(d.added), len(d.removed), d.unchanged)
+	}
+
+	var b bytes.Buffer
+	d.print(&b)
+	const want = "Baseline: 1 added, 2 removed, 2 unchanged\n+ a.go:40:0: RawSyscall(SYS_PIPE2, 0, 0, 0) in Pipe\n"
+	if got := b.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
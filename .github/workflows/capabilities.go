@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// A Capability records how the stubber handles a syscall call in one
+// statement context.
+type Capability struct {
+	Context string `json:"context"` // kind of statement containing the call
+	Example string `json:"example"`
+	Status  string `json:"status"`           // "stubbed", "flagged" or "not matched"
+	Reason  string `json:"reason,omitempty"` // why a flagged call is not stubbed
+}
+
+// capabilityExamples holds a statement for every context, calling Syscall.
+var capabilityExamples = []struct{ context, stmt string }{
+	{"ExprStmt", "Syscall(1, 2, 3)"},
+	{"AssignStmt", "r0, _, e1 := Syscall(1, 2, 3)"},
+	{"AssignStmt (operator)", "n += f(Syscall(1, 2, 3))"},
+	{"IncDecStmt", "hits[f(Syscall(1, 2, 3))]++"},
+	{"&& or || operand", "ok := f() && g(Syscall(1, 2, 3))"},
+	{"DeclStmt", "var r0, _, e1 = Syscall(1, 2, 3)"},
+	{"ReturnStmt", "return Syscall(1, 2, 3)"},
+	{"DeferStmt", "defer Syscall(1, 2, 3)"},
+	{"GoStmt", "go Syscall(1, 2, 3)"},
+	{"SendStmt", "ch <- f(Syscall(1, 2, 3))"},
+	{"IfStmt condition", "if f(Syscall(1, 2, 3)) {\n}"},
+	{"SwitchStmt tag", "switch f(Syscall(1, 2, 3)) {\n}"},
+	{"ForStmt condition", "for f(Syscall(1, 2, 3)) {\n}"},
+	{"RangeStmt", "for range f(Syscall(1, 2, 3)) {\n}"},
+	{"function literal", "f := func() {\n\tSyscall(1, 2, 3)\n}"},
+	{"panic argument", "panic(f(Syscall(1, 2, 3)))"},
+	{"os.Exit argument", "os.Exit(f(Syscall(1, 2, 3)))"},
+}
+
+// Capabilities reports how Stub handles a call in each statement context
+// with opts, by stubbing an example of each.
+func Capabilities(opts *Options) ([]Capability, error) {
+	var caps []Capability
+	for _, ex := range capabilityExamples {
+		src := "package p\n\nfunc f() {\n\t" + strings.ReplaceAll(ex.stmt, "\n", "\n\t") + "\n}\n"
+		res, err := Stub("capabilities.go", []byte(src), opts)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ex.context, err)
+		}
+		c := Capability{Context: ex.context, Example: ex.stmt, Status: "not matched"}
+		switch {
+		case len(res.Sites) > 0:
+			c.Status = "stubbed"
+		case len(res.Skipped) > 0:
+			c.Status = "flagged"
+			c.Reason = res.Skipped[0].Reason
+		}
+		caps = append(caps, c)
+	}
+	return caps, nil
+}
+
+// printCapabilities writes caps to w as a table, or as JSON if asJSON is set.
+func printCapabilities(w io.Writer, caps []Capability, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		return enc.Encode(caps)
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, c := range caps {
+		example, _, _ := strings.Cut(c.Example, "\n")
+		fmt.Fprintf(tw, "%s\t%s\t%s", c.Status, c.Context, example)
+		if c.Reason != "" {
+			fmt.Fprintf(tw, " (%s)", c.Reason)
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestCapabilities(t *testing.T) {
+	caps, err := Capabilities(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := make(map[string]string)
+	for _, c := range caps {
+		status[c.Context] = c.Status
+	}
+	for context, want := range map[string]string{
+		"ExprStmt":         "stubbed",
+		"AssignStmt":       "stubbed",
+		"IncDecStmt":       "stubbed",
+		"function literal": "stubbed",
+		"panic argument":   "flagged",
+		"&& or || operand": "flagged",
+		"ReturnStmt":       "not matched",
+	} {
+		if got := status[context]; got != want {
+			t.Errorf("%s: got status %q, want %q", context, got, want)
+		}
+	}
+
+	var b bytes.Buffer
+	if err := printCapabilities(&b, caps, true); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []Capability
+	if err := json.Unmarshal(b.Bytes(), &decoded); err != nil || len(decoded) != len(caps) {
+		t.Errorf("got %d capabilities from JSON (%v), want %d", len(decoded), err, len(caps))
+	}
+}
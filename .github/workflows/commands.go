@@ -0,0 +1,687 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// A command is a subcommand of wasmstub.
+type command struct {
+	name  string
+	args  string // synopsis of the arguments following the flags, if any
+	short string // one-line description printed by the top-level usage
+	multi bool   // whether the command accepts several arguments
+
+	// setup defines the flags of the command on fs and returns the
+	// function running it on its arguments once they are parsed.
+	setup func(fs *flag.FlagSet) func(args []string) int
+}
+
+var commands = []*command{
+	{name: "stub", args: "<directory>...", multi: true, short: "insert a panic before every syscall site", setup: setupStub},
+	{name: "undo", args: "<directory>...", multi: true, short: "remove the statements inserted by stub, restoring the original source", setup: setupUndo},
+	{name: "check", args: "<directory>...", multi: true, short: "list the files that still need stubbing", setup: setupCheck},
+	{name: "audit", args: "<directory>...", multi: true, short: "count the syscall sites of each file", setup: setupAudit},
+	{name: "report", args: "<directory>...", multi: true, short: "write a report of the sites stub would change, without modifying files", setup: setupReport},
+	{name: "dump-ast", args: "<file>", short: "print the AST of a file, marking the calls the matcher considers", setup: setupDumpAST},
+	{name: "capabilities", short: "list the statement contexts in which syscall calls are stubbed", setup: setupCapabilities},
+}
+
+func lookupCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: go run . <command> [flags] <directory>...\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-14s%s\n", cmd.name, cmd.short)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun \"go run . help <command>\" for the flags of a command.\n")
+	fmt.Fprintf(os.Stderr, "\nExit status: 0 success, 1 usage error, 2 files need stubbing (check), 3 processing failure.\n")
+}
+
+// run runs the command line args, without the program name, and returns the
+// exit status.
+func run(args []string) int {
+	if len(args) == 0 {
+		usage()
+		return exitUsage
+	}
+	if args[0] == "help" {
+		if len(args) > 1 {
+			if cmd := lookupCommand(args[1]); cmd != nil {
+				fs := cmd.flagSet()
+				cmd.setup(fs)
+				fs.Usage()
+				return exitOK
+			}
+		}
+		usage()
+		return exitOK
+	}
+	cmd := lookupCommand(args[0])
+	if cmd == nil {
+		// Before subcommands, the flags and directory were given
+		// directly, as for stub.
+		logger.warnf("running without a command is deprecated; use \"go run . stub [flags] <directory>\"")
+		cmd = lookupCommand("stub")
+	} else {
+		args = args[1:]
+	}
+	return cmd.run(args)
+}
+
+func (cmd *command) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s\n\n%s.\n\nFlags:\n", strings.TrimSpace("go run . "+cmd.name+" [flags] "+cmd.args), cmd.short)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func (cmd *command) run(args []string) int {
+	fs := cmd.flagSet()
+	run := cmd.setup(fs)
+	defaults, err := envFlags(fs)
+	if err != nil {
+		return usageError(err)
+	}
+	if err := fs.Parse(defaults); err != nil {
+		return exitUsage
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	var ok bool
+	switch {
+	case cmd.args == "":
+		ok = fs.NArg() == 0
+	case cmd.multi:
+		ok = fs.NArg() > 0 || readsStdin(fs)
+	default:
+		ok = fs.NArg() == 1
+	}
+	if !ok {
+		fs.Usage()
+		return exitUsage
+	}
+	return run(fs.Args())
+}
+
+// readsStdin reports whether the -stdin flag of fs, if any, is set, in which
+// case no directory is needed.
+func readsStdin(fs *flag.FlagSet) bool {
+	f := fs.Lookup("stdin")
+	return f != nil && f.Value.String() == "true"
+}
+
+// usageError prints err and returns the exit status of usage errors.
+func usageError(err error) int {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	return exitUsage
+}
+
+// logFlags are the flags controlling log output, shared by all commands.
+type logFlags struct {
+	level   *string
+	noColor *bool
+}
+
+func addLogFlags(fs *flag.FlagSet) *logFlags {
+	return &logFlags{
+		level:   fs.String("log-level", "info", "minimum log `level`: debug, info, warn or error"),
+		noColor: fs.Bool("no-color", false, "disable colorized log output"),
+	}
+}
+
+// apply configures logger.
+func (f *logFlags) apply() error {
+	level, err := parseLogLevel(*f.level)
+	if err != nil {
+		return err
+	}
+	logger.level = level
+	logger.color = !*f.noColor && isTerminal(os.Stderr)
+	return nil
+}
+
+// optionFlags are the flags controlling which sites are matched and how
+// they are stubbed, shared by the commands running Stub.
+type optionFlags struct {
+	log          *logFlags
+	exportedOnly *bool
+	unexported   *bool
+	skipTests    *bool
+	bestEffort   *bool
+	keepGroups   *bool
+	keepBOM      *bool
+	windows      *bool
+	goos         *string
+	goarch       *string
+	funcs        *string
+	onlyFuncs    *string
+	mode         *string
+	shimPkg      *string
+	shimInts     *string
+	pruneImports *bool
+	rules        *string
+	config       *string
+	trace        *bool
+	unmatched    *bool
+	dedupPanics  *bool
+	conditional  *bool
+	rename       *bool
+	initPolicy   *string
+	stubTag      *string
+	formatDiff   *bool
+	annotate     *bool
+	strict       *bool
+}
+
+func addOptionFlags(fs *flag.FlagSet) *optionFlags {
+	return &optionFlags{
+		log:          addLogFlags(fs),
+		exportedOnly: fs.Bool("exported-only", false, "only stub syscalls inside exported functions"),
+		unexported:   fs.Bool("only-unexported", false, "only stub syscalls outside exported functions, the inverse of -exported-only"),
+		bestEffort:   fs.Bool("best-effort", false, "stub the well-formed parts of files with syntax errors, leaving them unformatted, instead of failing"),
+		skipTests:    fs.Bool("skip-tests", false, "leave syscalls in _test.go files, such as in tests and examples, alone"),
+		keepGroups:   fs.Bool("keep-gofmt-groups", false, "keep the file as is apart from inserted lines instead of reformatting it with gofmt"),
+		keepBOM:      fs.Bool("keep-bom", false, "keep a leading UTF-8 byte order mark in stubbed files"),
+		windows:      fs.Bool("windows", false, "also match proc.Call and syscall.SyscallN calls used by x/sys/windows"),
+		goos:         fs.String("goos", "", "only stub the files built for `os`, such as js or wasip1, from their names and build constraints; with -goarch unset, it defaults to wasm"),
+		goarch:       fs.String("goarch", "", "only stub the files built for `arch`; with -goos unset, it defaults to js"),
+		funcs:        fs.String("funcs", "", "comma-separated `names` of functions to match in addition to the raw syscall functions"),
+		onlyFuncs:    fs.String("only-funcs", "", "comma-separated `names` of the only functions to match, replacing the default set and -funcs"),
+		mode:         fs.String("mode", "insert", "how to stub sites: insert a statement before each (insert), replace the enclosing function body (funcbody), insert a statement trying -shim-pkg first (shim), replace each call with one returning ENOSYS (nop), panic on entry to the enclosing function (entry) or make functions returning an error fail with ENOSYS, setting the errno of classic wrappers (errno)"),
+		shimPkg:      fs.String("shim-pkg", "", "import `path` of the package implementing Available and Do for -mode=shim"),
+		shimInts:     fs.String("shim-int-types", "", "comma-separated `names` of the types, besides Errno, Handle, Signal and Time_t, that -mode=shim converts results to like integers"),
+		pruneImports: fs.Bool("prune-imports", false, "remove imports left unused by stubbing"),
+		rules:        fs.String("rules", "", "read per-function stub actions from `file`"),
+		config:       fs.String("config", "", "read functions to skip, paths to exclude and per-function stub actions from the JSON `file`, such as syscall-stubs.json"),
+		trace:        fs.Bool("trace", false, "print the chain of AST nodes leading to each matched call"),
+		initPolicy:   fs.String("init-policy", "panic", "how to handle syscalls in package init functions, which run when the package is loaded: stub them (panic), leave them alone (skip) or return from init before them (lazy; not with -mode=funcbody or entry)"),
+		stubTag:      fs.String("stub-tag", "", "confine stubs to js/wasm builds: guard each inserted statement with a runtime.GOOS check (goos; -mode=insert or shim only) or move the stubbed copy of each file to a _wasmstub_js.go file and exclude js from the original (files)"),
+		rename:       fs.Bool("rename-stubbed-funcs", false, "with -mode=funcbody or entry, rename stubbed functions X to X_wasmstub behind forwarders, so that go tool nm on a linked binary lists the reachable ones"),
+		conditional:  fs.Bool("stub-conditional", false, "also stub syscalls in the right operand of && and ||, which then panic even when the call would not run"),
+		dedupPanics:  fs.Bool("dedup-panics", false, "collapse consecutive panics inserted by this or older versions of the tool into one"),
+		unmatched:    fs.Bool("print-unmatched", false, "print the calls of functions named like syscall functions that are not matched, to find names for -funcs"),
+		formatDiff:   fs.Bool("debug-format", false, "print the changes gofmt made beyond the inserted statements, for debugging"),
+		strict:       fs.Bool("strict-positions", false, "insert statements by rewriting the AST instead of splicing lines"),
+		annotate:     fs.Bool("annotate", false, "record the number of stubs in a comment at the start of stubbed files"),
+	}
+}
+
+// options configures logger and returns the Options set by the flags.
+func (f *optionFlags) options() (*Options, error) {
+	if err := f.log.apply(); err != nil {
+		return nil, err
+	}
+	mode, err := parseMode(*f.mode)
+	if err != nil {
+		return nil, err
+	}
+	if *f.exportedOnly && *f.unexported {
+		return nil, errors.New("-exported-only and -only-unexported are mutually exclusive")
+	}
+	initPolicy, err := parseInitPolicy(*f.initPolicy)
+	if err != nil {
+		return nil, err
+	}
+	if initPolicy == InitLazy && (mode == ModeFuncBody || mode == ModeEntry) {
+		return nil, errors.New("-init-policy=lazy cannot be combined with -mode=funcbody or -mode=entry")
+	}
+	stubTag, err := parseStubTag(*f.stubTag)
+	if err != nil {
+		return nil, err
+	}
+	if stubTag == StubTagGOOS && mode != ModeInsert && mode != ModeShim {
+		return nil, errors.New("-stub-tag=goos requires -mode=insert or -mode=shim")
+	}
+	if *f.rename && mode != ModeFuncBody && mode != ModeEntry {
+		return nil, errors.New("-rename-stubbed-funcs requires -mode=funcbody or -mode=entry")
+	}
+	if (mode == ModeShim) != (*f.shimPkg != "") {
+		return nil, errors.New("-shim-pkg must be set with -mode=shim, and only then")
+	}
+	if *f.shimInts != "" && mode != ModeShim {
+		return nil, errors.New("-shim-int-types requires -mode=shim")
+	}
+	opts := &Options{
+		Mode:               mode,
+		ShimPkg:            *f.shimPkg,
+		ShimIntTypes:       splitList(*f.shimInts),
+		PruneImports:       *f.pruneImports,
+		ExportedOnly:       *f.exportedOnly,
+		OnlyUnexported:     *f.unexported,
+		SkipTests:          *f.skipTests,
+		BestEffort:         *f.bestEffort,
+		KeepGofmtGroups:    *f.keepGroups,
+		KeepBOM:            *f.keepBOM,
+		Windows:            *f.windows,
+		GOOS:               *f.goos,
+		GOARCH:             *f.goarch,
+		Funcs:              splitList(*f.funcs),
+		OnlyFuncs:          splitList(*f.onlyFuncs),
+		Trace:              *f.trace,
+		Unmatched:          *f.unmatched,
+		DedupPanics:        *f.dedupPanics,
+		StubConditional:    *f.conditional,
+		RenameStubbedFuncs: *f.rename,
+		InitPolicy:         initPolicy,
+		StubTag:            stubTag,
+		KeepUnformatted:    *f.formatDiff,
+		Annotate:           *f.annotate,
+		StrictPositions:    *f.strict,
+	}
+	if *f.rules != "" {
+		if opts.Rules, err = loadRules(*f.rules); err != nil {
+			return nil, err
+		}
+	}
+	if *f.config != "" {
+		c, err := loadConfig(*f.config)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.apply(opts); err != nil {
+			return nil, err
+		}
+	}
+	return opts, nil
+}
+
+// observer returns the cliObserver matching the flags.
+func (f *optionFlags) observer() *cliObserver {
+	o := &cliObserver{trace: *f.trace, formatDiff: *f.formatDiff, pruneImports: *f.pruneImports}
+	if *f.unmatched {
+		o.unmatched = make(map[string]int)
+	}
+	return o
+}
+
+func setupStub(fs *flag.FlagSet) func(dirs []string) int {
+	optFlags := addOptionFlags(fs)
+	countOnly := fs.Bool("count-only", false, "only count syscall sites per file; do not modify files (see audit)")
+	check := fs.Bool("check", false, "list files that still need stubbing without writing them; exit with status 2 if there are any (see check)")
+	diff := fs.Bool("diff", false, "print a unified diff of the changes instead of writing them")
+	dryRun := fs.Bool("dry-run", false, "process files without writing them; unlike -check, exit with status 0 even if some need changes")
+	exitZero := fs.Bool("exit-zero", false, "with -dry-run or -diff, exit with status 0 whatever the changes pending, for previews in interactive use; failures still exit with status 3")
+	strip := fs.Bool("strip", false, "remove the statements inserted by earlier runs instead, restoring the original source, as the undo command does; only combines with -diff")
+	stdin := fs.Bool("stdin", false, "stub the source read from standard input instead of directories and write it to standard output")
+	stdinName := fs.String("stdin-name", "<stdin>", "file `name` of the source read with -stdin, used in messages and reports")
+	baseline := fs.String("baseline", "", "with -dry-run, -check or -diff, compare the sites to those of a JSON report `file` and print the added ones")
+	diffContext := fs.Int("diff-context", 3, "number of context `lines` around each -diff hunk, like diff -U")
+	stubConsts := fs.Bool("stub-constants", false, "write placeholder definitions of SYS_* numbers that stubbed code uses but js/wasm does not define")
+	watch := fs.Bool("watch", false, "after processing, keep watching the directory and process Go files as they change")
+	watchPoll := fs.Duration("watch-interval", 500*time.Millisecond, "how often -watch polls for changes")
+	reportFile := fs.String("report", "", "write a report of all matched sites to `file`")
+	reportFmt := fs.String("report-format", "", "format of the -report file: json, jsonl, csv or text (default from the file extension, else text)")
+	timing := fs.Bool("timing", false, fmt.Sprintf("print the time spent parsing, inspecting, splicing and formatting, and the %d slowest files", slowestFiles))
+	maxSize := fs.Int64("max-file-size", 0, "skip files larger than `bytes` with a warning; 0 means no limit")
+	summaryFile := fs.String("summary-json", "", "write the totals, mode, duration and exit status of the run to `file` as JSON")
+	postHook := fs.String("post-hook", "", "run the shell `command` on each written file, given its path as last argument, and fail if it does")
+	manifestFile := fs.String("manifest", "", "write a manifest of the tool version, options and hashes of the processed files to `file`")
+
+	var (
+		obs  *cliObserver
+		mode Mode
+	)
+	run := func(dirs []string) int {
+		opts, err := optFlags.options()
+		if err != nil {
+			return usageError(err)
+		}
+		if *diffContext < 0 {
+			return usageError(errors.New("-diff-context must not be negative"))
+		}
+		if *maxSize < 0 {
+			return usageError(errors.New("-max-file-size must not be negative"))
+		}
+		if *exitZero && (*check || !*dryRun && !*diff) {
+			return usageError(errors.New("-exit-zero requires -dry-run or -diff, and cannot be combined with -check, whose exit status is its result"))
+		}
+		if *manifestFile != "" && (*check || *diff || *dryRun) {
+			return usageError(errors.New("-manifest cannot be combined with -check, -diff or -dry-run"))
+		}
+		if *baseline != "" && !*dryRun && !*check && !*diff {
+			return usageError(errors.New("-baseline requires -dry-run, -check or -diff"))
+		}
+		format, err := reportFormat(*reportFile, *reportFmt)
+		if err != nil {
+			return usageError(err)
+		}
+		var base []reportRecord
+		if *baseline != "" {
+			if base, err = readBaseline(*baseline); err != nil {
+				return usageError(err)
+			}
+		}
+		if *stdin && (len(dirs) > 0 || *countOnly || *manifestFile != "" || *watch || *stubConsts || *postHook != "") {
+			return usageError(errors.New("-stdin takes no directory and cannot be combined with -count-only, -manifest, -watch, -stub-constants or -post-hook"))
+		}
+		if *strip {
+			if *check || *dryRun || *stdin || *countOnly || *watch || *stubConsts || *reportFile != "" || *manifestFile != "" || *baseline != "" {
+				return usageError(errors.New("-strip only combines with -diff"))
+			}
+			return undoDirs(dirs, *diff)
+		}
+		if *stdin && opts.StubTag == StubTagFiles {
+			return usageError(errors.New("-stdin cannot be combined with -stub-tag=files, which writes a second file"))
+		}
+		if *countOnly {
+			return audit(dirs, opts)
+		}
+		if len(dirs) > 1 && (*manifestFile != "" || *watch) {
+			return usageError(errors.New("-manifest and -watch require a single directory"))
+		}
+
+		mode = opts.mode()
+		obs = optFlags.observer()
+		streaming := *reportFile != "" && format == "jsonl"
+		obs.report = *reportFile != "" && !streaming || *baseline != ""
+		obs.timing = *timing
+		opts.Observer = obs
+		var report *atomicFile
+		if streaming {
+			if report, err = createAtomic(*reportFile); err != nil {
+				logger.errorf("writing report: %v", err)
+				return exitFailure
+			}
+			defer report.abort()
+			obs.stream = newJSONLStream(report)
+		}
+		r := &runner{opts: opts, check: *check, diff: *diff, diffContext: *diffContext, dryRun: *dryRun, maxSize: *maxSize, postHook: *postHook}
+		if *stdin {
+			_, err = r.processStdin(os.Stdin, os.Stdout, *stdinName)
+		} else {
+			err = processRoots(r, obs, dirs)
+		}
+		if err != nil {
+			logger.errorf("%v", err)
+			return exitFailure
+		}
+		if *baseline != "" {
+			compareBaseline(base, obs.records).print(os.Stdout)
+		}
+		if streaming {
+			err = obs.stream.flush()
+			if err == nil {
+				err = report.commit()
+			}
+			// Files processed by -watch are not reported, as with the
+			// other formats.
+			obs.stream = nil
+		} else if *reportFile != "" {
+			err = writeReport(*reportFile, format, obs.records)
+		}
+		if err != nil {
+			logger.errorf("writing report: %v", err)
+			return exitFailure
+		}
+		if *manifestFile != "" {
+			if err := writeManifest(*manifestFile, dirs[0], obs.processed, setFlags(fs)); err != nil {
+				logger.errorf("writing manifest: %v", err)
+				return exitFailure
+			}
+		}
+		if *stubConsts && !r.check && !r.diff && !r.dryRun {
+			for _, dir := range dirs {
+				written, err := stubConstants(dir)
+				if err != nil {
+					logger.errorf("%v", err)
+					return exitFailure
+				}
+				for _, file := range written {
+					logger.infof("Wrote placeholder syscall numbers: %s", file)
+				}
+			}
+		}
+		obs.summarize(opts)
+		if *watch {
+			logger.infof("Watching %s for changes", dirs[0])
+			if err := r.watch(dirs[0], *watchPoll, watchDebounce, nil); err != nil {
+				logger.errorf("%v", err)
+				return exitFailure
+			}
+		}
+		if r.check && obs.needsChange() {
+			return exitNeedsChange
+		}
+		return exitOK
+	}
+
+	return func(dirs []string) int {
+		start := time.Now()
+		status := run(dirs)
+		if *exitZero && status == exitNeedsChange {
+			status = exitOK
+		}
+		if *summaryFile != "" {
+			if err := writeSummary(*summaryFile, obs, mode, time.Since(start), status); err != nil {
+				logger.errorf("writing summary: %v", err)
+				return exitFailure
+			}
+		}
+		return status
+	}
+}
+
+// processRoots processes each of dirs with r, tagging the records of obs
+// with the directory they come from.
+func processRoots(r *runner, obs *cliObserver, dirs []string) error {
+	for _, dir := range dirs {
+		obs.root = dir
+		if err := r.processDirectory(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setupCheck(fs *flag.FlagSet) func(dirs []string) int {
+	optFlags := addOptionFlags(fs)
+	diff := fs.Bool("diff", false, "also print a unified diff of the changes each file needs")
+	return func(dirs []string) int {
+		opts, err := optFlags.options()
+		if err != nil {
+			return usageError(err)
+		}
+		obs := optFlags.observer()
+		opts.Observer = obs
+		r := &runner{opts: opts, check: true, diff: *diff, diffContext: 3}
+		if err := processRoots(r, obs, dirs); err != nil {
+			logger.errorf("%v", err)
+			return exitFailure
+		}
+		summarizeUnmatched(obs.unmatched)
+		if obs.needsChange() {
+			return exitNeedsChange
+		}
+		return exitOK
+	}
+}
+
+func setupAudit(fs *flag.FlagSet) func(dirs []string) int {
+	optFlags := addOptionFlags(fs)
+	return func(dirs []string) int {
+		opts, err := optFlags.options()
+		if err != nil {
+			return usageError(err)
+		}
+		return audit(dirs, opts)
+	}
+}
+
+// audit prints the number of sites of each file under dirs and the total.
+func audit(dirs []string, opts *Options) int {
+	total := 0
+	unmatched := make(map[string]int)
+	for _, dir := range dirs {
+		n, err := countDirectory(dir, opts, unmatched)
+		if err != nil {
+			logger.errorf("%v", err)
+			return exitFailure
+		}
+		total += n
+	}
+	fmt.Printf("Total: %d\n", total)
+	summarizeUnmatched(unmatched)
+	return exitOK
+}
+
+func setupReport(fs *flag.FlagSet) func(dirs []string) int {
+	optFlags := addOptionFlags(fs)
+	out := fs.String("o", "", "write the report to `file` instead of standard output")
+	reportFmt := fs.String("format", "", "report format: json, jsonl, csv or text (default from the -o file extension, else text)")
+	byPackage := fs.Bool("group-by-package", false, "report the number of sites and the exported functions stubbed per package instead of each site")
+	return func(dirs []string) int {
+		opts, err := optFlags.options()
+		if err != nil {
+			return usageError(err)
+		}
+		format, err := reportFormat(*out, *reportFmt)
+		if err != nil {
+			return usageError(err)
+		}
+		obs := optFlags.observer()
+		opts.Observer = obs
+		r := &runner{opts: opts, dryRun: true}
+		if format == "jsonl" && !*byPackage {
+			return streamReport(r, obs, dirs, *out)
+		}
+		obs.report = true
+		if err := processRoots(r, obs, dirs); err != nil {
+			logger.errorf("%v", err)
+			return exitFailure
+		}
+		var b bytes.Buffer
+		if *byPackage {
+			err = packageFormats[format](&b, groupByPackage(obs.records))
+		} else {
+			err = reportFormats[format](&b, obs.records)
+		}
+		if err == nil && *out == "" {
+			_, err = os.Stdout.Write(b.Bytes())
+		} else if err == nil {
+			err = writeFileAtomic(*out, b.Bytes())
+		}
+		if err != nil {
+			logger.errorf("writing report: %v", err)
+			return exitFailure
+		}
+		return exitOK
+	}
+}
+
+// streamReport writes the JSON Lines report of the sites under dirs to out,
+// or to standard output if out is empty, as files are processed.
+func streamReport(r *runner, obs *cliObserver, dirs []string, out string) int {
+	var w io.Writer = os.Stdout
+	var report *atomicFile
+	if out != "" {
+		var err error
+		if report, err = createAtomic(out); err != nil {
+			logger.errorf("writing report: %v", err)
+			return exitFailure
+		}
+		defer report.abort()
+		w = report
+	}
+	obs.stream = newJSONLStream(w)
+	if err := processRoots(r, obs, dirs); err != nil {
+		logger.errorf("%v", err)
+		return exitFailure
+	}
+	err := obs.stream.flush()
+	if err == nil && report != nil {
+		err = report.commit()
+	}
+	if err != nil {
+		logger.errorf("writing report: %v", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+func setupUndo(fs *flag.FlagSet) func(dirs []string) int {
+	log := addLogFlags(fs)
+	diff := fs.Bool("diff", false, "print a unified diff of the changes instead of writing them")
+	return func(dirs []string) int {
+		if err := log.apply(); err != nil {
+			return usageError(err)
+		}
+		return undoDirs(dirs, *diff)
+	}
+}
+
+// undoDirs runs undo on dirs and returns the exit status.
+func undoDirs(dirs []string, diff bool) int {
+	n := 0
+	for _, dir := range dirs {
+		removed, err := undoDirectory(dir, diff)
+		if err != nil {
+			logger.errorf("%v", err)
+			return exitFailure
+		}
+		n += removed
+	}
+	logger.infof("Removed %d stubs", n)
+	return exitOK
+}
+
+func setupDumpAST(fs *flag.FlagSet) func(args []string) int {
+	optFlags := addOptionFlags(fs)
+	return func(args []string) int {
+		file := args[0]
+		opts, err := optFlags.options()
+		if err != nil {
+			return usageError(err)
+		}
+		src, err := os.ReadFile(file)
+		if err != nil {
+			logger.errorf("%v", err)
+			return exitFailure
+		}
+		if err := dumpAST(os.Stdout, file, src, opts); err != nil {
+			logger.errorf("%v", err)
+			return exitFailure
+		}
+		return exitOK
+	}
+}
+
+func setupCapabilities(fs *flag.FlagSet) func(args []string) int {
+	optFlags := addOptionFlags(fs)
+	asJSON := fs.Bool("json", false, "print the capabilities as JSON")
+	return func([]string) int {
+		opts, err := optFlags.options()
+		if err != nil {
+			return usageError(err)
+		}
+		caps, err := Capabilities(opts)
+		if err != nil {
+			logger.errorf("%v", err)
+			return exitFailure
+		}
+		if err := printCapabilities(os.Stdout, caps, *asJSON); err != nil {
+			logger.errorf("%v", err)
+			return exitFailure
+		}
+		return exitOK
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"go/format"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	const src = "package p\n\nfunc f() {\n\tSyscall(1, 2, 3)\n}\n"
+	path := writeTemp(t, "p.go", src)
+	dir := filepath.Dir(path)
+
+	if got := run([]string{"check", dir}); got != exitNeedsChange {
+		t.Errorf("check before stubbing: exit %d, want %d", got, exitNeedsChange)
+	}
+	if got := run([]string{"check", "-bogus", dir}); got != exitUsage {
+		t.Errorf("check with unknown flag: exit %d, want %d", got, exitUsage)
+	}
+	if got := run(nil); got != exitUsage {
+		t.Errorf("no arguments: exit %d, want %d", got, exitUsage)
+	}
+	if got := run([]string{"check", "-exported-only", "-only-unexported", dir}); got != exitUsage {
+		t.Errorf("check with -exported-only and -only-unexported: exit %d, want %d", got, exitUsage)
+	}
+	if got := run([]string{"check", "-stub-tag=goos", "-mode=entry", dir}); got != exitUsage {
+		t.Errorf("check with -stub-tag=goos and -mode=entry: exit %d, want %d", got, exitUsage)
+	}
+
+	// Previews exit with status 0 whatever the pending changes; only
+	// -check reports them in its status.
+	if got := run([]string{"stub", "-dry-run", "-log-level=error", dir}); got != exitOK {
+		t.Errorf("stub -dry-run before stubbing: exit %d, want %d", got, exitOK)
+	}
+
+	// -exit-zero only applies to previews, and not to their failures.
+	if got := run([]string{"stub", "-dry-run", "-exit-zero", "-log-level=error", dir}); got != exitOK {
+		t.Errorf("stub -dry-run -exit-zero before stubbing: exit %d, want %d", got, exitOK)
+	}
+	badDir := filepath.Dir(writeTemp(t, "bad.go", "package p\nfunc f( {\n"))
+	if got := run([]string{"stub", "-dry-run", "-exit-zero", "-log-level=error", badDir}); got != exitFailure {
+		t.Errorf("stub -dry-run -exit-zero of a broken file: exit %d, want %d", got, exitFailure)
+	}
+	for _, args := range [][]string{{"-check", "-exit-zero"}, {"-check", "-dry-run", "-exit-zero"}, {"-exit-zero"}} {
+		if got := run(append(append([]string{"stub"}, args...), dir)); got != exitUsage {
+			t.Errorf("stub %v: exit %d, want %d", args, got, exitUsage)
+		}
+	}
+
+	// Without a command, the arguments are those of stub.
+	if got := run([]string{"-log-level=error", dir}); got != exitOK {
+		t.Fatalf("legacy stub: exit %d, want %d", got, exitOK)
+	}
+	if got := run([]string{"check", "-log-level=error", dir}); got != exitOK {
+		t.Errorf("check after stubbing: exit %d, want %d", got, exitOK)
+	}
+
+	if got := run([]string{"undo", "-log-level=error", dir}); got != exitOK {
+		t.Fatalf("undo: exit %d, want %d", got, exitOK)
+	}
+	if got := readFile(t, path); got != src {
+		t.Errorf("undo did not restore the file:\n%s", got)
+	}
+
+	if got := run([]string{"stub", "-log-level=error", dir}); got != exitOK {
+		t.Fatalf("stub: exit %d, want %d", got, exitOK)
+	}
+	if got := run([]string{"stub", "-strip", "-check", dir}); got != exitUsage {
+		t.Errorf("stub -strip -check: exit %d, want %d", got, exitUsage)
+	}
+	if got := run([]string{"stub", "-strip", "-log-level=error", dir}); got != exitOK {
+		t.Fatalf("stub -strip: exit %d, want %d", got, exitOK)
+	}
+	if got := readFile(t, path); got != src {
+		t.Errorf("stub -strip did not restore the file:\n%s", got)
+	}
+}
+
+func TestUndoShim(t *testing.T) {
+	src, err := os.ReadFile("testdata/shim.input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Stub("shim.go", src, fixtureOptions["shim"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, n := Undo(res.Output)
+	if want, _ := format.Source(src); n != len(res.Sites) || string(out) != string(want) {
+		t.Errorf("Undo removed %d stubs, leaving:\n%s\nwant %d, leaving:\n%s", n, out, len(res.Sites), want)
+	}
+}
+
+func TestUndoKeepsFuncBodies(t *testing.T) {
+	const src = "package p\n\nfunc f() int {\n\tpanic(\"syscall not supported in wasm: f\")\n}\n"
+	if out, n := Undo([]byte(src)); n != 0 || string(out) != src {
+		t.Errorf("Undo removed %d lines:\n%s", n, out)
+	}
+}
+
+func TestRunMultipleRoots(t *testing.T) {
+	const src = "package p\n\nfunc f() {\n\tSyscall(1, 2, 3)\n}\n"
+	a := filepath.Dir(writeTemp(t, "a.go", src))
+	b := filepath.Dir(writeTemp(t, "b.go", src))
+	out := filepath.Join(t.TempDir(), "report.json")
+
+	if got := run([]string{"report", "-log-level=error", "-o", out, a, b}); got != exitOK {
+		t.Fatalf("report: exit %d, want %d", got, exitOK)
+	}
+	records, err := readBaseline(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Root != a || records[1].Root != b {
+		t.Errorf("got records %+v, want one under each root", records)
+	}
+
+	if got := run([]string{"check", "-log-level=error", a, b}); got != exitNeedsChange {
+		t.Errorf("check: exit %d, want %d", got, exitNeedsChange)
+	}
+	if got := run([]string{"dump-ast", "p.go", "q.go"}); got != exitUsage {
+		t.Errorf("dump-ast with two files: exit %d, want %d", got, exitUsage)
+	}
+}
+
+// withStdin runs f with os.Stdin reading src.
+func withStdin(t *testing.T, src string, f func()) {
+	t.Helper()
+	in, err := os.Open(writeTemp(t, "stdin.go", src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	old := os.Stdin
+	os.Stdin = in
+	defer func() { os.Stdin = old }()
+	f()
+}
+
+func TestRunStdinCheck(t *testing.T) {
+	const unstubbed = "package p\n\nfunc f() {\n\tSyscall(1, 2, 3)\n}\n"
+	const stubbed = "package p\n\nfunc f() {\n\tpanic(\"syscall not supported in wasm: Syscall(1, 2, 3)\")\n\tSyscall(1, 2, 3)\n}\n"
+	tests := []struct {
+		src     string
+		want    int
+		records int
+	}{
+		{unstubbed, exitNeedsChange, 1},
+		{stubbed, exitOK, 0},
+	}
+	for _, tt := range tests {
+		report := filepath.Join(t.TempDir(), "report.json")
+		withStdin(t, tt.src, func() {
+			if got := run([]string{"stub", "-stdin", "-check", "-log-level=error", "-report", report}); got != tt.want {
+				t.Errorf("stub -stdin -check: exit %d, want %d", got, tt.want)
+			}
+		})
+		records, err := readBaseline(report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != tt.records || tt.records > 0 && records[0].File != "<stdin>" {
+			t.Errorf("report records = %+v, want %d for <stdin>", records, tt.records)
+		}
+	}
+
+	withStdin(t, unstubbed, func() {
+		if got := run([]string{"stub", "-stdin", "-log-level=error", "."}); got != exitUsage {
+			t.Errorf("stub -stdin with a directory: exit %d, want %d", got, exitUsage)
+		}
+	})
+}
+
+func TestProcessStdin(t *testing.T) {
+	const src = "package p\n\nfunc f() {}\n"
+	var out bytes.Buffer
+	r := &runner{opts: &Options{}}
+	if _, err := r.processStdin(strings.NewReader(src), &out, "p.go"); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != src {
+		t.Errorf("unchanged source not copied: %q", out.String())
+	}
+
+	out.Reset()
+	if _, err := r.processStdin(strings.NewReader("package p\n\nfunc f() {\n\tSyscall(1, 2, 3)\n}\n"), &out, "p.go"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), stubPrefix) {
+		t.Errorf("output not stubbed:\n%s", out.String())
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// A Config is the content of the file given to -config, conventionally
+// named syscall-stubs.json, gathering the settings of a tree in one place:
+//
+//	{
+//		"skip_funcs": ["IoctlGetWinsize", "Ioctl*"],
+//		"exclude": ["vendor/", "zsyscall_*_test.go"],
+//		"syscalls": {
+//			"Syscall6": {"action": "enosys", "message": "not available in the browser"},
+//			"RawSyscall": {"action": "ignore"}
+//		}
+//	}
+//
+// Only JSON is supported, to keep the tool free of dependencies.
+type Config struct {
+	// SkipFuncs lists the functions whose sites are left alone, as
+	// path.Match patterns of Site.Enclosing.
+	SkipFuncs []string `json:"skip_funcs"`
+
+	// Exclude lists files and directories to leave alone, in the syntax
+	// of ignore files, relative to each directory processed.
+	Exclude []string `json:"exclude"`
+
+	// Syscalls holds per-function rules, like a -rules file. Their action
+	// selects between panicking (panic), returning zero values as a no-op
+	// (zero), returning ENOSYS (enosys) and leaving the site alone
+	// (ignore).
+	Syscalls map[string]Rule `json:"syscalls"`
+}
+
+// loadConfig reads the Config at path, rejecting unknown fields so that
+// misspelled settings are not silently ignored.
+func loadConfig(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	var c Config
+	if err := dec.Decode(&c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &c, nil
+}
+
+func (c *Config) validate() error {
+	for _, pattern := range c.SkipFuncs {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("skip_funcs: bad pattern %q", pattern)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(c.Syscalls)) {
+		if action := c.Syscalls[name].Action; !actions[action] {
+			return fmt.Errorf("syscalls: unknown action %q for %s (want panic, enosys, zero or ignore)", action, name)
+		}
+	}
+	return nil
+}
+
+// apply sets the options of opts configured by c. Rules of c for functions
+// already having one, from a -rules file, are an error.
+func (c *Config) apply(opts *Options) error {
+	opts.SkipFuncs = append(opts.SkipFuncs, c.SkipFuncs...)
+	opts.Exclude = append(opts.Exclude, c.Exclude...)
+	for _, name := range slices.Sorted(maps.Keys(c.Syscalls)) {
+		if _, ok := opts.Rules[name]; ok {
+			return fmt.Errorf("rule for %s given both in the config and the rules file", name)
+		}
+		if opts.Rules == nil {
+			opts.Rules = make(map[string]Rule)
+		}
+		opts.Rules[name] = c.Syscalls[name]
+	}
+	return nil
+}
+
+// skipFunc reports whether the sites of the function declaration name are
+// left alone by Options.SkipFuncs.
+func (o *Options) skipFunc(name string) bool {
+	if o == nil || name == "" {
+		return false
+	}
+	return slices.ContainsFunc(o.SkipFuncs, func(pattern string) bool {
+		ok, _ := path.Match(pattern, name)
+		return ok
+	})
+}
+
+// excludeRules returns Options.Exclude parsed as the rules of an ignore
+// file.
+func (o *Options) excludeRules() []ignoreRule {
+	if o == nil {
+		return nil
+	}
+	return parseIgnore(strings.Join(o.Exclude, "\n"))
+}
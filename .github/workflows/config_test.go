@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name, data, err string
+	}{
+		{"ok", `{"skip_funcs": ["Ioctl*"], "exclude": ["vendor/"], "syscalls": {"Syscall6": {"action": "enosys"}}}`, ""},
+		{"unknown", `{"skip_func": ["Ioctl*"]}`, `unknown field "skip_func"`},
+		{"action", `{"syscalls": {"Syscall": {"action": "noop"}}}`, `unknown action "noop" for Syscall`},
+		{"pattern", `{"skip_funcs": ["["]}`, `bad pattern "["`},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.name+".json")
+		writeFile(t, path, tt.data)
+		_, err := loadConfig(path)
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s: loadConfig: %v, want error containing %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestConfig(t *testing.T) {
+	root := t.TempDir()
+	const src = `package p
+
+func IoctlGetWinsize() {
+	Syscall(SYS_IOCTL, 0, 0, 0)
+}
+
+func read() (n int, err error) {
+	Syscall6(SYS_READ, 0, 0, 0, 0, 0, 0)
+	return
+}
+`
+	for _, name := range []string{"a.go", "vendor/v/b.go"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, path, src)
+	}
+	c := &Config{
+		SkipFuncs: []string{"Ioctl*"},
+		Exclude:   []string{"vendor/"},
+		Syscalls:  map[string]Rule{"Syscall6": {Action: ActionENOSYS}},
+	}
+	opts := &Options{Rules: map[string]Rule{"Syscall": {Action: ActionZero}}}
+	if err := c.apply(opts); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&runner{opts: opts}).processDirectory(root); err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Replace(src, "\tSyscall6(", "\treturn 0, ENOSYS\n\tSyscall6(", 1)
+	if got := readFile(t, filepath.Join(root, "a.go")); got != want {
+		t.Errorf("a.go:\n%s\nwant:\n%s", got, want)
+	}
+	if got := readFile(t, filepath.Join(root, "vendor", "v", "b.go")); got != src {
+		t.Errorf("excluded vendor/v/b.go was changed:\n%s", got)
+	}
+
+	c.Syscalls = map[string]Rule{"Syscall": {Action: ActionPanic}}
+	if err := c.apply(opts); err == nil {
+		t.Error("apply with a rule for Syscall already given: no error")
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// constantsFile is the name of the file written by -stub-constants. The
+// _wasm suffix restricts it to GOARCH=wasm.
+const constantsFile = "zwasmstub_sysnum_wasm.go"
+
+// wasmContext is the build context whose files are checked for undefined
+// syscall numbers.
+var wasmContext = func() build.Context {
+	ctxt := build.Default
+	ctxt.GOOS = "js"
+	ctxt.GOARCH = "wasm"
+	ctxt.CgoEnabled = false
+	return ctxt
+}()
+
+// stubConstants writes a constantsFile into every package directory under
+// root whose js/wasm files refer to SYS_* identifiers that no js/wasm file
+// defines, declaring each of them as 0, and removes stale ones. It returns
+// the paths of the files written.
+//
+// This is a last-resort compatibility shim: stubbed wrappers keep their
+// original syscall after the inserted panic, and that dead code must still
+// compile. The placeholder numbers are never passed to a real syscall.
+func stubConstants(root string) ([]string, error) {
+	var written []string
+	err := filepath.WalkDir(root, func(dir string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		pkg, names, err := undefinedSyscallNumbers(dir)
+		if err != nil {
+			return fmt.Errorf("checking %s: %w", dir, err)
+		}
+		file := filepath.Join(dir, constantsFile)
+		if len(names) == 0 {
+			if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			return nil
+		}
+		if err := writeFileAtomic(file, constantsSource(pkg, names)); err != nil {
+			return err
+		}
+		written = append(written, file)
+		return nil
+	})
+	return written, err
+}
+
+// undefinedSyscallNumbers type-checks the non-test Go files in dir that
+// build for js/wasm, ignoring any previous constantsFile, and returns the
+// package name and the sorted names of the SYS_* identifiers they use
+// without a declaration.
+func undefinedSyscallNumbers(dir string) (string, []string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") || name == constantsFile {
+			continue
+		}
+		if ok, err := wasmContext.MatchFile(dir, name); err != nil || !ok {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, 0)
+		if err != nil {
+			return "", nil, err
+		}
+		files = append(files, f)
+	}
+	if len(files) == 0 {
+		return "", nil, nil
+	}
+
+	info := &types.Info{
+		Defs: make(map[*ast.Ident]types.Object),
+		Uses: make(map[*ast.Ident]types.Object),
+	}
+	conf := types.Config{
+		Importer: fakeImporter{},
+		// Errors are expected, not least for the undefined identifiers
+		// being looked for; keep checking past them.
+		Error: func(error) {},
+	}
+	conf.Check(files[0].Name.Name, fset, files, info)
+
+	seen := make(map[string]bool)
+	var visit func(n ast.Node) bool
+	visit = func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			// The selected name is qualified; only the operand can be
+			// an unqualified SYS_* identifier.
+			ast.Inspect(n.X, visit)
+			return false
+		case *ast.Ident:
+			if strings.HasPrefix(n.Name, "SYS_") && info.Uses[n] == nil && info.Defs[n] == nil {
+				seen[n.Name] = true
+			}
+		}
+		return true
+	}
+	for _, f := range files {
+		ast.Inspect(f, visit)
+	}
+	var names []string
+	for name := range seen {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return files[0].Name.Name, names, nil
+}
+
+// constantsSource returns the source of a constantsFile for package pkg
+// declaring names as 0.
+func constantsSource(pkg string, names []string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by wasmstub -stub-constants; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "// Placeholder syscall numbers referenced by stubbed code but not defined\n")
+	fmt.Fprintf(&b, "// for wasm. The calls using them are never reached.\n\n")
+	fmt.Fprintf(&b, "package %s\n\nconst (\n", pkg)
+	for _, name := range names {
+		fmt.Fprintf(&b, "\t%s = 0\n", name)
+	}
+	fmt.Fprintf(&b, ")\n")
+	out, err := format.Source(b.Bytes())
+	if err != nil {
+		panic(err) // names are identifiers, so the source is always valid
+	}
+	return out
+}
+
+// fakeImporter returns empty packages, which is enough to resolve the
+// declarations of the package being checked.
+type fakeImporter struct{}
+
+func (fakeImporter) Import(importPath string) (*types.Package, error) {
+	if importPath == "unsafe" {
+		return types.Unsafe, nil
+	}
+	pkg := types.NewPackage(importPath, path.Base(importPath))
+	pkg.MarkComplete()
+	return pkg, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStubConstants(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "syscall_unix.go"), `package unix
+
+import "syscall"
+
+const SYS_DEFINED = 1
+
+func read(fd int) {
+	panic("syscall not supported in wasm: Syscall(SYS_READ, uintptr(fd), 0, 0)")
+	Syscall(SYS_READ, uintptr(fd), 0, 0)
+	Syscall(SYS_DEFINED, syscall.SYS_WRITE, 0, 0)
+	Syscall(SYS_ABSENT, 0, 0, 0)
+}
+`)
+	writeFile(t, filepath.Join(dir, "zsysnum_linux.go"), "//go:build linux\n\npackage unix\n\nconst SYS_READ = 0\n")
+	writeFile(t, filepath.Join(dir, "x_test.go"), "package unix\n\nvar _ = SYS_TESTONLY\n")
+
+	written, err := stubConstants(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, constantsFile)
+	if len(written) != 1 || written[0] != file {
+		t.Fatalf("wrote %q, want %q", written, file)
+	}
+	const want = `// Code generated by wasmstub -stub-constants; DO NOT EDIT.
+
+// Placeholder syscall numbers referenced by stubbed code but not defined
+// for wasm. The calls using them are never reached.
+
+package unix
+
+const (
+	SYS_ABSENT = 0
+	SYS_READ   = 0
+)
+`
+	if got := readFile(t, file); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// A second run ignores the previous output and writes the same file.
+	if _, err := stubConstants(dir); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, file); got != want {
+		t.Errorf("after second run got:\n%s\nwant:\n%s", got, want)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "syscall_unix.go")); err != nil {
+		t.Fatal(err)
+	}
+	written, err = stubConstants(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(written) != 0 {
+		t.Errorf("wrote %q for a package without undefined numbers", written)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("stale %s was not removed: %v", constantsFile, err)
+	}
+}
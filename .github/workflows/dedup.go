@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// generatedPrefix starts the panic statements inserted by any version of
+// the tool, including older ones that did not name the call, as in
+// panic("syscall not supported in wasm"), or did not quote it.
+const generatedPrefix = `panic("syscall not supported in wasm`
+
+// dedupPanics collapses each run of consecutive generated panics in src,
+// as left by runs of different versions of the tool, into a single one,
+// and returns the result and the number of lines removed. The panic kept
+// is the last one in the current format, if any, and otherwise the last
+// one.
+func dedupPanics(src []byte) ([]byte, int) {
+	lines := bytes.SplitAfter(src, []byte("\n"))
+	out := make([]byte, 0, len(src))
+	n := 0
+	for i := 0; i < len(lines); {
+		j := i
+		for j < len(lines) && strings.HasPrefix(string(bytes.TrimSpace(lines[j])), generatedPrefix) {
+			j++
+		}
+		if j-i < 2 {
+			out = append(out, lines[i]...)
+			i++
+			continue
+		}
+		keep := j - 1
+		for k := j - 1; k >= i; k-- {
+			if isCanonicalPanic(string(bytes.TrimSpace(lines[k]))) {
+				keep = k
+				break
+			}
+		}
+		out = append(out, lines[keep]...)
+		n += j - i - 1
+		i = j
+	}
+	return out, n
+}
+
+// isCanonicalPanic reports whether text is a panic statement in the format
+// of DefaultInsert.
+func isCanonicalPanic(text string) bool {
+	arg, ok := strings.CutPrefix(text, "panic(")
+	if !ok {
+		return false
+	}
+	arg, ok = strings.CutSuffix(arg, ")")
+	if !ok {
+		return false
+	}
+	msg, err := strconv.Unquote(arg)
+	return err == nil && strings.HasPrefix(msg, "syscall not supported in wasm: ")
+}
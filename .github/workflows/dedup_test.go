@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestDedupPanics(t *testing.T) {
+	tests := []struct {
+		src, want string
+		n         int
+	}{
+		{
+			// The panic in the current format is kept, wherever it is.
+			"\tpanic(\"syscall not supported in wasm: Syscall(1, 2, 3)\")\n\tpanic(\"syscall not supported in wasm\")\n\tSyscall(1, 2, 3)\n",
+			"\tpanic(\"syscall not supported in wasm: Syscall(1, 2, 3)\")\n\tSyscall(1, 2, 3)\n",
+			1,
+		},
+		{
+			// Without one, the last panic is kept.
+			"panic(\"syscall not supported in wasm\")\npanic(\"syscall not supported in wasm (old)\")\n",
+			"panic(\"syscall not supported in wasm (old)\")\n",
+			1,
+		},
+		{
+			// Panics that are not consecutive are left alone.
+			"panic(\"syscall not supported in wasm\")\n\npanic(\"syscall not supported in wasm\")\n",
+			"panic(\"syscall not supported in wasm\")\n\npanic(\"syscall not supported in wasm\")\n",
+			0,
+		},
+	}
+	for _, tt := range tests {
+		got, n := dedupPanics([]byte(tt.src))
+		if string(got) != tt.want || n != tt.n {
+			t.Errorf("dedupPanics(%q) = %q, %d; want %q, %d", tt.src, got, n, tt.want, tt.n)
+		}
+	}
+}
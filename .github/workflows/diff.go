@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// diffOp is a single line of an edit script: kind is ' ' for a line common
+// to both inputs, '-' for a deleted line and '+' for an inserted one.
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// unifiedDiff returns a unified diff turning a into b, with context
+// unchanged lines around each change, like diff -U. It returns nil if a and b
+// are equal.
+func unifiedDiff(oldName, newName string, a, b []byte, context int) []byte {
+	if bytes.Equal(a, b) {
+		return nil
+	}
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	// aLine[i] and bLine[i] are the number of lines of a and b consumed
+	// before ops[i].
+	aLine := make([]int, len(ops)+1)
+	bLine := make([]int, len(ops)+1)
+	for i, op := range ops {
+		aLine[i+1], bLine[i+1] = aLine[i], bLine[i]
+		if op.kind != '+' {
+			aLine[i+1]++
+		}
+		if op.kind != '-' {
+			bLine[i+1]++
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+	for i := 0; i < len(ops); {
+		for i < len(ops) && ops[i].kind == ' ' {
+			i++
+		}
+		if i == len(ops) {
+			break
+		}
+
+		// Extend the hunk over changes separated by at most 2*context
+		// unchanged lines, so that their context would overlap.
+		end := i
+		for {
+			for end < len(ops) && ops[end].kind != ' ' {
+				end++
+			}
+			next := end
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+			if next == len(ops) || next-end > 2*context {
+				break
+			}
+			end = next
+		}
+		start := max(i-context, 0)
+		stop := min(end+context, len(ops))
+
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n",
+			hunkRange(aLine[start], aLine[stop]-aLine[start]),
+			hunkRange(bLine[start], bLine[stop]-bLine[start]))
+		for _, op := range ops[start:stop] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.line)
+			if !strings.HasSuffix(op.line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = stop
+	}
+	return out.Bytes()
+}
+
+// hunkRange formats the start and length of a hunk, where start is the
+// number of lines preceding it.
+func hunkRange(start, n int) string {
+	if n == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if n == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
+
+// splitLines splits b into lines, each keeping its trailing newline.
+func splitLines(b []byte) []string {
+	lines := strings.SplitAfter(string(b), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns a shortest edit script turning a into b, computed with
+// Myers' O(ND) algorithm.
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	maxD := n + m
+	if maxD == 0 {
+		return nil
+	}
+	v := make([]int, 2*maxD+2)
+	// trace[d] holds v as it was before step d.
+	var trace [][]int
+	var d int
+search:
+	for d = 0; d <= maxD; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[maxD+k-1] < v[maxD+k+1]) {
+				x = v[maxD+k+1]
+			} else {
+				x = v[maxD+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[maxD+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	var ops []diffOp
+	x, y := n, m
+	for ; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[maxD+k-1] < v[maxD+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[maxD+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			ops = append(ops, diffOp{' ', a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			ops = append(ops, diffOp{'+', b[y-1]})
+			y--
+		} else {
+			ops = append(ops, diffOp{'-', a[x-1]})
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		ops = append(ops, diffOp{' ', a[x-1]})
+		x--
+		y--
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
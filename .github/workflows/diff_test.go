@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	var a []string
+	for i := 1; i <= 20; i++ {
+		a = append(a, "line"+string(rune('a'+i-1)))
+	}
+	b := append([]string{}, a[:3]...)
+	b = append(b, "new1")
+	b = append(b, a[3:15]...)
+	b = append(b, "new2")
+	b = append(b, a[16:]...)
+	old := strings.Join(a, "\n") + "\n"
+	new := strings.Join(b, "\n") + "\n"
+
+	tests := []struct {
+		context int
+		want    string
+	}{
+		{0, `--- a
++++ b
+@@ -3,0 +4 @@
++new1
+@@ -16 +17 @@
+-linep
++new2
+`},
+		{1, `--- a
++++ b
+@@ -3,2 +3,3 @@
+ linec
++new1
+ lined
+@@ -15,3 +16,3 @@
+ lineo
+-linep
++new2
+ lineq
+`},
+		{6, `--- a
++++ b
+@@ -1,20 +1,21 @@
+ linea
+ lineb
+ linec
++new1
+ lined
+ linee
+ linef
+ lineg
+ lineh
+ linei
+ linej
+ linek
+ linel
+ linem
+ linen
+ lineo
+-linep
++new2
+ lineq
+ liner
+ lines
+ linet
+`},
+	}
+	for _, tt := range tests {
+		got := string(unifiedDiff("a", "b", []byte(old), []byte(new), tt.context))
+		if got != tt.want {
+			t.Errorf("context %d: got:\n%s\nwant:\n%s", tt.context, got, tt.want)
+		}
+	}
+
+	if d := unifiedDiff("a", "b", []byte(old), []byte(old), 3); d != nil {
+		t.Errorf("diff of equal inputs = %q, want nil", d)
+	}
+}
+
+func TestUnifiedDiffNoNewline(t *testing.T) {
+	got := string(unifiedDiff("a", "b", []byte("x\ny"), []byte("x\nz\n"), 3))
+	want := `--- a
++++ b
+@@ -1,2 +1,2 @@
+ x
+-y
+\ No newline at end of file
++z
+`
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// A lineRange is an inclusive range of line numbers.
+type lineRange struct{ from, to int }
+
+// offRegions returns the line ranges of file between a //wasmstub:off
+// directive and the next //wasmstub:on, or the end of the file if there is
+// none. Directives must be line comments of their own, without a space
+// after the slashes, like other Go directives.
+func offRegions(fset *token.FileSet, file *ast.File) []lineRange {
+	var regions []lineRange
+	off := 0 // line of the pending //wasmstub:off, or 0
+	for _, group := range file.Comments {
+		for _, c := range group.List {
+			switch strings.TrimSpace(c.Text) {
+			case "//wasmstub:off":
+				if off == 0 {
+					off = fset.Position(c.Pos()).Line
+				}
+			case "//wasmstub:on":
+				if off != 0 {
+					regions = append(regions, lineRange{off, fset.Position(c.Pos()).Line})
+					off = 0
+				}
+			}
+		}
+	}
+	if off != 0 {
+		regions = append(regions, lineRange{off, fset.File(file.Pos()).LineCount()})
+	}
+	return regions
+}
+
+// inRegions reports whether line is in one of regions.
+func inRegions(line int, regions []lineRange) bool {
+	for _, r := range regions {
+		if r.from <= line && line <= r.to {
+			return true
+		}
+	}
+	return false
+}
+
+// isDirectiveLine reports whether line holds nothing but a directive
+// comment, such as //go:nosplit or //line, which applies to what follows it.
+// Directives are recognized like go/ast does: "//line " or "//" followed by
+// a lower-case name, a colon and a lower-case letter or digit. The
+// //wasmstub: directives delimit regions rather than apply to a line, and
+// are not included.
+func isDirectiveLine(line []byte) bool {
+	text, ok := strings.CutPrefix(strings.TrimSpace(string(line)), "//")
+	if !ok || strings.HasPrefix(text, "wasmstub:") {
+		return false
+	}
+	if strings.HasPrefix(text, "line ") {
+		return true
+	}
+	name, rest, ok := strings.Cut(text, ":")
+	if !ok || name == "" || rest == "" || !isLowerAlnum(rest[0]) {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isLowerAlnum(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isLowerAlnum(c byte) bool {
+	return 'a' <= c && c <= 'z' || '0' <= c && c <= '9'
+}
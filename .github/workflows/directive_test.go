@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestIsDirectiveLine(t *testing.T) {
+	for line, want := range map[string]bool{
+		"\t//go:nosplit":                 true,
+		"//go:linkname f runtime.f":      true,
+		"//line foo.go:10":               true,
+		"//lint:ignore SA1019 reason":    true,
+		"// go:nosplit":                  false,
+		"//Go:nosplit":                   false,
+		"//go:":                          false,
+		"// A comment: with a colon":     false,
+		"//wasmstub:off":                 false,
+		"x := 1 //go:nosplit":            false,
+		"//export f":                     false,
+		"//http://example.com is a link": false,
+	} {
+		if got := isDirectiveLine([]byte(line)); got != want {
+			t.Errorf("isDirectiveLine(%q) = %v, want %v", line, got, want)
+		}
+	}
+}
+
+func TestUndoAboveDirectives(t *testing.T) {
+	input, err := os.ReadFile("testdata/directives.input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden, err := os.ReadFile("testdata/directives.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := Undo(golden); string(got) != string(input) {
+		t.Errorf("Undo did not restore the input:\n%s", got)
+	}
+}
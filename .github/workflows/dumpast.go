@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"strings"
+)
+
+// dumpAST prints the AST of src like ast.Print, annotating the Lparen line
+// of every call the matcher considers with whether it matched and, if not,
+// the type of its Fun.
+func dumpAST(w io.Writer, filename string, src []byte, opts *Options) error {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+
+	m := newMatcher(file, opts)
+	notes := make(map[string]string) // by position of Lparen
+	for _, decl := range file.Decls {
+		ast.Inspect(decl, func(n ast.Node) bool {
+			stmt, ok := n.(ast.Stmt)
+			if !ok {
+				return true
+			}
+			for _, expr := range stmtExprs(stmt) {
+				ast.Inspect(expr, func(n ast.Node) bool {
+					switch n := n.(type) {
+					case *ast.FuncLit:
+						return false
+					case *ast.CallExpr:
+						note := fmt.Sprintf("not matched: Fun is %T", n.Fun)
+						if name, ok := m.matchCall(n); ok {
+							note = "matched as " + name
+						}
+						notes[fset.Position(n.Lparen).String()] = note
+					}
+					return true
+				})
+			}
+			return true
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := ast.Fprint(&buf, fset, file, ast.NotNilFilter); err != nil {
+		return err
+	}
+	sc := bufio.NewScanner(&buf)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		line := sc.Text()
+		if _, pos, ok := strings.Cut(line, "Lparen: "); ok {
+			if note, ok := notes[pos]; ok {
+				line += "  <- " + note
+			}
+		}
+		fmt.Fprintln(w, line)
+	}
+	return sc.Err()
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDumpAST(t *testing.T) {
+	const src = "package p\n\nfunc f() {\n\tunix.Syscall(1, 2, 3)\n\tSyscall(1, 2, 3)\n}\n"
+	var b bytes.Buffer
+	if err := dumpAST(&b, "p.go", []byte(src), nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Lparen: p.go:4:14  <- not matched: Fun is *ast.SelectorExpr\n",
+		"Lparen: p.go:5:9  <- matched as Syscall\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("output does not contain %q", want)
+		}
+	}
+}
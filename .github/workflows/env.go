@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// optsEnv is the environment variable holding default flags, applied before
+// those of the command line so that these override them.
+const optsEnv = "WASMSTUB_OPTS"
+
+// envFlags returns the words of the optsEnv variable that set flags defined
+// by fs. Flags that fs does not define are dropped, with their value, so
+// that the same defaults can serve every command.
+func envFlags(fs *flag.FlagSet) ([]string, error) {
+	words, err := splitWords(os.Getenv(optsEnv))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", optsEnv, err)
+	}
+	var args []string
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		if !strings.HasPrefix(word, "-") || word == "-" || word == "--" {
+			return nil, fmt.Errorf("%s: %q is not a flag", optsEnv, word)
+		}
+		name, _, hasValue := strings.Cut(strings.TrimLeft(word, "-"), "=")
+		f := fs.Lookup(name)
+		// A flag without "=" takes the next word as its value unless it
+		// is boolean; undefined flags are assumed to be boolean when the
+		// next word is a flag.
+		takesNext := !hasValue && i+1 < len(words) && !isBoolFlag(f) &&
+			(f != nil || !strings.HasPrefix(words[i+1], "-"))
+		if f != nil {
+			args = append(args, word)
+			if takesNext {
+				args = append(args, words[i+1])
+			}
+		}
+		if takesNext {
+			i++
+		}
+	}
+	return args, nil
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	if f == nil {
+		return false
+	}
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// splitWords splits s into words like a POSIX shell, honoring single and
+// double quotes and backslash escapes, without any expansion.
+func splitWords(s string) ([]string, error) {
+	var (
+		words []string
+		word  strings.Builder
+		in    bool // whether a word is started, possibly empty as in ''
+	)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			if in {
+				words = append(words, word.String())
+				word.Reset()
+				in = false
+			}
+		case c == '\\':
+			if i+1 == len(s) {
+				return nil, errors.New("trailing backslash")
+			}
+			i++
+			word.WriteByte(s[i])
+			in = true
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			word.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+			in = true
+		case c == '"':
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("\\\"$`", s[i+1]) >= 0 {
+					i++
+				}
+				word.WriteByte(s[i])
+			}
+			if i == len(s) {
+				return nil, errors.New("unterminated double quote")
+			}
+			in = true
+		default:
+			word.WriteByte(c)
+			in = true
+		}
+	}
+	if in {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
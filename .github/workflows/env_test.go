@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+)
+
+func TestSplitWords(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"  -a  -b=1 ", []string{"-a", "-b=1"}},
+		{`-funcs='a b' -rules="x \"y\".txt"`, []string{"-funcs=a b", `-rules=x "y".txt`}},
+		{`a\ b '' "it's"`, []string{"a b", "", "it's"}},
+	}
+	for _, tt := range tests {
+		got, err := splitWords(tt.in)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitWords(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{`'a`, `"a`, `a\`} {
+		if _, err := splitWords(in); err == nil {
+			t.Errorf("splitWords(%q) succeeded, want error", in)
+		}
+	}
+}
+
+func TestEnvFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	mode := fs.String("mode", "insert", "")
+	check := fs.Bool("check", false, "")
+	t.Setenv(optsEnv, "-unknown -other value -mode nop -check -also=1")
+	defaults, err := envFlags(fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"-mode", "nop", "-check"}; !reflect.DeepEqual(defaults, want) {
+		t.Errorf("got defaults %q, want %q", defaults, want)
+	}
+	if err := fs.Parse(defaults); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse([]string{"-mode=entry", "dir"}); err != nil {
+		t.Fatal(err)
+	}
+	if *mode != "entry" || !*check || fs.Arg(0) != "dir" {
+		t.Errorf("got mode %q, check %v, args %q; want entry, true, [dir]", *mode, *check, fs.Args())
+	}
+
+	t.Setenv(optsEnv, "dir")
+	if _, err := envFlags(fs); err == nil {
+		t.Error("envFlags accepted a directory")
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// errnoVarRE matches the names given to the errno result of raw syscalls in
+// the generated wrappers.
+var errnoVarRE = regexp.MustCompile(`^(e[0-9]*|errno)$`)
+
+// errnoFuncs are the raw syscall functions whose third result is an Errno.
+var errnoFuncs = map[string]bool{
+	"Syscall":     true,
+	"Syscall6":    true,
+	"RawSyscall":  true,
+	"RawSyscall6": true,
+}
+
+// usesLocals reports whether args refer to the unsafe package or to
+// variables declared in a function body, which would be left unused if
+// the call was removed. Parameters and package-level names stay valid.
+func usesLocals(args []ast.Expr) bool {
+	found := false
+	for _, arg := range args {
+		ast.Inspect(arg, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.SelectorExpr:
+				if x, ok := n.X.(*ast.Ident); ok && x.Name == "unsafe" && x.Obj == nil {
+					found = true
+				}
+			case *ast.Ident:
+				if n.Obj != nil && n.Obj.Kind == ast.Var {
+					if _, param := n.Obj.Decl.(*ast.Field); !param {
+						found = true
+					}
+				}
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+// errnoRewrites finds the statements of src in the classic shape of the
+// generated wrappers,
+//
+//	r0, _, e1 := Syscall(...)
+//	...
+//	err = errnoErr(e1)
+//
+// that is, an assignment of the results of a raw syscall function whose
+// errno variable is later passed to an errnoErr-style function. It returns
+// the replacements of these statements with assignments of zeros and
+// ENOSYS, dropping the blank identifiers, such as r0, e1 := uintptr(0),
+// ENOSYS, keyed by the offset of the syscall call.
+func errnoRewrites(filename string, src []byte) (map[int]replacement, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, 0)
+	if err != nil {
+		return nil, err
+	}
+	rewrites := make(map[int]replacement)
+	for _, decl := range file.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if !ok || fd.Body == nil {
+			continue
+		}
+		ast.Inspect(fd.Body, func(n ast.Node) bool {
+			assign, ok := n.(*ast.AssignStmt)
+			if !ok || len(assign.Lhs) != 3 || len(assign.Rhs) != 1 || (assign.Tok != token.DEFINE && assign.Tok != token.ASSIGN) {
+				return true
+			}
+			call, ok := assign.Rhs[0].(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			fun, ok := call.Fun.(*ast.Ident)
+			errno, isIdent := assign.Lhs[2].(*ast.Ident)
+			if !ok || !errnoFuncs[fun.Name] || !isIdent || !errnoVarRE.MatchString(errno.Name) || !passedToErrnoErr(fd.Body, errno.Name) {
+				return true
+			}
+			var names, values []string
+			for i, lhs := range assign.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok && id.Name == "_" {
+					continue
+				}
+				names = append(names, string(src[fset.Position(lhs.Pos()).Offset:fset.Position(lhs.End()).Offset]))
+				if i == 2 {
+					values = append(values, "ENOSYS")
+				} else {
+					values = append(values, "uintptr(0)")
+				}
+			}
+			start, end := fset.Position(assign.Pos()).Offset, fset.Position(assign.End()).Offset
+			text := strings.Join(names, ", ") + " " + assign.Tok.String() + " " + strings.Join(values, ", ")
+			if usesLocals(call.Args) {
+				// Keep the arguments evaluated, so that the locals
+				// and imports they use are still used.
+				var args []string
+				for _, arg := range call.Args {
+					args = append(args, string(src[fset.Position(arg.Pos()).Offset:fset.Position(arg.End()).Offset]))
+				}
+				indent := indentAt(src, fset.Position(assign.Pos()))
+				text = "_ = []uintptr{" + strings.Join(args, ", ") + "}\n" + string(indent) + text
+			}
+			rewrites[fset.Position(call.Pos()).Offset] = replacement{offset: start, length: end - start, text: text}
+			return true
+		})
+	}
+	return rewrites, nil
+}
+
+// passedToErrnoErr reports whether body calls a function named like
+// errnoErr with the variable name as an argument.
+func passedToErrnoErr(body *ast.BlockStmt, name string) bool {
+	found := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || found {
+			return !found
+		}
+		var fun string
+		switch f := call.Fun.(type) {
+		case *ast.Ident:
+			fun = f.Name
+		case *ast.SelectorExpr:
+			fun = f.Sel.Name
+		}
+		if !strings.HasPrefix(strings.ToLower(fun), "errnoerr") {
+			return true
+		}
+		for _, arg := range call.Args {
+			if id, ok := arg.(*ast.Ident); ok && id.Name == name {
+				found = true
+			}
+		}
+		return true
+	})
+	return found
+}
+
+// returnsErrno reports whether the sites that ModeErrno cannot rewrite can
+// return ENOSYS instead, as they are in a function declaration, outside
+// function literals, with an error or Errno result.
+func returnsErrno(site Site) bool {
+	return site.Enclosing != "" && !site.Literal && slices.ContainsFunc(site.Results, func(typ string) bool {
+		return typ == "error" || typ == "Errno"
+	})
+}
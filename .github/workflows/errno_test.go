@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestModeErrnoStrictPositions(t *testing.T) {
+	src, err := os.ReadFile("testdata/errno.input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("testdata/errno.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Stub("errno.go", src, &Options{Mode: ModeErrno, StrictPositions: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(res.Output) != string(want) {
+		t.Errorf("strict positions:\n%s\nwant:\n%s", res.Output, want)
+	}
+}
+
+func TestErrnoRewritesShape(t *testing.T) {
+	const src = `package p
+
+func f() (err error) {
+	r0, _, e1 := Syscall(1, 2, 3)
+	_ = r0
+	err = errnoErr(e1)
+	_, _, err2 := Syscall(1, 2, 3)
+	err = errnoErr(err2)
+	_, _, e2 := Syscall(1, 2, 3)
+	_ = e2
+	_, _, e3 := Other(1, 2, 3)
+	err = errnoErr(e3)
+	return
+}
+`
+	rewrites, err := errnoRewrites("p.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rewrites) != 1 {
+		t.Fatalf("got %d rewrites, want 1: %+v", len(rewrites), rewrites)
+	}
+	for _, r := range rewrites {
+		if r.text != "r0, e1 := uintptr(0), ENOSYS" {
+			t.Errorf("got rewrite %q", r.text)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import "fmt"
+
+// ParseError is returned by FindSites and Stub when a file cannot be
+// parsed. With Options.BestEffort, it is returned by FindSites along with
+// the sites of the partial AST, and set as Result.ParseErr by Stub.
+type ParseError struct {
+	Path    string
+	Err     error // from go/parser, whose messages already include positions
+	Partial bool  // whether the sites of the partial AST were kept
+}
+
+func (e *ParseError) Error() string { return e.Err.Error() }
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// FormatError is set as Result.FormatErr when the stubbed source of a file
+// cannot be formatted.
+type FormatError struct {
+	Path string
+	Err  error
+}
+
+func (e *FormatError) Error() string { return e.Err.Error() }
+func (e *FormatError) Unwrap() error { return e.Err }
+
+// WriteError is returned when a stubbed file cannot be written.
+type WriteError struct {
+	Path string
+	Err  error
+}
+
+func (e *WriteError) Error() string { return e.Err.Error() }
+func (e *WriteError) Unwrap() error { return e.Err }
+
+// TooLargeError is returned when a file is skipped for being larger than
+// the -max-file-size limit.
+type TooLargeError struct {
+	Path      string
+	Size, Max int64
+}
+
+func (e *TooLargeError) Error() string {
+	return fmt.Sprintf("%s: file size %d exceeds the limit of %d bytes", e.Path, e.Size, e.Max)
+}
+
+// HookError is returned when the -post-hook command fails for a written
+// file.
+type HookError struct {
+	Path string
+	Cmd  string
+	Err  error // from os/exec, usually an *exec.ExitError
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("post-hook %q for %s: %v", e.Cmd, e.Path, e.Err)
+}
+func (e *HookError) Unwrap() error { return e.Err }
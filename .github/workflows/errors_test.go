@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestErrorTypes(t *testing.T) {
+	_, err := Stub("bad.go", []byte("package p\n\nfunc {\n"), nil)
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Path != "bad.go" {
+		t.Errorf("Stub error = %v, want *ParseError for bad.go", err)
+	}
+
+	res, err := Stub("p.go", []byte("package p\n\nfunc f() {\n\tSyscall(1, 2, 3)\n}\n"), &Options{InsertFunc: unbalancedInsert})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var formatErr *FormatError
+	if !errors.As(res.FormatErr, &formatErr) || formatErr.Path != "p.go" {
+		t.Errorf("FormatErr = %v, want *FormatError for p.go", res.FormatErr)
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "p.go")
+	writeFile(t, path, "package p\n\nfunc f() {\n\tSyscall(1, 2, 3)\n}\n")
+	defer func(old func(string, string) error) { rename = old }(rename)
+	rename = func(string, string) error { return os.ErrPermission }
+	_, err = new(runner).processFile(path)
+	var writeErr *WriteError
+	if !errors.As(err, &writeErr) || writeErr.Path != path || !errors.Is(err, os.ErrPermission) {
+		t.Errorf("processFile error = %v, want *WriteError wrapping os.ErrPermission", err)
+	}
+}
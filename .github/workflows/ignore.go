@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFile is the name of the files listing paths to leave alone, in a
+// subset of gitignore syntax, relative to the directory containing them.
+const ignoreFile = ".wasmstubignore"
+
+// An ignoreRule is a pattern of an ignore file.
+type ignoreRule struct {
+	pattern  string // slash-separated, without leading or trailing slash
+	negate   bool   // "!pattern": re-include matching paths
+	dirOnly  bool   // "pattern/": only match directories
+	anchored bool   // pattern contains a slash: match from the ignore file's directory
+}
+
+// parseIgnore parses the content of an ignore file. It supports comments,
+// negation, trailing slashes for directories, leading slashes and "**".
+func parseIgnore(data string) []ignoreRule {
+	var rules []ignoreRule
+	sc := bufio.NewScanner(strings.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var r ignoreRule
+		if rest, ok := strings.CutPrefix(line, "!"); ok {
+			r.negate = true
+			line = rest
+		}
+		line = strings.TrimPrefix(line, `\`)
+		if rest, ok := strings.CutSuffix(line, "/"); ok {
+			r.dirOnly = true
+			line = rest
+		}
+		r.anchored = strings.Contains(line, "/")
+		r.pattern = strings.TrimPrefix(line, "/")
+		if r.pattern != "" {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// match reports whether r matches rel, a slash-separated path relative to
+// the directory of the ignore file.
+func (r ignoreRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if !r.anchored {
+		return matchSegments([]string{r.pattern}, []string{path.Base(rel)})
+	}
+	return matchSegments(strings.Split(r.pattern, "/"), strings.Split(rel, "/"))
+}
+
+// matchSegments matches path segments against pattern segments, where "**"
+// matches any number of segments and the others are path.Match patterns.
+func matchSegments(pattern, segs []string) bool {
+	if len(pattern) == 0 {
+		return len(segs) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segs); i++ {
+			if matchSegments(pattern[1:], segs[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segs) == 0 {
+		return false
+	}
+	ok, err := path.Match(pattern[0], segs[0])
+	return err == nil && ok && matchSegments(pattern[1:], segs[1:])
+}
+
+// ignorer applies the ignore files found while walking a tree.
+type ignorer struct {
+	rules map[string][]ignoreRule // by directory
+
+	// root and extra are the root of the tree and the rules applying
+	// there before its ignore file, from Options.Exclude.
+	root  string
+	extra []ignoreRule
+}
+
+// load reads the ignore file of dir, if any.
+func (ig *ignorer) load(dir string) error {
+	var rules []ignoreRule
+	if dir == ig.root {
+		rules = ig.extra
+	}
+	data, err := os.ReadFile(filepath.Join(dir, ignoreFile))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	rules = append(rules, parseIgnore(string(data))...)
+	if len(rules) == 0 {
+		return nil
+	}
+	if ig.rules == nil {
+		ig.rules = make(map[string][]ignoreRule)
+	}
+	ig.rules[dir] = rules
+	return nil
+}
+
+// ignored reports whether the ignore files of the directories containing p
+// exclude it. The last matching rule wins, and rules of deeper directories
+// come later.
+func (ig *ignorer) ignored(p string, isDir bool) bool {
+	var dirs []string
+	for dir := filepath.Dir(p); ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if parent := filepath.Dir(dir); parent == dir {
+			break
+		}
+	}
+	ignored := false
+	for i := len(dirs) - 1; i >= 0; i-- {
+		rel, err := filepath.Rel(dirs[i], p)
+		if err != nil {
+			continue
+		}
+		for _, r := range ig.rules[dirs[i]] {
+			if r.match(filepath.ToSlash(rel), isDir) {
+				ignored = !r.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// walkGoFiles calls fn for every Go file under root in lexical order,
+// skipping the files and directories excluded by ignore files.
+func walkGoFiles(root string, fn func(path string, d fs.DirEntry) error) error {
+	return walkGoFilesExcluding(root, nil, fn)
+}
+
+// walkGoFilesExcluding is walkGoFiles, applying exclude as if it came first
+// in the ignore file of root.
+func walkGoFilesExcluding(root string, exclude []ignoreRule, fn func(path string, d fs.DirEntry) error) error {
+	ig := ignorer{root: root, extra: exclude}
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != root && ig.ignored(path, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return ig.load(path)
+		}
+		if !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+		return fn(path, d)
+	})
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestWalkGoFilesIgnore(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{
+		"a.go",
+		"a_test.go",
+		"gen/b.go",
+		"gen/keep.go",
+		"gen/deep/c.go",
+		"internal/d.go",
+		"internal/x/e.go",
+		"vendor/f.go",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, path, "package p\n")
+	}
+	writeFile(t, filepath.Join(root, ignoreFile), "# top level\n*_test.go\n/vendor/\ninternal/**/e.go\n")
+	writeFile(t, filepath.Join(root, "gen", ignoreFile), "*.go\n!keep.go\n")
+
+	var got []string
+	err := walkGoFiles(root, func(path string, _ fs.DirEntry) error {
+		rel, _ := filepath.Rel(root, path)
+		got = append(got, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a.go", "gen/keep.go", "internal/d.go"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"regexp"
+	"slices"
+)
+
+var majorVersion = regexp.MustCompile(`^v[0-9]+$`)
+
+// importName returns the name under which spec is referenced in a file. For
+// imports without an explicit name, it is assumed to be the last element of
+// the path, ignoring a major version suffix.
+func importName(spec *ast.ImportSpec) string {
+	if spec.Name != nil {
+		return spec.Name.Name
+	}
+	p := importPath(spec)
+	name := path.Base(p)
+	if majorVersion.MatchString(name) && path.Dir(p) != "." {
+		name = path.Base(path.Dir(p))
+	}
+	return name
+}
+
+func importPath(spec *ast.ImportSpec) string {
+	p := spec.Path.Value
+	return p[1 : len(p)-1]
+}
+
+// unusedImports returns the paths of the imports of f whose package name is
+// not referenced by any selector. Blank and dot imports are never reported.
+func unusedImports(f *ast.File) []string {
+	used := make(map[string]bool)
+	ast.Inspect(f, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok {
+				used[x.Name] = true
+			}
+		}
+		return true
+	})
+	var unused []string
+	for _, spec := range f.Imports {
+		name := importName(spec)
+		if name == "_" || name == "." || used[name] {
+			continue
+		}
+		unused = append(unused, importPath(spec))
+	}
+	return unused
+}
+
+// newlyUnusedImports returns the imports that are used in src but not in
+// modified. It returns nil if either does not parse.
+func newlyUnusedImports(src, modified []byte) []string {
+	before, err := parser.ParseFile(token.NewFileSet(), "", src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil
+	}
+	after, err := parser.ParseFile(token.NewFileSet(), "", modified, parser.SkipObjectResolution)
+	if err != nil {
+		return nil
+	}
+	wasUnused := unusedImports(before)
+	var unused []string
+	for _, p := range unusedImports(after) {
+		if !slices.Contains(wasUnused, p) {
+			unused = append(unused, p)
+		}
+	}
+	return unused
+}
+
+// pruneImports removes the lines of the import specs of src with one of paths.
+// A single unparenthesized import is removed with its declaration.
+func pruneImports(src []byte, paths []string) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ImportsOnly|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	drop := make(map[int]bool)
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.IMPORT {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			if !slices.Contains(paths, importPath(spec.(*ast.ImportSpec))) {
+				continue
+			}
+			var from, to token.Pos = spec.Pos(), spec.End()
+			if !gd.Lparen.IsValid() {
+				from, to = gd.Pos(), gd.End()
+			}
+			for l := fset.Position(from).Line; l <= fset.Position(to).Line; l++ {
+				drop[l-1] = true
+			}
+		}
+	}
+	var out []byte
+	for i, line := range splitLines(src) {
+		if !drop[i] {
+			out = append(out, line...)
+		}
+	}
+	return out, nil
+}
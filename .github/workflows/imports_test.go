@@ -0,0 +1,55 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestUnusedImportsReported(t *testing.T) {
+	const src = `package p
+
+import "unsafe"
+
+import (
+	"math/rand/v2"
+	"os"
+)
+
+func f(p *byte) {
+	Syscall(1, uintptr(unsafe.Pointer(p)), 0)
+	rand.Int()
+}
+
+func g() { os.Exit(1) }
+`
+	res, err := Stub("p.go", []byte(src), &Options{Mode: ModeFuncBody})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"unsafe", "math/rand/v2"}; !slices.Equal(res.UnusedImports, want) {
+		t.Errorf("UnusedImports = %q, want %q", res.UnusedImports, want)
+	}
+	if !strings.Contains(string(res.Output), `import "unsafe"`) {
+		t.Errorf("import pruned without PruneImports:\n%s", res.Output)
+	}
+
+	res, err = Stub("p.go", []byte(src), &Options{Mode: ModeFuncBody, PruneImports: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(res.Output), "unsafe") || strings.Contains(string(res.Output), "rand") {
+		t.Errorf("imports not pruned:\n%s", res.Output)
+	}
+}
+
+func TestInsertModeKeepsImports(t *testing.T) {
+	const src = "package p\n\nimport \"unsafe\"\n\nfunc f(p *byte) {\n\tSyscall(1, uintptr(unsafe.Pointer(p)), 0)\n}\n"
+	res, err := Stub("p.go", []byte(src), &Options{PruneImports: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.UnusedImports) != 0 {
+		t.Errorf("UnusedImports = %q, want none", res.UnusedImports)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// logLevel is the severity of a log message.
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+var levelNames = [...]string{
+	levelDebug: "debug",
+	levelInfo:  "info",
+	levelWarn:  "warn",
+	levelError: "error",
+}
+
+// ANSI color codes used for each level's label.
+var levelColors = [...]string{
+	levelDebug: "\x1b[90m",
+	levelInfo:  "\x1b[32m",
+	levelWarn:  "\x1b[33m",
+	levelError: "\x1b[31m",
+}
+
+func (l logLevel) String() string {
+	if l < 0 || int(l) >= len(levelNames) {
+		return fmt.Sprintf("logLevel(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+func parseLogLevel(s string) (logLevel, error) {
+	for l, name := range levelNames {
+		if s == name {
+			return logLevel(l), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q (want debug, info, warn or error)", s)
+}
+
+// leveledLogger writes messages at or above a minimum level, optionally
+// colorizing the level label.
+type leveledLogger struct {
+	w     io.Writer
+	level logLevel
+	color bool
+}
+
+var logger = &leveledLogger{w: os.Stderr, level: levelInfo}
+
+func (l *leveledLogger) logf(level logLevel, format string, args ...any) {
+	if level < l.level {
+		return
+	}
+	label := level.String()
+	if l.color {
+		label = levelColors[level] + label + "\x1b[0m"
+	}
+	fmt.Fprintf(l.w, "%s: %s\n", label, fmt.Sprintf(format, args...))
+}
+
+func (l *leveledLogger) debugf(format string, args ...any) { l.logf(levelDebug, format, args...) }
+func (l *leveledLogger) infof(format string, args ...any)  { l.logf(levelInfo, format, args...) }
+func (l *leveledLogger) warnf(format string, args ...any)  { l.logf(levelWarn, format, args...) }
+func (l *leveledLogger) errorf(format string, args ...any) { l.logf(levelError, format, args...) }
+
+// isTerminal reports whether f refers to a character device such as a
+// terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	for _, name := range []string{"debug", "info", "warn", "error"} {
+		l, err := parseLogLevel(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l.String() != name {
+			t.Errorf("parseLogLevel(%q) = %v", name, l)
+		}
+	}
+	if _, err := parseLogLevel("verbose"); err == nil {
+		t.Error("parseLogLevel(verbose) succeeded")
+	}
+}
+
+func TestLeveledLogger(t *testing.T) {
+	var b strings.Builder
+	l := &leveledLogger{w: &b, level: levelWarn}
+	l.debugf("d")
+	l.infof("i")
+	l.warnf("w %d", 1)
+	l.errorf("e")
+	if got, want := b.String(), "warn: w 1\nerror: e\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	b.Reset()
+	l.color = true
+	l.errorf("e")
+	if got, want := b.String(), "\x1b[31merror\x1b[0m: e\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
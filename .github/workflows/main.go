@@ -1,174 +1,311 @@
+// Command wasmstub inserts a panic before every raw syscall call in a tree
+// of Go files, so that packages such as x/sys/unix compile for wasm and fail
+// loudly, rather than silently, when a syscall is reached.
+//
+// Usage:
+//
+//	go run . <command> [flags] <directory>...
+//
+// The commands are:
+//
+//	stub          insert the panics
+//	undo          remove the panics inserted by stub
+//	check         list the files that still need stubbing
+//	audit         count the syscall sites of each file
+//	report        write a report of the sites stub would change
+//	dump-ast      print the AST of a file, marking the calls the matcher considers
+//	capabilities  list the statement contexts in which calls are stubbed
+//
+// Run "go run . help <command>" for the flags of a command. Default flags
+// can be set in the WASMSTUB_OPTS environment variable, split into words
+// like a shell would; flags on the command line override them, and those
+// that a command does not define are ignored.
+//
+// Running without a command, as in "go run . [flags] <directory>", is
+// deprecated and equivalent to stub.
+//
+// The exit status is one of:
+//
+//	0  success
+//	1  usage error, such as an unknown flag or a missing directory
+//	2  with check or stub -check, some files still need stubbing
+//	3  a file could not be read, parsed or written
+//
+// Previews made with stub -dry-run or -diff exit with status 0 whatever the
+// changes they find; status 2 is reserved for check and stub -check, which
+// exist to fail CI. stub -exit-zero, which requires -dry-run or -diff,
+// makes sure a preview exits with status 0 whatever the changes pending;
+// failures still exit with status 3. It is rejected with -check, whose exit
+// status is its result.
 package main
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
-	"go/ast"
-	"go/format"
-	"go/parser"
-	"go/token"
+	"io"
+	"io/fs"
+	"maps"
 	"os"
-	"path/filepath"
+	"os/exec"
+	"slices"
 	"strings"
+	"time"
+)
+
+// watchDebounce is how long a file must stay unchanged before -watch
+// processes it, so that it is not read halfway through being written.
+const watchDebounce = 300 * time.Millisecond
+
+// Exit codes of the command. See the package documentation.
+const (
+	exitOK          = 0
+	exitUsage       = 1
+	exitNeedsChange = 2
+	exitFailure     = 3
 )
 
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: go run main.go <directory>")
-		os.Exit(1)
-	}
+	os.Exit(run(os.Args[1:]))
+}
 
-	dir := os.Args[1]
-	if err := processDirectory(dir); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
-	}
+// stats accumulates results across the files of a run.
+type stats struct {
+	files    int // files with at least one stubbed site
+	changed  int // files whose content was or would be changed
+	stale    int // files with a stale annotation
+	sites    int
+	perFunc  map[string]int // stubbed sites per called function
+	internal int
+	tests    int // sites stubbed in _test.go files
+	skipTest int // sites skipped because of Options.SkipTests
+	partial  int // files processed despite parse errors
+	deduped  int // redundant panics removed
+	init     int // sites in init functions handled by Options.InitPolicy
+	excluded int // files not built for Options.GOOS and GOARCH
+	notGofmt int
 }
 
-func processDirectory(dir string) error {
-	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+func (st *stats) add(res *Result) {
+	if len(res.Sites) > 0 {
+		st.files++
+	}
+	if res.Changed {
+		st.changed++
+	}
+	if res.StaleAnnotation {
+		st.stale++
+	}
+	st.sites += len(res.Sites)
+	for _, site := range res.Sites {
+		if st.perFunc == nil {
+			st.perFunc = make(map[string]int)
+		}
+		st.perFunc[site.Func]++
+		if site.Test {
+			st.tests++
 		}
+	}
+	st.internal += res.Internal
+	st.skipTest += res.Tests
+	st.deduped += res.Deduped
+	st.init += res.Init
+	if res.Excluded {
+		st.excluded++
+	}
+	if res.ParseErr != nil {
+		st.partial++
+	}
+	if res.NotGofmt {
+		st.notGofmt++
+	}
+}
+
+// runner processes files as configured on the command line.
+type runner struct {
+	opts        *Options
+	check       bool   // list files needing changes instead of writing them
+	diff        bool   // print diffs instead of writing files
+	diffContext int    // context lines per diff hunk
+	dryRun      bool   // process files without writing or listing them
+	maxSize     int64  // if not 0, files larger than this are skipped
+	postHook    string // if not empty, shell command run on each written file
+}
 
-		if !info.IsDir() && strings.HasSuffix(path, ".go") {
-			if err := processFile(path); err != nil {
-				return fmt.Errorf("processing %s: %w", path, err)
+func (r *runner) processDirectory(dir string) error {
+	return walkGoFilesExcluding(dir, r.opts.excludeRules(), func(path string, _ fs.DirEntry) error {
+		if _, err := r.processFile(path); err != nil {
+			var tooLarge *TooLargeError
+			if errors.As(err, &tooLarge) {
+				logger.warnf("skipped %v", err)
+				return nil
 			}
-			fmt.Printf("Processed: %s\n", path)
+			return fmt.Errorf("processing %s: %w", path, err)
 		}
-
 		return nil
 	})
 }
 
-func processFile(filename string) error {
-	fset := token.NewFileSet()
-	content, err := os.ReadFile(filename)
-	if err != nil {
-		return err
-	}
+// processFile stubs filename, notifying the observer of r.opts.
+func (r *runner) processFile(filename string) (*Result, error) {
+	obs := r.opts.observer()
+	obs.FileStarted(filename)
+	res, err := r.stubFile(filename)
+	obs.FileDone(filename, res, err)
+	return res, err
+}
 
-	node, err := parser.ParseFile(fset, filename, content, parser.ParseComments)
+func (r *runner) stubFile(filename string) (*Result, error) {
+	if r.maxSize > 0 {
+		info, err := os.Stat(filename)
+		if err != nil {
+			return nil, err
+		}
+		if info.Size() > r.maxSize {
+			return nil, &TooLargeError{Path: filename, Size: info.Size(), Max: r.maxSize}
+		}
+	}
+	content, err := os.ReadFile(filename)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	syscallFuncs := map[string]bool{
-		"Syscall":           true,
-		"Syscall6":          true,
-		"RawSyscall":        true,
-		"RawSyscall6":       true,
-		"SyscallNoError":    true,
-		"RawSyscallNoError": true,
-	}
-
-	type stmtInfo struct {
-		pos      token.Pos
-		call     *ast.CallExpr
-		funcName string
-	}
-	var stmts []stmtInfo
-
-	ast.Inspect(node, func(n ast.Node) bool {
-		switch stmt := n.(type) {
-		case *ast.ExprStmt:
-			// Handle direct calls like: SyscallNoError(...)
-			if call, ok := stmt.X.(*ast.CallExpr); ok {
-				if ident, ok := call.Fun.(*ast.Ident); ok {
-					if syscallFuncs[ident.Name] {
-						stmts = append(stmts, stmtInfo{
-							pos:      stmt.Pos(),
-							call:     call,
-							funcName: ident.Name,
-						})
-					}
-				}
-			}
-		case *ast.AssignStmt:
-			// Handle assignments like: _, _, e1 := Syscall6(...)
-			for _, expr := range stmt.Rhs {
-				if call, ok := expr.(*ast.CallExpr); ok {
-					if ident, ok := call.Fun.(*ast.Ident); ok {
-						if syscallFuncs[ident.Name] {
-							stmts = append(stmts, stmtInfo{
-								pos:      stmt.Pos(),
-								call:     call,
-								funcName: ident.Name,
-							})
-						}
-					}
-				}
-			}
+	return r.stub(filename, content, func(out []byte) error {
+		if err := writeFileAtomic(filename, out); err != nil {
+			return &WriteError{Path: filename, Err: err}
 		}
-		return true
+		if r.postHook != "" {
+			return runHook(r.postHook, filename)
+		}
+		return nil
 	})
+}
 
-	if len(stmts) == 0 {
-		return nil
+// runHook runs the shell command cmd with path as its last argument, so
+// that "git add" runs "git add path". Its output goes to ours.
+func runHook(cmd, path string) error {
+	c := exec.Command("/bin/sh", "-c", cmd+` "$@"`, "sh", path)
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		return &HookError{Path: path, Cmd: cmd, Err: err}
 	}
+	return nil
+}
 
-	lines := bytes.Split(content, []byte("\n"))
-	offset := 0
-
-	for _, stmt := range stmts {
-		pos := fset.Position(stmt.pos)
-		lineIdx := pos.Line - 1 + offset
-
-		if lineIdx < 0 || lineIdx >= len(lines) {
-			continue
-		}
-
-		if lineIdx > 0 && bytes.Contains(lines[lineIdx-1], []byte("panic(\"syscall not supported in wasm:")) {
-			continue
-		}
-
-		line := lines[lineIdx]
-		indent := getIndentBytes(line)
-
-		callText := extractCallFromAST(stmt.call, fset, content)
-
-		panicLine := append(indent, []byte(fmt.Sprintf("panic(\"syscall not supported in wasm: %s\")", callText))...)
+// processStdin stubs the source read from in as a file named name and
+// writes the result to out, copying the source if it needs no change. With
+// check, diff or dryRun, nothing is written to out, as for files.
+func (r *runner) processStdin(in io.Reader, out io.Writer, name string) (*Result, error) {
+	obs := r.opts.observer()
+	obs.FileStarted(name)
+	res, err := r.readStdin(in, out, name)
+	obs.FileDone(name, res, err)
+	return res, err
+}
 
-		lines = insertLineBytes(lines, lineIdx, panicLine)
-		offset++
+func (r *runner) readStdin(in io.Reader, out io.Writer, name string) (*Result, error) {
+	content, err := io.ReadAll(in)
+	if err != nil {
+		return nil, err
 	}
-
-	modified := bytes.Join(lines, []byte("\n"))
-	formatted, err := format.Source(modified)
+	write := func(b []byte) error {
+		_, err := out.Write(b)
+		return err
+	}
+	res, err := r.stub(name, content, write)
 	if err != nil {
-		fmt.Printf("Warning: could not format %s: %v\n", filename, err)
-		return os.WriteFile(filename, modified, 0644)
+		return nil, err
 	}
-
-	return os.WriteFile(filename, formatted, 0644)
+	if !res.Changed && !r.check && !r.diff && !r.dryRun {
+		return res, write(content)
+	}
+	return res, nil
 }
 
-func extractCallFromAST(call *ast.CallExpr, fset *token.FileSet, content []byte) string {
-	start := fset.Position(call.Pos()).Offset
-	end := fset.Position(call.End()).Offset
+// stub stubs content, read from filename. Depending on r, it then lists
+// filename, prints a diff or passes the changed output to write.
+func (r *runner) stub(filename string, content []byte, write func([]byte) error) (*Result, error) {
+	res, err := Stub(filename, content, r.opts)
+	if err != nil {
+		return nil, err
+	}
+	if r.check && res.StaleAnnotation && !res.Changed {
+		// Partly regenerated upstream, with its stubs otherwise intact.
+		fmt.Println(filename)
+		return res, nil
+	}
+	if !res.Changed {
+		return res, nil
+	}
 
-	if start >= 0 && end <= len(content) && start < end {
-		return string(content[start:end])
+	if r.check {
+		fmt.Println(filename)
+	}
+	if r.diff {
+		os.Stdout.Write(unifiedDiff(filename+".orig", filename, content, res.Output, r.diffContext))
+		if res.Variant != nil {
+			os.Stdout.Write(unifiedDiff("/dev/null", res.VariantPath, nil, res.Variant, r.diffContext))
+		}
 	}
+	if r.check || r.diff || r.dryRun {
+		return res, nil
+	}
+	if res.Variant != nil {
+		// The variant goes first: the original alone, excluding js,
+		// would leave js builds without the code of the file.
+		if err := writeFileAtomic(res.VariantPath, res.Variant); err != nil {
+			return res, &WriteError{Path: res.VariantPath, Err: err}
+		}
+		if r.postHook != "" {
+			if err := runHook(r.postHook, res.VariantPath); err != nil {
+				return res, err
+			}
+		}
+	}
+	return res, write(res.Output)
+}
 
-	// Fallback (shouldn't happen)
-	return "syscall"
+// countDirectory prints the number of syscall sites in each Go file under
+// dir that has any and returns the total. Files are only parsed, never
+// spliced, formatted or written. With Options.Unmatched, the unmatched calls
+// are logged and counted in unmatched.
+func countDirectory(dir string, opts *Options, unmatched map[string]int) (int, error) {
+	total := 0
+	err := walkGoFilesExcluding(dir, opts.excludeRules(), func(path string, _ fs.DirEntry) error {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var res Result
+		sites, err := findSites(path, content, opts, &res)
+		if err != nil {
+			return fmt.Errorf("processing %s: %w", path, err)
+		}
+		logUnmatched(res.Unmatched, unmatched)
+		if len(sites) > 0 {
+			fmt.Printf("%s: %d\n", path, len(sites))
+		}
+		total += len(sites)
+		return nil
+	})
+	return total, err
 }
 
-func getIndentBytes(line []byte) []byte {
-	for i := 0; i < len(line); i++ {
-		if line[i] != ' ' && line[i] != '\t' {
-			return line[:i]
+// splitList splits a comma-separated flag value, ignoring empty elements.
+func splitList(s string) []string {
+	var list []string
+	for _, elem := range strings.Split(s, ",") {
+		if elem = strings.TrimSpace(elem); elem != "" {
+			list = append(list, elem)
 		}
 	}
-	return []byte{}
+	return list
 }
 
-func insertLineBytes(lines [][]byte, index int, newLine []byte) [][]byte {
-	result := make([][]byte, 0, len(lines)+1)
-	result = append(result, lines[:index]...)
-	result = append(result, newLine)
-	result = append(result, lines[index:]...)
-	return result
+// formatCounts formats counts as "name=n" pairs sorted by name.
+func formatCounts(counts map[string]int) string {
+	var pairs []string
+	for _, name := range slices.Sorted(maps.Keys(counts)) {
+		pairs = append(pairs, fmt.Sprintf("%s=%d", name, counts[name]))
+	}
+	return strings.Join(pairs, ", ")
 }
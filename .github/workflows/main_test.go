@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func writeTemp(t *testing.T, name, src string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	writeFile(t, path, src)
+	return path
+}
+
+func writeFile(t *testing.T, path, src string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// TestFallbackIndent checks the indentation of inserted statements when the
+// spliced output cannot be formatted and is written unformatted.
+func TestFallbackIndent(t *testing.T) {
+	const src = `package p
+
+func g() {
+	if true {
+		r0, _, _ := Syscall6(1, 2, 3, 4, 5, 6, 7)
+		_ = r0
+	}
+	RawSyscall(1, 2, 3)
+}
+`
+	const want = `package p
+
+func g() {
+	if true {
+		unbalanced(Syscall6
+		r0, _, _ := Syscall6(1, 2, 3, 4, 5, 6, 7)
+		_ = r0
+	}
+	unbalanced(RawSyscall
+	RawSyscall(1, 2, 3)
+}
+`
+	path := writeTemp(t, "fallback.go", src)
+	r := &runner{opts: &Options{InsertFunc: unbalancedInsert}}
+	if _, err := r.processFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, path); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// unbalancedInsert is an InsertFunc whose statements do not parse, so that
+// the stubbed output cannot be formatted.
+func unbalancedInsert(site Site) (string, error) {
+	return "unbalanced(" + site.Func, nil
+}
+
+func TestCheck(t *testing.T) {
+	const src = "package p\n\nfunc f() {\n\tSyscall(1, 2, 3)\n}\n"
+	path := writeTemp(t, "p.go", src)
+
+	obs := &cliObserver{}
+	r := &runner{opts: &Options{Observer: obs}, check: true}
+	if err := r.processDirectory(filepath.Dir(path)); err != nil {
+		t.Fatal(err)
+	}
+	if obs.changed != 1 {
+		t.Errorf("check: changed = %d, want 1", obs.changed)
+	}
+	if got := readFile(t, path); got != src {
+		t.Errorf("check modified the file:\n%s", got)
+	}
+
+	if err := new(runner).processDirectory(filepath.Dir(path)); err != nil {
+		t.Fatal(err)
+	}
+	obs = &cliObserver{}
+	r = &runner{opts: &Options{Observer: obs}, check: true}
+	if err := r.processDirectory(filepath.Dir(path)); err != nil {
+		t.Fatal(err)
+	}
+	if obs.changed != 0 {
+		t.Errorf("check after stubbing: changed = %d, want 0", obs.changed)
+	}
+}
+
+// TestPreviewsDoNotWrite checks that -diff and -dry-run leave files alone,
+// including those whose stubbed output cannot be formatted and would be
+// written unformatted.
+func TestPreviewsDoNotWrite(t *testing.T) {
+	const src = "package p\n\nfunc f() {\n\tSyscall(1, 2, 3)\n}\n"
+	for _, r := range []*runner{{diff: true}, {dryRun: true}} {
+		path := writeTemp(t, "p.go", src)
+		r.opts = &Options{InsertFunc: unbalancedInsert}
+		res, err := r.processFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.FormatErr == nil {
+			t.Fatal("unbalancedInsert output was formatted")
+		}
+		if got := readFile(t, path); got != src {
+			t.Errorf("diff=%v dryRun=%v modified the file:\n%s", r.diff, r.dryRun, got)
+		}
+	}
+}
+
+func TestFormatCounts(t *testing.T) {
+	got := formatCounts(map[string]int{"Syscall6": 2, "RawSyscall": 1, "Syscall": 10})
+	if want := "RawSyscall=1, Syscall=10, Syscall6=2"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := splitList(" Syscall6, ,RawSyscall6 "); !slices.Equal(got, []string{"Syscall6", "RawSyscall6"}) {
+		t.Errorf("splitList = %q", got)
+	}
+}
+
+func TestMaxFileSize(t *testing.T) {
+	const src = "package p\n\nfunc f() {\n\tSyscall(1, 2, 3)\n}\n"
+	path := writeTemp(t, "p.go", src)
+	obs := &cliObserver{}
+	r := &runner{opts: &Options{Observer: obs}, maxSize: int64(len(src) - 1)}
+	if err := r.processDirectory(filepath.Dir(path)); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, path); got != src || obs.tooLarge != 1 {
+		t.Errorf("got %d files too large and content:\n%s\nwant the file skipped", obs.tooLarge, got)
+	}
+
+	r.maxSize = int64(len(src))
+	if err := r.processDirectory(filepath.Dir(path)); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, path); got == src {
+		t.Error("a file of the maximum size was not stubbed")
+	}
+}
+
+func TestPostHook(t *testing.T) {
+	const src = "package p\n\nfunc f() {\n\tSyscall(1, 2, 3)\n}\n"
+	path := writeTemp(t, "p.go", src)
+	hooked := filepath.Join(t.TempDir(), "hooked")
+	r := &runner{postHook: "touch " + hooked, opts: &Options{}}
+	if _, err := r.processFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(hooked); err != nil {
+		t.Errorf("hook did not run: %v", err)
+	}
+
+	// The hook only runs for written files.
+	r.postHook = "false"
+	if _, err := r.processFile(path); err != nil {
+		t.Errorf("hook ran on an unchanged file: %v", err)
+	}
+
+	writeFile(t, path, src)
+	_, err := r.processFile(path)
+	var hookErr *HookError
+	if !errors.As(err, &hookErr) || hookErr.Path != path {
+		t.Errorf("processFile error = %v, want *HookError for %s", err, path)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// version is the version of the tool recorded in manifests.
+const version = "v0.1.0"
+
+// manifest records what a run produced, so that CI can check that a tree was
+// fully stubbed by a given version of the tool with given options.
+type manifest struct {
+	Version string            `json:"version"`
+	Options map[string]string `json:"options"` // flags set on the command line
+	Files   []manifestEntry   `json:"files"`   // sorted by path
+}
+
+type manifestEntry struct {
+	Path   string `json:"path"` // slash-separated, relative to the root
+	SHA256 string `json:"sha256"`
+}
+
+// setFlags returns the flags of fs that were set, mapped to their values.
+func setFlags(fs *flag.FlagSet) map[string]string {
+	opts := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		opts[f.Name] = f.Value.String()
+	})
+	return opts
+}
+
+// writeManifest writes to file a manifest of the given files under root,
+// hashing their current content.
+func writeManifest(file, root string, paths []string, options map[string]string) error {
+	m := manifest{Version: version, Options: options, Files: []manifestEntry{}}
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(content)
+		m.Files = append(m.Files, manifestEntry{
+			Path:   filepath.ToSlash(rel),
+			SHA256: hex.EncodeToString(sum[:]),
+		})
+	}
+	slices.SortFunc(m.Files, func(a, b manifestEntry) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	data, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(file, append(data, '\n'))
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	a := filepath.Join(dir, "sub", "a.go")
+	b := filepath.Join(dir, "b.go")
+	writeFile(t, a, "package a\n")
+	writeFile(t, b, "package b\n")
+
+	fs := flag.NewFlagSet("wasmstub", flag.ContinueOnError)
+	fs.Bool("check", false, "")
+	fs.Bool("windows", false, "")
+	if err := fs.Parse([]string{"-windows"}); err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(t.TempDir(), "manifest.json")
+	if err := writeManifest(file, dir, []string{a, b}, setFlags(fs)); err != nil {
+		t.Fatal(err)
+	}
+	var got manifest
+	if err := json.Unmarshal([]byte(readFile(t, file)), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := manifest{
+		Version: version,
+		Options: map[string]string{"windows": "true"},
+		Files: []manifestEntry{
+			{"b.go", "983aab874348ab0e62d9fa51e0719b12f570234284c1f21c740bb6d3ca7cf11d"},
+			{"sub/a.go", "7b39baa38a2ec2b8d111bbbd8e448e80226477ab40105d9d2123d4dc18067438"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got manifest %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"slices"
+	"strings"
+)
+
+// A Mode selects how the sites of a file are stubbed.
+type Mode string
+
+const (
+	// ModeInsert inserts a statement before each site, as returned by
+	// Options.InsertFunc or the matching Rule.
+	ModeInsert Mode = "insert"
+
+	// ModeFuncBody replaces the whole body of every function declaration
+	// containing a site with a panic.
+	ModeFuncBody Mode = "funcbody"
+
+	// ModeShim inserts a statement before each site like ModeInsert, but
+	// first offers the syscall to a shim package. See shimInsert.
+	ModeShim Mode = "shim"
+
+	// ModeNop replaces the function of each syscall call with a function
+	// literal returning zeros and ENOSYS, so that the call does nothing
+	// and reports that it is not implemented. See nopFuncLit.
+	ModeNop Mode = "nop"
+
+	// ModeEntry inserts a single panic naming the function as the first
+	// statement of every function declaration containing a site, so that
+	// nothing in the function runs, not even the evaluation of the
+	// syscall arguments.
+	ModeEntry Mode = "entry"
+
+	// ModeErrno sets the errno of the wrappers in the classic shape found
+	// by errnoRewrites to ENOSYS instead of calling the syscall, so that
+	// their error handling runs. Before the other sites of functions with
+	// an error or Errno result, it inserts a return of ENOSYS, as the
+	// enosys action of a Rule does, so that callers can fall back, say to
+	// cooked mode when Tcgetattr fails. Only the sites of the others, such
+	// as Getpid, and those in function literals get a statement inserted
+	// like ModeInsert. Custom InsertFuncs and Rules take precedence.
+	ModeErrno Mode = "errno"
+)
+
+var modes = map[Mode]bool{
+	ModeInsert:   true,
+	ModeFuncBody: true,
+	ModeShim:     true,
+	ModeNop:      true,
+	ModeEntry:    true,
+	ModeErrno:    true,
+}
+
+// parseMode returns the Mode named s.
+func parseMode(s string) (Mode, error) {
+	if m := Mode(s); modes[m] {
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown mode %q (want insert, funcbody, shim, nop, entry or errno)", s)
+}
+
+// An InitPolicy selects how the sites in package init functions, which run
+// as soon as the package is loaded, are handled.
+type InitPolicy string
+
+const (
+	// InitPanic stubs them like the other sites, so that a program
+	// importing the package fails at load if they are reached.
+	InitPanic InitPolicy = "panic"
+
+	// InitSkip leaves them alone.
+	InitSkip InitPolicy = "skip"
+
+	// InitLazy inserts a return from init before them instead of a
+	// panic, so that the package loads with the rest of its
+	// initialization undone and fails later, where it is used. It only
+	// applies where the mode inserts a statement: with ModeInsert,
+	// ModeShim and ModeErrno.
+	InitLazy InitPolicy = "lazy"
+)
+
+// parseInitPolicy returns the InitPolicy named s.
+func parseInitPolicy(s string) (InitPolicy, error) {
+	switch p := InitPolicy(s); p {
+	case InitPanic, InitSkip, InitLazy:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown init policy %q (want panic, skip or lazy)", s)
+}
+
+// lazyPrefix starts the statements inserted by InitLazy.
+const lazyPrefix = "return // syscall not supported in wasm: "
+
+// lazyInsert returns the statement inserted by InitLazy before site.
+func lazyInsert(site Site) string {
+	return lazyPrefix + joinLines(site.Call)
+}
+
+// replaceFuncBodies returns src with the body of every function declaration
+// containing one of sites replaced by a panic naming the function.
+func replaceFuncBodies(filename string, src []byte, sites []Site) ([]byte, error) {
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, filename, src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	type body struct {
+		start, end int // offsets of the braces
+		name       string
+	}
+	var bodies []body
+	for _, decl := range node.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if !ok || fd.Body == nil {
+			continue
+		}
+		b := body{
+			start: fset.Position(fd.Body.Lbrace).Offset,
+			end:   fset.Position(fd.Body.Rbrace).Offset,
+			name:  fd.Name.Name,
+		}
+		if slices.ContainsFunc(sites, func(s Site) bool { return b.start <= s.Pos.Offset && s.Pos.Offset < b.end }) {
+			bodies = append(bodies, b)
+		}
+	}
+
+	var out []byte
+	last := 0
+	for _, b := range bodies {
+		out = append(out, src[last:b.start]...)
+		out = fmt.Appendf(out, "{\n\tpanic(\"syscall not supported in wasm: %s\")\n}", b.name)
+		last = b.end + 1
+	}
+	return append(out, src[last:]...), nil
+}
+
+// An entryBody is the body of a function declaration stubbed by ModeEntry.
+type entryBody struct {
+	lbrace, rbrace int // offsets of the braces
+	name           string
+	stubbed        bool // whether the body already starts with the panic
+}
+
+// entryBodies returns the bodies of the function declarations in src.
+func entryBodies(filename string, src []byte) ([]entryBody, error) {
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, filename, src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	var bodies []entryBody
+	for _, decl := range node.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if !ok || fd.Body == nil {
+			continue
+		}
+		b := entryBody{
+			lbrace: fset.Position(fd.Body.Lbrace).Offset,
+			rbrace: fset.Position(fd.Body.Rbrace).Offset,
+			// The panic of a function renamed by
+			// Options.RenameStubbedFuncs names it as it was before.
+			name: strings.TrimSuffix(fd.Name.Name, renameSuffix),
+		}
+		if len(fd.Body.List) > 0 {
+			first := src[fset.Position(fd.Body.List[0].Pos()).Offset:]
+			b.stubbed = bytes.HasPrefix(first, []byte(entryPanic(b.name)))
+		}
+		bodies = append(bodies, b)
+	}
+	return bodies, nil
+}
+
+// bodyAt returns the body of bodies containing offset, or nil.
+func bodyAt(bodies []entryBody, offset int) *entryBody {
+	for i, b := range bodies {
+		if b.lbrace < offset && offset < b.rbrace {
+			return &bodies[i]
+		}
+	}
+	return nil
+}
+
+// entryPanic returns the statement inserted by ModeEntry in function name.
+func entryPanic(name string) string {
+	return fmt.Sprintf("%s%s\")", stubPrefix, name)
+}
+
+// nopResults holds the function literals replacing raw syscall functions
+// with ModeNop, keyed by the replaced function. The literals have the
+// signature of the function, returning (0, 0, ENOSYS) for the syscall
+// triples, so that the arguments are still evaluated and the results still
+// assigned.
+var nopResults = map[string]string{
+	"Syscall":           "func(...uintptr) (uintptr, uintptr, Errno) { return 0, 0, ENOSYS }",
+	"Syscall6":          "func(...uintptr) (uintptr, uintptr, Errno) { return 0, 0, ENOSYS }",
+	"RawSyscall":        "func(...uintptr) (uintptr, uintptr, Errno) { return 0, 0, ENOSYS }",
+	"RawSyscall6":       "func(...uintptr) (uintptr, uintptr, Errno) { return 0, 0, ENOSYS }",
+	"SyscallNoError":    "func(...uintptr) (uintptr, uintptr) { return 0, 0 }",
+	"RawSyscallNoError": "func(...uintptr) (uintptr, uintptr) { return 0, 0 }",
+}
+
+// nopFuncLit returns the function literal replacing the function called at
+// site, or ok == false if site is not a call of a raw syscall function by
+// name.
+func nopFuncLit(site Site) (lit string, ok bool) {
+	lit, ok = nopResults[site.Func]
+	return lit, ok && strings.HasPrefix(site.Call, site.Func+"(")
+}
+
+// A replacement replaces length bytes at offset with text.
+type replacement struct {
+	offset, length int
+	text           string
+}
+
+// replaceRanges applies replacements, which must not overlap, to src.
+func replaceRanges(src []byte, replacements []replacement) []byte {
+	slices.SortFunc(replacements, func(a, b replacement) int { return a.offset - b.offset })
+	var out []byte
+	last := 0
+	for _, r := range replacements {
+		out = append(out, src[last:r.offset]...)
+		out = append(out, r.text...)
+		last = r.offset + r.length
+	}
+	return append(out, src[last:]...)
+}
+
+// shiftOffset returns the offset in the result of replaceRanges of offset in
+// its source, which must not be inside one of replacements.
+func shiftOffset(offset int, replacements []replacement) int {
+	shifted := offset
+	for _, r := range replacements {
+		if r.offset < offset {
+			shifted += len(r.text) - r.length
+		}
+	}
+	return shifted
+}
+
+// lineAt returns the 0-based index of the line of src containing offset.
+func lineAt(src []byte, offset int) int {
+	return bytes.Count(src[:offset], []byte("\n"))
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+)
+
+// Observer receives progress events while files are processed, so that
+// embedders can report progress without parsing log output.
+type Observer interface {
+	// FileStarted is called before path is read.
+	FileStarted(path string)
+	// SiteStubbed is called for each site as a statement is inserted
+	// before it.
+	SiteStubbed(site Site)
+	// FileDone is called once path has been processed. res is nil if err
+	// is not.
+	FileDone(path string, res *Result, err error)
+}
+
+type nopObserver struct{}
+
+func (nopObserver) FileStarted(string)              {}
+func (nopObserver) SiteStubbed(Site)                {}
+func (nopObserver) FileDone(string, *Result, error) {}
+
+func (o *Options) observer() Observer {
+	if o == nil || o.Observer == nil {
+		return nopObserver{}
+	}
+	return o.Observer
+}
+
+// cliObserver is the Observer used by the command. It logs progress and
+// accumulates the statistics printed at the end of a run and, if report is
+// set, the records of the -report file.
+type cliObserver struct {
+	stats
+	processed    []string       // paths of the files processed without error
+	trace        bool           // log the AST path of every site
+	unmatched    map[string]int // if not nil, unmatched syscall-like calls per name
+	formatDiff   bool           // log the changes format.Source made to each file
+	pruneImports bool           // unused imports are pruned rather than reported
+	tooLarge     int            // files skipped for exceeding the size limit
+	timing       bool           // record the Timing of every file for summarize
+	timings      []fileTiming
+	report       bool         // accumulate records
+	stream       *jsonlStream // if not nil, write the records of each file to it
+	root         string       // directory being processed, recorded in records
+	records      []reportRecord
+}
+
+func (o *cliObserver) FileStarted(path string) {}
+
+func (o *cliObserver) SiteStubbed(site Site) {
+	logger.debugf("%s: stubbed %s", site.Pos, site.Call)
+}
+
+func (o *cliObserver) FileDone(path string, res *Result, err error) {
+	var tooLarge *TooLargeError
+	if errors.As(err, &tooLarge) {
+		o.tooLarge++
+	}
+	if err != nil {
+		return
+	}
+	o.add(res)
+	o.processed = append(o.processed, path)
+	if o.timing {
+		o.timings = append(o.timings, fileTiming{path, res.Timing})
+	}
+	if o.report || o.stream != nil {
+		records := reportRecords(res)
+		for i := range records {
+			records[i].Root = o.root
+		}
+		if o.report {
+			o.records = append(o.records, records...)
+		}
+		if o.stream != nil {
+			o.stream.writeFile(records)
+		}
+	}
+	if o.trace {
+		for _, site := range res.Sites {
+			logger.infof("%s: %s", site.Pos, strings.Join(site.Path, " > "))
+		}
+		for _, skip := range res.Skipped {
+			logger.infof("%s: %s (skipped)", skip.Site.Pos, strings.Join(skip.Site.Path, " > "))
+		}
+	}
+	logUnmatched(res.Unmatched, o.unmatched)
+	if res.Deduped > 0 {
+		logger.infof("%s: removed %d redundant panics", path, res.Deduped)
+	}
+	if res.ParseErr != nil {
+		logger.warnf("%s: partially processed (had parse errors): %v", path, res.ParseErr)
+	}
+	for _, skip := range res.Skipped {
+		logger.warnf("%s: skipped %s: %s", skip.Site.Pos, skip.Site.Call, skip.Reason)
+	}
+	if res.StaleAnnotation {
+		logger.warnf("%s: the wasmstub annotation does not match the stubs in the file; was it regenerated?", path)
+	}
+	for _, p := range res.UnusedImports {
+		if o.pruneImports {
+			logger.infof("%s: pruned unused import %q", path, p)
+		} else {
+			logger.warnf("%s: import %q is no longer used", path, p)
+		}
+	}
+	if o.formatDiff && res.Formatted && !bytes.Equal(res.Unformatted, res.Output) {
+		logger.infof("%s: changes made by format.Source:\n%s", path, unifiedDiff(path+".spliced", path, res.Unformatted, res.Output, 3))
+	}
+	if res.Changed && res.FormatErr != nil && res.ParseErr == nil {
+		logger.warnf("could not format %s: %v", path, res.FormatErr)
+	}
+	if res.Excluded {
+		logger.debugf("Excluded: %s", path)
+		return
+	}
+	logger.infof("Processed: %s", path)
+}
+
+// needsChange reports whether any file needs stubbing or has a stale
+// annotation.
+func (o *cliObserver) needsChange() bool {
+	return o.changed > 0 || o.stale > 0
+}
+
+// summarize logs the totals of the run made with opts.
+func (o *cliObserver) summarize(opts *Options) {
+	if o.sites > 0 {
+		logger.infof("Stubbed %d sites: %s", o.sites, formatCounts(o.perFunc))
+	}
+	if opts.ExportedOnly {
+		logger.infof("Skipped %d sites in unexported functions", o.internal)
+	}
+	if opts.OnlyUnexported {
+		logger.infof("Skipped %d sites in exported functions", o.internal)
+	}
+	if ctxt := opts.target(); ctxt != nil {
+		logger.infof("Skipped %d files not built for %s/%s", o.excluded, ctxt.GOOS, ctxt.GOARCH)
+	}
+	summarizeUnmatched(o.unmatched)
+	if o.init > 0 {
+		logger.infof("Handled %d sites in init functions with -init-policy=%s", o.init, opts.initPolicy())
+	}
+	if opts.DedupPanics {
+		logger.infof("Removed %d redundant panics", o.deduped)
+	}
+	if o.tooLarge > 0 {
+		logger.warnf("Skipped %d files larger than -max-file-size", o.tooLarge)
+	}
+	if o.partial > 0 {
+		logger.warnf("Partially processed %d files with parse errors; their output is not formatted", o.partial)
+	}
+	if opts.SkipTests {
+		logger.infof("Skipped %d sites in _test.go files", o.skipTest)
+	} else if o.tests > 0 {
+		logger.infof("Stubbed %d sites in _test.go files (see -skip-tests)", o.tests)
+	}
+	if opts.KeepGofmtGroups {
+		logger.infof("Kept %d stubbed files unformatted for a minimal diff; %d of them differ from gofmt output (run gofmt to normalize them at the cost of a larger diff)", o.files, o.notGofmt)
+	}
+	if o.timing {
+		printTimings(os.Stderr, o.timings, slowestFiles)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type recordingObserver struct {
+	events []string
+}
+
+func (o *recordingObserver) FileStarted(path string) {
+	o.events = append(o.events, "start "+filepath.Base(path))
+}
+
+func (o *recordingObserver) SiteStubbed(site Site) {
+	o.events = append(o.events, "site "+site.Func)
+}
+
+func (o *recordingObserver) FileDone(path string, res *Result, err error) {
+	o.events = append(o.events, fmt.Sprintf("done %s %d %v", filepath.Base(path), len(res.Sites), err))
+}
+
+func TestObserver(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.go"), "package p\n\nfunc f() {\n\tSyscall(1, 2, 3)\n\tRawSyscall6(1, 2, 3, 4, 5, 6, 7)\n}\n")
+	writeFile(t, filepath.Join(dir, "b.go"), "package p\n")
+
+	obs := &recordingObserver{}
+	r := &runner{opts: &Options{Observer: obs}}
+	if err := r.processDirectory(dir); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"start a.go",
+		"site Syscall",
+		"site RawSyscall6",
+		"done a.go 2 <nil>",
+		"start b.go",
+		"done b.go 0 <nil>",
+	}
+	if !reflect.DeepEqual(obs.events, want) {
+		t.Errorf("got events %q, want %q", obs.events, want)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// renameSuffix is appended to the names of the functions renamed by
+// Options.RenameStubbedFuncs.
+const renameSuffix = "_wasmstub"
+
+// renameStubbed renames every function declaration of src stubbed by
+// ModeFuncBody or ModeEntry, that is whose body starts with the panic
+// naming it, from X to X_wasmstub, and declares a function X forwarding to
+// it in its place.
+//
+// This is meant for checking at link time that no stubbed function is
+// reachable: the linker drops unreachable functions, so after building a
+// program for wasm,
+//
+//	go tool nm prog.wasm | grep _wasmstub
+//
+// lists the stubbed functions it can still call. The renamed functions are
+// marked //go:noinline so that they keep a symbol of their own even when
+// reachable. Renaming fails if X_wasmstub is already declared in the file;
+// declarations in other files of the package are not checked, and show up
+// as compile errors instead.
+func renameStubbed(filename string, src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, filename, src, parser.SkipObjectResolution|parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	offset := func(pos token.Pos) int { return fset.Position(pos).Offset }
+	text := func(n ast.Node) string { return string(src[offset(n.Pos()):offset(n.End())]) }
+
+	declared := make(map[string]bool)
+	for _, decl := range node.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			declared[funcKey(decl, decl.Name.Name)] = true
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.ValueSpec:
+					for _, name := range spec.Names {
+						declared[name.Name] = true
+					}
+				case *ast.TypeSpec:
+					declared[spec.Name.Name] = true
+				}
+			}
+		}
+	}
+
+	var replacements []replacement
+	for _, decl := range node.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if !ok || fd.Body == nil || len(fd.Body.List) == 0 || strings.HasSuffix(fd.Name.Name, renameSuffix) {
+			continue
+		}
+		name := fd.Name.Name
+		if !bytes.HasPrefix(src[offset(fd.Body.List[0].Pos()):], []byte(entryPanic(name))) {
+			continue
+		}
+		renamed := name + renameSuffix
+		if declared[funcKey(fd, renamed)] {
+			return nil, fmt.Errorf("%s: cannot rename %s to %s, which is already declared", fset.Position(fd.Pos()), name, renamed)
+		}
+		replacements = append(replacements,
+			replacement{offset: offset(fd.Pos()), text: "//go:noinline\n"},
+			replacement{offset: offset(fd.Name.Pos()), length: len(name), text: renamed},
+			replacement{offset: offset(fd.End()), text: forwarder(fd, renamed, text)},
+		)
+	}
+	return replaceRanges(src, replacements), nil
+}
+
+// funcKey identifies the function or method fd would declare under name,
+// as "T.name" for methods of T.
+func funcKey(fd *ast.FuncDecl, name string) string {
+	if fd.Recv == nil || len(fd.Recv.List) == 0 {
+		return name
+	}
+	typ := fd.Recv.List[0].Type
+	for {
+		switch t := typ.(type) {
+		case *ast.StarExpr:
+			typ = t.X
+			continue
+		case *ast.IndexExpr:
+			typ = t.X
+			continue
+		case *ast.IndexListExpr:
+			typ = t.X
+			continue
+		case *ast.Ident:
+			return t.Name + "." + name
+		}
+		return name
+	}
+}
+
+// forwarder returns the declaration of a function with the name and
+// signature of fd calling renamed, preceded by a blank line. Unnamed and
+// blank parameters are named argN.
+func forwarder(fd *ast.FuncDecl, renamed string, text func(ast.Node) string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n\n// %s forwards to %s. See Options.RenameStubbedFuncs.\nfunc ", fd.Name.Name, renamed)
+	call := renamed
+	if fd.Recv != nil && len(fd.Recv.List) > 0 {
+		recv := fd.Recv.List[0]
+		name := "recv"
+		if len(recv.Names) > 0 && recv.Names[0].Name != "_" {
+			name = recv.Names[0].Name
+		}
+		fmt.Fprintf(&b, "(%s %s) ", name, text(recv.Type))
+		call = name + "." + renamed
+	}
+	b.WriteString(fd.Name.Name)
+	if tparams := fd.Type.TypeParams; tparams != nil {
+		b.WriteString(text(tparams))
+		var names []string
+		for _, field := range tparams.List {
+			for _, name := range field.Names {
+				names = append(names, name.Name)
+			}
+		}
+		call += "[" + strings.Join(names, ", ") + "]"
+	}
+
+	var params, args []string
+	for _, field := range fd.Type.Params.List {
+		names := make([]string, max(len(field.Names), 1))
+		for i := range names {
+			if i < len(field.Names) && field.Names[i].Name != "_" {
+				names[i] = field.Names[i].Name
+			} else {
+				names[i] = fmt.Sprintf("arg%d", len(args))
+			}
+			params = append(params, names[i]+" "+text(field.Type))
+			arg := names[i]
+			if _, ok := field.Type.(*ast.Ellipsis); ok {
+				arg += "..."
+			}
+			args = append(args, arg)
+		}
+	}
+	fmt.Fprintf(&b, "(%s)", strings.Join(params, ", "))
+	ret := ""
+	if results := fd.Type.Results; results != nil {
+		b.WriteString(" " + text(results))
+		ret = "return "
+	}
+	fmt.Fprintf(&b, " {\n\t%s%s(%s)\n}", ret, call, strings.Join(args, ", "))
+	return b.String()
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// reportRecord is a single site in a -report file.
+type reportRecord struct {
+	Root      string `json:"root,omitempty"` // directory argument the file was found under
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	Func      string `json:"func"`
+	Enclosing string `json:"enclosing,omitempty"`
+	Package   string `json:"package,omitempty"`
+	Call      string `json:"call"`
+	Status    string `json:"status"` // "stubbed" or "skipped"
+	Reason    string `json:"reason,omitempty"`
+}
+
+// reportRecords returns the records describing the sites of res.
+func reportRecords(res *Result) []reportRecord {
+	var records []reportRecord
+	add := func(site Site, status, reason string) {
+		records = append(records, reportRecord{
+			File:      site.File,
+			Line:      site.Pos.Line,
+			Column:    site.Pos.Column,
+			Func:      site.Func,
+			Enclosing: site.Enclosing,
+			Package:   site.Package,
+			Call:      site.Call,
+			Status:    status,
+			Reason:    reason,
+		})
+	}
+	for _, site := range res.Sites {
+		add(site, "stubbed", "")
+	}
+	for _, skip := range res.Skipped {
+		add(skip.Site, "skipped", skip.Reason)
+	}
+	return records
+}
+
+var reportFormats = map[string]func(io.Writer, []reportRecord) error{
+	"json":  writeJSONReport,
+	"jsonl": writeJSONLReport,
+	"csv":   writeCSVReport,
+	"text":  writeTextReport,
+}
+
+// reportFormat returns the format of the report written to file: format if
+// it is set, and otherwise the one matching the file extension, with text
+// as the default.
+func reportFormat(file, format string) (string, error) {
+	if format == "" {
+		switch filepath.Ext(file) {
+		case ".json":
+			return "json", nil
+		case ".jsonl":
+			return "jsonl", nil
+		case ".csv":
+			return "csv", nil
+		}
+		return "text", nil
+	}
+	if _, ok := reportFormats[format]; !ok {
+		return "", fmt.Errorf("unknown report format %q (want json, jsonl, csv or text)", format)
+	}
+	return format, nil
+}
+
+// writeReport writes records to file in the given format.
+func writeReport(file, format string, records []reportRecord) error {
+	var b bytes.Buffer
+	if err := reportFormats[format](&b, records); err != nil {
+		return err
+	}
+	return writeFileAtomic(file, b.Bytes())
+}
+
+func writeJSONReport(w io.Writer, records []reportRecord) error {
+	if records == nil {
+		records = []reportRecord{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(records)
+}
+
+// writeJSONLReport writes records as JSON Lines, one object per line, in
+// their order. Commands stream this format with a jsonlStream instead.
+func writeJSONLReport(w io.Writer, records []reportRecord) error {
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonlStream writes the records of a JSON Lines report as files are
+// processed, instead of holding them all in memory. The records of a file
+// are written together, sorted by position; files come in the order they
+// are processed, which walkGoFiles makes the sorted order of their paths
+// under each root.
+type jsonlStream struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+	err error // first write error
+}
+
+func newJSONLStream(w io.Writer) *jsonlStream {
+	bw := bufio.NewWriter(w)
+	return &jsonlStream{w: bw, enc: json.NewEncoder(bw)}
+}
+
+// writeFile writes the records of a file, sorting them in place.
+func (s *jsonlStream) writeFile(records []reportRecord) {
+	slices.SortStableFunc(records, func(a, b reportRecord) int {
+		return cmp.Or(cmp.Compare(a.Line, b.Line), cmp.Compare(a.Column, b.Column))
+	})
+	for _, r := range records {
+		if s.err != nil {
+			return
+		}
+		s.err = s.enc.Encode(r)
+	}
+}
+
+// flush writes any buffered records and returns the first error.
+func (s *jsonlStream) flush() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.w.Flush()
+}
+
+func writeCSVReport(w io.Writer, records []reportRecord) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"file", "line", "column", "func", "enclosing", "call", "status", "reason", "root", "package"})
+	for _, r := range records {
+		cw.Write([]string{r.File, strconv.Itoa(r.Line), strconv.Itoa(r.Column), r.Func, r.Enclosing, r.Call, r.Status, r.Reason, r.Root, r.Package})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeTextReport(w io.Writer, records []reportRecord) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "FILE\tLINE\tFUNC\tENCLOSING\tSTATUS\n")
+	stubbed := 0
+	for _, r := range records {
+		status := r.Status
+		if r.Reason != "" {
+			status += " (" + r.Reason + ")"
+		} else {
+			stubbed++
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\n", r.File, r.Line, r.Func, r.Enclosing, status)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d sites, %d stubbed, %d skipped\n", len(records), stubbed, len(records)-stubbed)
+	return err
+}
+
+// packageSummary aggregates the records of one package, for the view of
+// report -group-by-package.
+type packageSummary struct {
+	Package  string   `json:"package"`
+	Dir      string   `json:"dir"`
+	Stubbed  int      `json:"stubbed"`
+	Skipped  int      `json:"skipped"`
+	Exported []string `json:"exported"` // sorted exported functions with stubbed sites
+}
+
+// groupByPackage aggregates records by package, identified by its directory
+// and name, in the order of their directories.
+func groupByPackage(records []reportRecord) []packageSummary {
+	index := make(map[[2]string]int)
+	var pkgs []packageSummary
+	exported := make(map[[2]string]map[string]bool)
+	for _, r := range records {
+		key := [2]string{filepath.Dir(r.File), r.Package}
+		i, ok := index[key]
+		if !ok {
+			i = len(pkgs)
+			index[key] = i
+			pkgs = append(pkgs, packageSummary{Package: r.Package, Dir: key[0], Exported: []string{}})
+			exported[key] = make(map[string]bool)
+		}
+		if r.Status != "stubbed" {
+			pkgs[i].Skipped++
+			continue
+		}
+		pkgs[i].Stubbed++
+		if token.IsExported(r.Enclosing) && !exported[key][r.Enclosing] {
+			exported[key][r.Enclosing] = true
+			pkgs[i].Exported = append(pkgs[i].Exported, r.Enclosing)
+		}
+	}
+	for i := range pkgs {
+		slices.Sort(pkgs[i].Exported)
+	}
+	slices.SortStableFunc(pkgs, func(a, b packageSummary) int {
+		return cmp.Or(strings.Compare(a.Dir, b.Dir), strings.Compare(a.Package, b.Package))
+	})
+	return pkgs
+}
+
+var packageFormats = map[string]func(io.Writer, []packageSummary) error{
+	"json":  writeJSONPackages,
+	"jsonl": writeJSONLPackages,
+	"csv":   writeCSVPackages,
+	"text":  writeTextPackages,
+}
+
+func writeJSONPackages(w io.Writer, pkgs []packageSummary) error {
+	if pkgs == nil {
+		pkgs = []packageSummary{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(pkgs)
+}
+
+func writeJSONLPackages(w io.Writer, pkgs []packageSummary) error {
+	enc := json.NewEncoder(w)
+	for _, pkg := range pkgs {
+		if err := enc.Encode(pkg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeCSVPackages(w io.Writer, pkgs []packageSummary) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"package", "dir", "stubbed", "skipped", "exported"})
+	for _, p := range pkgs {
+		cw.Write([]string{p.Package, p.Dir, strconv.Itoa(p.Stubbed), strconv.Itoa(p.Skipped), strings.Join(p.Exported, " ")})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeTextPackages writes a section per package listing its exported
+// functions, meant to be pasted in release notes.
+func writeTextPackages(w io.Writer, pkgs []packageSummary) error {
+	for i, p := range pkgs {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "package %s (%s): %d sites stubbed, %d skipped, in %d exported functions\n", p.Package, p.Dir, p.Stubbed, p.Skipped, len(p.Exported))
+		for _, name := range p.Exported {
+			if _, err := fmt.Fprintf(w, "\t%s\n", name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var testRecords = reportRecords(&Result{
+	Sites: []Site{{
+		File:      "a.go",
+		Func:      "Syscall",
+		Call:      "Syscall(1, 2, 3)",
+		Pos:       token.Position{Line: 4, Column: 2},
+		Enclosing: "Read",
+		Package:   "p",
+	}},
+	Skipped: []Skip{{
+		Site: Site{
+			File:      "a.go",
+			Func:      "RawSyscall",
+			Call:      "RawSyscall(4, 5, 6)",
+			Pos:       token.Position{Line: 9, Column: 2},
+			Enclosing: "exit",
+			Package:   "p",
+			Terminal:  true,
+		},
+		Reason: "already terminal",
+	}},
+})
+
+func TestReportFormat(t *testing.T) {
+	tests := []struct {
+		file, format string
+		want         string
+	}{
+		{"r.json", "", "json"},
+		{"r.csv", "", "csv"},
+		{"r.txt", "", "text"},
+		{"r", "", "text"},
+		{"r.json", "csv", "csv"},
+		{"r.jsonl", "", "jsonl"},
+	}
+	for _, tt := range tests {
+		got, err := reportFormat(tt.file, tt.format)
+		if err != nil || got != tt.want {
+			t.Errorf("reportFormat(%q, %q) = %q, %v; want %q", tt.file, tt.format, got, err, tt.want)
+		}
+	}
+	if _, err := reportFormat("r.json", "xml"); err == nil {
+		t.Error("reportFormat accepted xml")
+	}
+}
+
+func TestReports(t *testing.T) {
+	tests := map[string]string{
+		"json": `[
+	{
+		"file": "a.go",
+		"line": 4,
+		"column": 2,
+		"func": "Syscall",
+		"enclosing": "Read",
+		"package": "p",
+		"call": "Syscall(1, 2, 3)",
+		"status": "stubbed"
+	},
+	{
+		"file": "a.go",
+		"line": 9,
+		"column": 2,
+		"func": "RawSyscall",
+		"enclosing": "exit",
+		"package": "p",
+		"call": "RawSyscall(4, 5, 6)",
+		"status": "skipped",
+		"reason": "already terminal"
+	}
+]
+`,
+		"jsonl": `{"file":"a.go","line":4,"column":2,"func":"Syscall","enclosing":"Read","package":"p","call":"Syscall(1, 2, 3)","status":"stubbed"}
+{"file":"a.go","line":9,"column":2,"func":"RawSyscall","enclosing":"exit","package":"p","call":"RawSyscall(4, 5, 6)","status":"skipped","reason":"already terminal"}
+`,
+		"csv": `file,line,column,func,enclosing,call,status,reason,root,package
+a.go,4,2,Syscall,Read,"Syscall(1, 2, 3)",stubbed,,,p
+a.go,9,2,RawSyscall,exit,"RawSyscall(4, 5, 6)",skipped,already terminal,,p
+`,
+		"text": `FILE  LINE  FUNC        ENCLOSING  STATUS
+a.go  4     Syscall     Read       stubbed
+a.go  9     RawSyscall  exit       skipped (already terminal)
+
+2 sites, 1 stubbed, 1 skipped
+`,
+	}
+	for format, want := range tests {
+		var b strings.Builder
+		if err := reportFormats[format](&b, testRecords); err != nil {
+			t.Fatal(err)
+		}
+		if got := b.String(); got != want {
+			t.Errorf("%s report:\n%s\nwant:\n%s", format, got, want)
+		}
+	}
+}
+
+func TestJSONLStream(t *testing.T) {
+	var b strings.Builder
+	s := newJSONLStream(&b)
+	// Skipped sites come after stubbed ones in reportRecords, whatever
+	// their position.
+	s.writeFile(reportRecords(&Result{
+		Sites:   []Site{{File: "a.go", Pos: token.Position{Line: 9}}},
+		Skipped: []Skip{{Site: Site{File: "a.go", Pos: token.Position{Line: 4}}}},
+	}))
+	s.writeFile(reportRecords(&Result{Sites: []Site{{File: "b.go", Pos: token.Position{Line: 1}}}}))
+	if b.Len() > 0 {
+		t.Errorf("records written before flush: %q", b.String())
+	}
+	if err := s.flush(); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, line := range strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n") {
+		var r reportRecord
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+		got = append(got, fmt.Sprintf("%s:%d", r.File, r.Line))
+	}
+	if want := []string{"a.go:4", "a.go:9", "b.go:1"}; !slices.Equal(got, want) {
+		t.Errorf("got records %v, want %v", got, want)
+	}
+}
+
+func TestGroupByPackage(t *testing.T) {
+	records := []reportRecord{
+		{File: "unix/a.go", Package: "unix", Enclosing: "Read", Status: "stubbed"},
+		{File: "unix/b.go", Package: "unix", Enclosing: "Read", Status: "stubbed"},
+		{File: "unix/b.go", Package: "unix", Enclosing: "read", Status: "stubbed"},
+		{File: "unix/b.go", Package: "unix", Enclosing: "Exit", Status: "skipped"},
+		{File: "plan9/a.go", Package: "plan9", Enclosing: "Close", Status: "stubbed"},
+	}
+	var b strings.Builder
+	if err := writeTextPackages(&b, groupByPackage(records)); err != nil {
+		t.Fatal(err)
+	}
+	const want = `package plan9 (plan9): 1 sites stubbed, 0 skipped, in 1 exported functions
+	Close
+
+package unix (unix): 3 sites stubbed, 1 skipped, in 1 exported functions
+	Read
+`
+	if got := b.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"io"
+	"os"
+	"strings"
+)
+
+// An Action is what is inserted before the sites of a function by a Rule.
+type Action string
+
+const (
+	ActionPanic  Action = "panic"  // panic, like DefaultInsert
+	ActionENOSYS Action = "enosys" // return ENOSYS as the error result
+	ActionZero   Action = "zero"   // return zero values
+	ActionIgnore Action = "ignore" // leave the site alone
+)
+
+var actions = map[Action]bool{
+	ActionPanic:  true,
+	ActionENOSYS: true,
+	ActionZero:   true,
+	ActionIgnore: true,
+}
+
+// A Rule overrides how the sites of one syscall function are stubbed.
+type Rule struct {
+	Action Action
+
+	// Message replaces the default panic message of ActionPanic, and is
+	// added as a comment to the return statements of ActionENOSYS and
+	// ActionZero.
+	Message string
+}
+
+// Insert returns the line inserted before site by r. It must not be called
+// for ActionIgnore.
+func (r Rule) Insert(site Site) (string, error) {
+	switch r.Action {
+	case ActionPanic:
+		if r.Message == "" {
+			return DefaultInsert(site)
+		}
+		return fmt.Sprintf("panic(%q)", r.Message+": "+site.Call), nil
+	case ActionENOSYS, ActionZero:
+		if site.Enclosing == "" {
+			return "", fmt.Errorf("cannot apply %s rule outside a function declaration", r.Action)
+		}
+		values := make([]string, len(site.Results))
+		for i, typ := range site.Results {
+			values[i] = zeroValue(typ)
+			if r.Action == ActionENOSYS && (typ == "error" || typ == "Errno") {
+				values[i] = "ENOSYS"
+			}
+		}
+		text := strings.TrimSpace("return " + strings.Join(values, ", "))
+		if r.Message != "" {
+			text += " // " + r.Message
+		}
+		return text, nil
+	}
+	return "", fmt.Errorf("rule for %s: unexpected action %q", site.Func, r.Action)
+}
+
+// zeroValue returns an expression for the zero value of the type typ.
+func zeroValue(typ string) string {
+	expr, err := parser.ParseExpr(typ)
+	if err != nil {
+		return "*new(" + typ + ")"
+	}
+	switch t := expr.(type) {
+	case *ast.Ident:
+		switch t.Name {
+		case "int", "int8", "int16", "int32", "int64",
+			"uint", "uint8", "uint16", "uint32", "uint64", "uintptr",
+			"float32", "float64", "complex64", "complex128", "byte", "rune":
+			return "0"
+		case "string":
+			return `""`
+		case "bool":
+			return "false"
+		case "error", "any":
+			return "nil"
+		}
+	case *ast.StarExpr, *ast.ArrayType, *ast.MapType, *ast.ChanType, *ast.FuncType, *ast.InterfaceType:
+		if a, ok := t.(*ast.ArrayType); !ok || a.Len == nil {
+			return "nil"
+		}
+	}
+	return "*new(" + typ + ")"
+}
+
+// parseRules parses a rules file. Each line holds a function name, an
+// action and an optional message extending to the end of the line:
+//
+//	# function      action  message
+//	Syscall         panic
+//	SyscallNoError  zero
+//	RawSyscall6     enosys  raw syscalls are not available
+//
+// Blank lines and lines starting with '#' are ignored.
+func parseRules(r io.Reader) (map[string]Rule, error) {
+	rules := make(map[string]Rule)
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: missing action for %s", n, fields[0])
+		}
+		name, action := fields[0], Action(fields[1])
+		if !actions[action] {
+			return nil, fmt.Errorf("line %d: unknown action %q (want panic, enosys, zero or ignore)", n, action)
+		}
+		if _, ok := rules[name]; ok {
+			return nil, fmt.Errorf("line %d: duplicate rule for %s", n, name)
+		}
+		var message string
+		if len(fields) > 2 {
+			rest := strings.TrimSpace(line[len(fields[0]):])
+			message = strings.TrimSpace(rest[len(fields[1]):])
+		}
+		rules[name] = Rule{Action: action, Message: message}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// loadRules reads the rules file at path.
+func loadRules(path string) (map[string]Rule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rules, err := parseRules(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRules(t *testing.T) {
+	rules, err := parseRules(strings.NewReader(`
+# function  action  message
+Syscall         panic
+SyscallNoError  zero
+RawSyscall6     enosys   raw syscalls are not available
+RawSyscall      ignore
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Rule{
+		"Syscall":        {Action: ActionPanic},
+		"SyscallNoError": {Action: ActionZero},
+		"RawSyscall6":    {Action: ActionENOSYS, Message: "raw syscalls are not available"},
+		"RawSyscall":     {Action: ActionIgnore},
+	}
+	if len(rules) != len(want) {
+		t.Fatalf("got %d rules, want %d", len(rules), len(want))
+	}
+	for name, r := range want {
+		if rules[name] != r {
+			t.Errorf("rule for %s = %+v, want %+v", name, rules[name], r)
+		}
+	}
+
+	for _, bad := range []string{
+		"Syscall\n",
+		"Syscall fail\n",
+		"Syscall panic\nSyscall zero\n",
+	} {
+		if _, err := parseRules(strings.NewReader(bad)); err == nil {
+			t.Errorf("parseRules(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestRules(t *testing.T) {
+	const src = `package p
+
+func a() (fd int, err error) {
+	r0, _, e1 := RawSyscall6(1, 2, 3, 4, 5, 6, 7)
+	fd = int(r0)
+	if e1 != 0 {
+		err = e1
+	}
+	return
+}
+
+func b() (p *int, s []byte, h Handle) {
+	SyscallNoError(1, 2, 3)
+	return
+}
+
+func c() {
+	Syscall(1, 2, 3)
+	RawSyscall(1, 2, 3)
+}
+`
+	const want = `package p
+
+func a() (fd int, err error) {
+	return 0, ENOSYS // raw syscalls are not available
+	r0, _, e1 := RawSyscall6(1, 2, 3, 4, 5, 6, 7)
+	fd = int(r0)
+	if e1 != 0 {
+		err = e1
+	}
+	return
+}
+
+func b() (p *int, s []byte, h Handle) {
+	return nil, nil, *new(Handle)
+	SyscallNoError(1, 2, 3)
+	return
+}
+
+func c() {
+	panic("no syscalls: Syscall(1, 2, 3)")
+	Syscall(1, 2, 3)
+	RawSyscall(1, 2, 3)
+}
+`
+	opts := &Options{Rules: map[string]Rule{
+		"RawSyscall6":    {Action: ActionENOSYS, Message: "raw syscalls are not available"},
+		"SyscallNoError": {Action: ActionZero},
+		"Syscall":        {Action: ActionPanic, Message: "no syscalls"},
+		"RawSyscall":     {Action: ActionIgnore},
+	}}
+	res, err := Stub("p.go", []byte(src), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(res.Output); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if len(res.Skipped) != 1 || res.Skipped[0].Reason != "ignored by rule" {
+		t.Errorf("Skipped = %+v, want RawSyscall ignored by rule", res.Skipped)
+	}
+}
+
+func TestRulesIdempotent(t *testing.T) {
+	const src = "package p\n\nfunc f() (err error) {\n\tSyscall(1, 2, 3)\n\treturn\n}\n"
+	opts := &Options{Rules: map[string]Rule{"Syscall": {Action: ActionENOSYS}}}
+	res, err := Stub("p.go", []byte(src), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := Stub("p.go", res.Output, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Changed {
+		t.Errorf("second run changed the output:\n%s", again.Output)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// allowedVet holds the suffixes of the go vet findings expected in stubbed
+// code: every inserted panic makes the statement after it unreachable.
+var allowedVet = []string{": unreachable code"}
+
+// TestSelfCheck stubs the package in testdata/selfcheck in every mode and
+// checks that the output passes gofmt -l and go vet, apart from the
+// findings in allowedVet. It guards against output that parses but that
+// the toolchain would still flag.
+func TestSelfCheck(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs gofmt and go vet")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	out, err := exec.Command(goTool, "env", "GOROOT").Output()
+	if err != nil {
+		t.Fatalf("go env GOROOT: %v", err)
+	}
+	gofmt := filepath.Join(strings.TrimSpace(string(out)), "bin", "gofmt")
+
+	corpus, err := filepath.Glob("testdata/selfcheck/*.go")
+	if err != nil || len(corpus) == 0 {
+		t.Fatalf("no corpus: %v", err)
+	}
+	modes := map[string]*Options{
+		"insert":   nil,
+		"strict":   {StrictPositions: true},
+		"funcbody": {Mode: ModeFuncBody, PruneImports: true},
+		"nop":      {Mode: ModeNop},
+		"entry":    {Mode: ModeEntry},
+		"errno":    {Mode: ModeErrno},
+		"rename":   {Mode: ModeEntry, RenameStubbedFuncs: true},
+		"lazy":     {InitPolicy: InitLazy},
+		"goos":     {StubTag: StubTagGOOS},
+		"files":    {StubTag: StubTagFiles},
+	}
+	for name, opts := range modes {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, filepath.Join(dir, "go.mod"), "module selfcheck\n\ngo 1.24\n")
+			for _, path := range corpus {
+				writeFile(t, filepath.Join(dir, filepath.Base(path)), readFile(t, path))
+			}
+			if err := (&runner{opts: opts}).processDirectory(dir); err != nil {
+				t.Fatal(err)
+			}
+
+			out, err := exec.Command(gofmt, "-l", dir).CombinedOutput()
+			if err != nil || len(out) > 0 {
+				t.Errorf("gofmt -l: %v\n%s", err, out)
+			}
+
+			vet := exec.Command(goTool, "vet", ".")
+			vet.Dir = dir
+			vet.Env = append(os.Environ(), "GOWORK=off", "GOFLAGS=", "GOTOOLCHAIN=local")
+			out, err = vet.CombinedOutput()
+			var unexpected []string
+			for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+				if line == "" || strings.HasPrefix(line, "#") || hasAnySuffix(line, allowedVet) {
+					continue
+				}
+				unexpected = append(unexpected, line)
+			}
+			if len(unexpected) > 0 {
+				t.Errorf("go vet: %v\n%s", err, strings.Join(unexpected, "\n"))
+			}
+		})
+	}
+}
+
+func hasAnySuffix(s string, suffixes []string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(s, suffix) {
+			return true
+		}
+	}
+	return false
+}
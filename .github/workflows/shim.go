@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"slices"
+	"strings"
+)
+
+// With ModeShim, the statement inserted before a site first offers the
+// syscall to a shim package provided by the host, and only panics if the
+// shim does not implement it. The shim package, given by Options.ShimPkg,
+// must provide:
+//
+//	// Available reports whether the host implements the syscall trap.
+//	func Available(trap uintptr) bool
+//
+//	// Do performs the syscall trap with args, returning its two results
+//	// and nil, or an error if it failed.
+//	func Do(trap uintptr, args ...uintptr) (r1, r2 uintptr, err error)
+//
+// Package golang.org/x/sys/sysjs provides them on top of handlers registered
+// by the program, and is the shim the fork workflow stubs unix with.
+//
+// For a site in a function returning (n int, err error), the inserted
+// statements are
+//
+//	if wasmsyscall.Available(SYS_READ) {
+//		r1, _, err := wasmsyscall.Do(SYS_READ, uintptr(fd), uintptr(p), uintptr(n))
+//		return int(r1), err
+//	}
+//	panic("syscall not supported in wasm: Syscall(SYS_READ, ...)")
+//
+// The results of the enclosing function are filled in order from r1 and r2
+// for integer types, err for error types and zero values otherwise. The
+// integer types are the predeclared ones, the named ones of unix and
+// windows listed in shimIntTypes, and those of Options.ShimIntTypes; any
+// other named type, such as a struct, gets its zero value. Sites
+// of calls without a trap argument, such as the proc calls of -windows, are
+// stubbed with a plain panic.
+
+// shimInsert returns the if statement offering site to the shim package
+// named name, or ok == false if site cannot be offered. intTypes lists
+// named integer types in addition to shimIntTypes.
+func shimInsert(site Site, name string, intTypes []string) (text string, ok bool) {
+	if !syscallFuncs[site.Func] || site.Enclosing == "" {
+		return "", false
+	}
+	expr, err := parser.ParseExpr(site.Call)
+	if err != nil {
+		return "", false
+	}
+	call, ok := expr.(*ast.CallExpr)
+	if !ok || len(call.Args) == 0 {
+		return "", false
+	}
+	args := make([]string, len(call.Args))
+	for i, arg := range call.Args {
+		args[i] = site.Call[arg.Pos()-1 : arg.End()-1]
+	}
+	trap := args[0]
+
+	var results []string
+	used := map[string]bool{}
+	ints := []string{"r1", "r2"}
+	for _, typ := range site.Results {
+		switch {
+		case typ == "error":
+			results = append(results, "err")
+			used["err"] = true
+		case isIntegerType(typ, intTypes) && len(ints) > 0:
+			results = append(results, typ+"("+ints[0]+")")
+			used[ints[0]] = true
+			ints = ints[1:]
+		default:
+			results = append(results, zeroValue(typ))
+		}
+	}
+	var lhs []string
+	for _, v := range []string{"r1", "r2", "err"} {
+		if used[v] {
+			lhs = append(lhs, v)
+		} else {
+			lhs = append(lhs, "_")
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "if %s.Available(%s) {\n", name, trap)
+	do := fmt.Sprintf("%s.Do(%s)", name, strings.Join(args, ", "))
+	if len(used) == 0 {
+		fmt.Fprintf(&b, "%s\n", do)
+	} else {
+		fmt.Fprintf(&b, "%s := %s\n", strings.Join(lhs, ", "), do)
+	}
+	fmt.Fprintf(&b, "%s\n}", strings.TrimSpace("return "+strings.Join(results, ", ")))
+	return b.String(), true
+}
+
+// shimIntTypes are the named types of unix and windows that shim results
+// convert from uintptr.
+var shimIntTypes = []string{"Errno", "Handle", "Signal", "Time_t"}
+
+// isIntegerType reports whether typ is a predeclared integer type or one of
+// the named integer types of shimIntTypes and extra.
+func isIntegerType(typ string, extra []string) bool {
+	switch typ {
+	case "int", "int8", "int16", "int32", "int64",
+		"uint", "uint8", "uint16", "uint32", "uint64", "uintptr", "byte", "rune":
+		return true
+	}
+	return slices.Contains(shimIntTypes, typ) || slices.Contains(extra, typ)
+}
+
+// addImport returns src with an import of path added after the package
+// clause, unless src already imports it.
+func addImport(src []byte, path string) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ImportsOnly|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	for _, spec := range f.Imports {
+		if importPath(spec) == path {
+			return src, nil
+		}
+	}
+	end := fset.Position(f.Name.End()).Offset
+	var out []byte
+	out = append(out, src[:end]...)
+	out = fmt.Appendf(out, "\n\nimport %q", path)
+	return append(out, src[end:]...), nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestShimIdempotent(t *testing.T) {
+	src, err := os.ReadFile("testdata/shim.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Stub("shim.go", src, fixtureOptions["shim"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Changed {
+		t.Errorf("stubbing again changed the file:\n%s", res.Output)
+	}
+}
+
+func TestShimWithoutTrap(t *testing.T) {
+	site := Site{Func: "Call", Call: "procGetTickCount.Call()", Enclosing: "GetTickCount"}
+	if text, ok := shimInsert(site, "wasmsyscall", nil); ok {
+		t.Errorf("shimInsert offered a proc call:\n%s", text)
+	}
+}
+
+func TestShimResults(t *testing.T) {
+	site := Site{Func: "Syscall", Call: "Syscall(SYS_FSTAT, uintptr(fd), 0, 0)", Enclosing: "fstat"}
+	tests := []struct {
+		results  []string
+		intTypes []string
+		want     string
+	}{
+		{[]string{"Stat_t", "error"}, nil, "return *new(Stat_t), err"},
+		{[]string{"Handle", "Errno"}, nil, "return Handle(r1), Errno(r2)"},
+		{[]string{"Pid", "error"}, nil, "return *new(Pid), err"},
+		{[]string{"Pid", "error"}, []string{"Pid"}, "return Pid(r1), err"},
+	}
+	for _, tt := range tests {
+		site.Results = tt.results
+		text, ok := shimInsert(site, "wasmsyscall", tt.intTypes)
+		if !ok || !strings.Contains(text, tt.want) {
+			t.Errorf("shimInsert for results %v and types %v:\n%s\nwant %q", tt.results, tt.intTypes, text, tt.want)
+		}
+	}
+}
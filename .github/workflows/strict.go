@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"slices"
+)
+
+// A stmtInsertion is a statement list to insert, with StrictPositions,
+// before the statement at offset.
+type stmtInsertion struct {
+	offset int    // offset of the statement containing the site
+	text   string // statements to insert, as returned by Options.insert
+}
+
+// insertStmts parses src and inserts the statements of each of insertions
+// into the innermost statement list holding a statement that contains its
+// offset, before that statement. The file is then printed again, so that
+// the result does not depend on how src is laid out in lines.
+func insertStmts(filename string, src []byte, insertions []stmtInsertion) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	tf := fset.File(file.Pos())
+	// directives maps lines holding only a directive comment to it.
+	directives := make(map[int]*ast.Comment)
+	for _, g := range file.Comments {
+		for _, c := range g.List {
+			line := tf.Line(c.Pos())
+			if start := tf.Offset(tf.LineStart(line)); isDirectiveLine(src[start:tf.Offset(c.End())]) {
+				directives[line] = c
+			}
+		}
+	}
+
+	// target is where the statements of an insertion go.
+	type target struct {
+		list  *[]ast.Stmt
+		index int
+	}
+	targets := make([]target, len(insertions))
+	ast.Inspect(file, func(n ast.Node) bool {
+		var list *[]ast.Stmt
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			list = &n.List
+		case *ast.CaseClause:
+			list = &n.Body
+		case *ast.CommClause:
+			list = &n.Body
+		default:
+			return true
+		}
+		// Lists are visited outermost first, so the innermost one wins.
+		for i, stmt := range *list {
+			start, end := tf.Offset(stmt.Pos()), tf.Offset(stmt.End())
+			for j, ins := range insertions {
+				if start <= ins.offset && ins.offset < end {
+					targets[j] = target{list, i}
+				}
+			}
+		}
+		return true
+	})
+
+	// Insert from the end of each list so that earlier indices stay valid.
+	order := make([]int, len(insertions))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return targets[b].index - targets[a].index })
+	done := make(map[target]bool)
+	for _, i := range order {
+		t := targets[i]
+		if t.list == nil {
+			return nil, fmt.Errorf("%s: no statement list contains offset %d", filename, insertions[i].offset)
+		}
+		if done[t] {
+			continue
+		}
+		done[t] = true
+		stmts, err := parseStmts(insertions[i].text, directiveStart(tf, directives, (*t.list)[t.index].Pos()))
+		if err != nil {
+			return nil, err
+		}
+		*t.list = slices.Insert(*t.list, t.index, stmts...)
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// directiveStart returns the position before the directive comments on the
+// lines right above pos, or pos if there are none, so that statements
+// inserted there do not separate the directives from the statement they
+// apply to.
+func directiveStart(tf *token.File, directives map[int]*ast.Comment, pos token.Pos) token.Pos {
+	line := tf.Line(pos)
+	if directives[line-1] == nil {
+		return pos
+	}
+	for directives[line-1] != nil {
+		line--
+	}
+	// The end of the line above, so that the printer puts the first
+	// directive on a line of its own after the statements.
+	return tf.LineStart(line) - 1
+}
+
+// parseStmts parses text as a list of statements positioned at pos, so
+// that the comments before pos are printed before them.
+func parseStmts(text string, pos token.Pos) ([]ast.Stmt, error) {
+	src := "package p\nfunc _() {\n" + text + "\n}\n"
+	file, err := parser.ParseFile(token.NewFileSet(), "", src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, fmt.Errorf("parsing inserted statements %q: %w", text, err)
+	}
+	stmts := file.Decls[0].(*ast.FuncDecl).Body.List
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			if n != nil {
+				setPositions(reflect.ValueOf(n).Elem(), pos)
+			}
+			return true
+		})
+	}
+	return stmts, nil
+}
+
+// setPositions sets the valid token.Pos fields of the struct v to pos.
+// Invalid ones, such as the Ellipsis of a call without one, mean something.
+func setPositions(v reflect.Value, pos token.Pos) {
+	posType := reflect.TypeFor[token.Pos]()
+	for i := 0; i < v.NumField(); i++ {
+		if f := v.Field(i); f.Type() == posType && f.CanSet() && f.Int() != int64(token.NoPos) {
+			f.Set(reflect.ValueOf(pos))
+		}
+	}
+}
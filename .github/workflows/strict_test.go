@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestStrictPositionsMatchesFixtures checks that the AST rewriting of
+// StrictPositions produces the same output as line splicing on the fixtures.
+func TestStrictPositionsMatchesFixtures(t *testing.T) {
+	inputs, err := filepath.Glob("testdata/*.input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".input")
+		if fixtureOptions[name] != nil {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			src, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines, err := Stub(input, src, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			strict, err := Stub(input, src, &Options{StrictPositions: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(strict.Output, lines.Output) {
+				t.Errorf("strict positions:\n%s\nline splicing:\n%s", strict.Output, lines.Output)
+			}
+		})
+	}
+}
+
+func TestStrictPositionsOneLineClosure(t *testing.T) {
+	const src = `package p
+
+func f() {
+	close := func() { Syscall(SYS_CLOSE, 0, 0, 0) }
+	defer close()
+}
+`
+	const want = `package p
+
+func f() {
+	close := func() {
+		panic("syscall not supported in wasm: Syscall(SYS_CLOSE, 0, 0, 0)")
+		Syscall(SYS_CLOSE, 0, 0, 0)
+	}
+	defer close()
+}
+`
+	res, err := Stub("p.go", []byte(src), &Options{StrictPositions: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(res.Output); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
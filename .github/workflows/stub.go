@@ -0,0 +1,1099 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Site describes a syscall call site matched in a source file.
+type Site struct {
+	File string         // name of the file containing the call
+	Func string         // name of the called function, e.g. "Syscall6"
+	Call string         // source text of the call expression
+	Pos  token.Position // position of the statement containing the call
+
+	// CallPos is the position of the call expression itself.
+	CallPos token.Position
+
+	// Enclosing is the name of the function declaration containing the
+	// call, or "" for calls outside any function declaration.
+	Enclosing string
+
+	// Package is the name in the package clause of File.
+	Package string
+
+	// Results holds the source text of the result types of the enclosing
+	// function declaration, one per result.
+	Results []string
+
+	// Terminal reports whether the call is an argument of panic or
+	// os.Exit, so that the statement already never completes normally.
+	Terminal bool
+
+	// Conditional reports whether the call is in the right operand of a
+	// && or || expression, and so only runs depending on the left one, as
+	// in ok := precheck() && Syscall(...) == 0.
+	Conditional bool
+
+	// Literal reports whether the call is inside a function literal, to
+	// which Results do not apply.
+	Literal bool
+
+	// Init reports whether the statement is in a package init function,
+	// outside function literals, and so runs when the package is loaded.
+	Init bool
+
+	// Test reports whether the site is in a _test.go file, in a test,
+	// benchmark or example function or a helper.
+	Test bool
+
+	// Off reports whether the statement is between //wasmstub:off and
+	// //wasmstub:on directive comments, and so must be left alone.
+	Off bool
+
+	// Path is the chain of AST nodes from the file down to the call, as
+	// returned by astPath. It is only set if Options.Trace is.
+	Path []string
+}
+
+// Options controls how syscall sites are stubbed.
+type Options struct {
+	// InsertFunc returns the exact line(s) to insert before the statement
+	// of a matched site. Multiple lines are separated by "\n" and each is
+	// indented like the statement. If nil, DefaultInsert is used.
+	InsertFunc func(site Site) (string, error)
+
+	// ExportedOnly restricts stubbing to sites whose enclosing function
+	// declaration is exported.
+	ExportedOnly bool
+
+	// OnlyUnexported is the inverse of ExportedOnly: it restricts stubbing
+	// to sites outside exported function declarations. Setting both stubs
+	// nothing.
+	OnlyUnexported bool
+
+	// BestEffort stubs the sites found in the partial AST of a file with
+	// syntax errors instead of failing. The output of such a file cannot
+	// be formatted.
+	BestEffort bool
+
+	// SkipTests leaves the sites of _test.go files alone. Tests and
+	// examples run on the host, where the real syscalls are available.
+	SkipTests bool
+
+	// KeepGofmtGroups keeps the spliced source instead of reformatting the
+	// whole file, so that the only changes are the inserted lines. The
+	// source must still be valid Go, but is not necessarily gofmt-clean.
+	KeepGofmtGroups bool
+
+	// KeepBOM keeps a leading UTF-8 byte order mark in the output. By
+	// default it is dropped from stubbed files.
+	KeepBOM bool
+
+	// Funcs adds names of functions to match to the default set of raw
+	// syscall functions.
+	Funcs []string
+
+	// OnlyFuncs, if not empty, restricts matching to calls of these
+	// functions, overriding both the default set and Funcs. It also
+	// applies to the names given to Windows sites.
+	OnlyFuncs []string
+
+	// Mode selects how sites are stubbed. The zero value is ModeInsert.
+	Mode Mode
+
+	// ShimPkg is the import path of the shim package used by ModeShim.
+	ShimPkg string
+
+	// ShimIntTypes names the types, besides the predeclared integer types
+	// and those of shimIntTypes, that ModeShim converts results to.
+	ShimIntTypes []string
+
+	// PruneImports removes the imports left unused by stubbing from the
+	// output. Otherwise they are only listed in Result.UnusedImports.
+	PruneImports bool
+
+	// StrictPositions inserts statements into the AST and prints it again,
+	// instead of splicing lines into the source.
+	StrictPositions bool
+
+	// Annotate adds a comment recording the number of stubs at the start
+	// of stubbed files, or updates it.
+	Annotate bool
+
+	// SkipFuncs leaves the sites of the function declarations whose names
+	// match one of these path.Match patterns alone.
+	SkipFuncs []string
+
+	// Exclude holds patterns of files and directories left alone when
+	// processing directories, in the syntax of ignore files, relative to
+	// each directory. They come before the directory's own ignore file.
+	Exclude []string
+
+	// GOOS and GOARCH, if either is set, restrict stubbing to the files
+	// built for that target, as told by their names and build
+	// constraints. The other files are left alone, with Result.Excluded
+	// set. An empty GOOS then means js and an empty GOARCH wasm.
+	GOOS, GOARCH string
+
+	// Rules maps syscall function names to the Rule applied to their
+	// sites instead of InsertFunc.
+	Rules map[string]Rule
+
+	// Trace sets the Path of every site.
+	Trace bool
+
+	// Unmatched sets Result.Unmatched, to find functions that should be
+	// added to Funcs.
+	Unmatched bool
+
+	// InitPolicy selects how the sites of package init functions are
+	// handled. The zero value is InitPanic.
+	InitPolicy InitPolicy
+
+	// RenameStubbedFuncs renames the functions stubbed by ModeFuncBody or
+	// ModeEntry from X to X_wasmstub and declares forwarders under their
+	// names, so that the linker tells whether any of them is reachable.
+	// See renameStubbed. It has no effect in other modes.
+	RenameStubbedFuncs bool
+
+	// StubConditional stubs the sites in the right operand of && or ||
+	// like the others, although the inserted statement then runs even
+	// when the call would not. By default they are skipped.
+	StubConditional bool
+
+	// StubTag confines the stubs to js/wasm builds. The zero value
+	// stubs the file for every platform. See StubTag for the tradeoffs.
+	StubTag StubTag
+
+	// DedupPanics collapses consecutive panics inserted by this or older
+	// versions of the tool into one, in the current format if possible.
+	DedupPanics bool
+
+	// KeepUnformatted sets Result.Unformatted, to tell the changes made by
+	// format.Source apart from the inserted statements.
+	KeepUnformatted bool
+
+	// Observer, if not nil, is notified of progress.
+	Observer Observer
+
+	// Windows additionally matches the DLL proc calls used by
+	// x/sys/windows. See matchWindowsCall for the heuristic.
+	Windows bool
+}
+
+// DefaultInsert returns the panic statement inserted by default. Calls
+// spanning several lines are joined into one in the message.
+func DefaultInsert(site Site) (string, error) {
+	return fmt.Sprintf("panic(%q)", "syscall not supported in wasm: "+joinLines(site.Call)), nil
+}
+
+// joinLines joins the lines of the call text s, as in
+//
+//	SyscallN(
+//		proc.Addr(),
+//		a,
+//	)
+//
+// into SyscallN(proc.Addr(), a).
+func joinLines(s string) string {
+	var b strings.Builder
+	for line := range strings.SplitSeq(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		joined := b.String()
+		if strings.HasPrefix(line, ")") && strings.HasSuffix(joined, ",") {
+			b.Reset()
+			b.WriteString(joined[:len(joined)-1])
+		} else if b.Len() > 0 && !strings.HasSuffix(joined, "(") && !strings.HasPrefix(line, ")") {
+			b.WriteByte(' ')
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+// skip reports whether site is excluded from stubbing by o.
+func (o *Options) skip(site Site) bool {
+	if o == nil {
+		return false
+	}
+	exported := token.IsExported(site.Enclosing)
+	return o.ExportedOnly && !exported || o.OnlyUnexported && exported
+}
+
+func (o *Options) bestEffort() bool {
+	return o != nil && o.BestEffort
+}
+
+func (o *Options) skipTests() bool {
+	return o != nil && o.SkipTests
+}
+
+func (o *Options) keepGofmtGroups() bool {
+	return o != nil && o.KeepGofmtGroups
+}
+
+func (o *Options) mode() Mode {
+	if o == nil || o.Mode == "" {
+		return ModeInsert
+	}
+	return o.Mode
+}
+
+func (o *Options) pruneImports() bool {
+	return o != nil && o.PruneImports
+}
+
+func (o *Options) strictPositions() bool {
+	return o != nil && o.StrictPositions
+}
+
+func (o *Options) annotate() bool {
+	return o != nil && o.Annotate
+}
+
+func (o *Options) initPolicy() InitPolicy {
+	if o == nil || o.InitPolicy == "" {
+		return InitPanic
+	}
+	return o.InitPolicy
+}
+
+func (o *Options) renameStubbedFuncs() bool {
+	return o != nil && o.RenameStubbedFuncs && (o.mode() == ModeFuncBody || o.mode() == ModeEntry)
+}
+
+func (o *Options) stubConditional() bool {
+	return o != nil && o.StubConditional
+}
+
+func (o *Options) stubTag() StubTag {
+	if o == nil {
+		return ""
+	}
+	return o.StubTag
+}
+
+// target returns the build context of Options.GOOS and GOARCH, or nil if
+// neither is set.
+func (o *Options) target() *build.Context {
+	if o == nil || o.GOOS == "" && o.GOARCH == "" {
+		return nil
+	}
+	ctxt := targetContext(o.GOOS, o.GOARCH)
+	return &ctxt
+}
+
+func (o *Options) dedupPanics() bool {
+	return o != nil && o.DedupPanics
+}
+
+func (o *Options) unmatched() bool {
+	return o != nil && o.Unmatched
+}
+
+func (o *Options) keepBOM() bool {
+	return o != nil && o.KeepBOM
+}
+
+// rule returns the Rule for the sites of the function name, if any.
+func (o *Options) rule(name string) (Rule, bool) {
+	if o == nil {
+		return Rule{}, false
+	}
+	r, ok := o.Rules[name]
+	return r, ok
+}
+
+func (o *Options) insert(site Site) (string, error) {
+	if r, ok := o.rule(site.Func); ok {
+		return r.Insert(site)
+	}
+	insert := DefaultInsert
+	if o != nil && o.InsertFunc != nil {
+		insert = o.InsertFunc
+	} else if o.mode() == ModeErrno && returnsErrno(site) {
+		// Fail like the rewritten wrappers rather than panic.
+		return Rule{Action: ActionENOSYS}.Insert(site)
+	}
+	text, err := insert(site)
+	if err != nil || o.mode() != ModeShim {
+		return text, err
+	}
+	if shim, ok := shimInsert(site, path.Base(o.ShimPkg), o.ShimIntTypes); ok {
+		text = shim + "\n" + text
+	}
+	return text, nil
+}
+
+// stubPrefix starts the panic statements inserted by DefaultInsert,
+// ModeFuncBody and ModeEntry.
+const stubPrefix = `panic("syscall not supported in wasm: `
+
+var utf8BOM = []byte("\ufeff")
+
+var syscallFuncs = map[string]bool{
+	"Syscall":           true,
+	"Syscall6":          true,
+	"RawSyscall":        true,
+	"RawSyscall6":       true,
+	"SyscallNoError":    true,
+	"RawSyscallNoError": true,
+}
+
+// Result is the outcome of stubbing a single source file.
+type Result struct {
+	Sites     []Site // sites that had a statement inserted before them, one per statement
+	Internal  int    // sites skipped because of Options.ExportedOnly or OnlyUnexported
+	Tests     int    // sites skipped because of Options.SkipTests
+	Skipped   []Skip // sites that matched but could not be stubbed
+	Output    []byte // stubbed source; nil if no syscall call was found
+	Changed   bool   // whether Output differs from the source
+	Formatted bool   // whether Output was formatted with format.Source
+	NotGofmt  bool   // with KeepGofmtGroups, whether Output differs from gofmt's
+	FormatErr error  // *FormatError from format.Source when Formatted is false
+	ParseErr  error  // with Options.BestEffort, the *ParseError of a partially parsed source
+	Deduped   int    // redundant panics removed by Options.DedupPanics
+	Init      int    // sites in init functions stubbed, skipped or guarded by Options.InitPolicy
+	Excluded  bool   // whether the file is not built for Options.GOOS and GOARCH, and was left alone
+
+	// Variant is the stubbed source with Options.StubTag set to
+	// StubTagFiles, to be written to VariantPath. Output is then the
+	// source with its build constraint excluding js.
+	Variant     []byte
+	VariantPath string
+
+	// StaleAnnotation reports whether the source has an annotation, as
+	// written with Options.Annotate, whose count does not match its stubs.
+	StaleAnnotation bool
+
+	// UnusedImports holds the paths of the imports used by the source but
+	// not by the stubbed output. With Options.PruneImports they have been
+	// removed from Output.
+	UnusedImports []string
+
+	// Unmatched holds the calls of functions that look like syscall
+	// functions but were not matched. It is only set if Options.Unmatched
+	// is.
+	Unmatched []Unmatched
+
+	// Unformatted is the stubbed source before it was passed to
+	// format.Source. It is only set if Options.KeepUnformatted is.
+	Unformatted []byte
+
+	// Timing is the time spent in each phase of Stub.
+	Timing Timing
+}
+
+// Skip records a matched site that could not be stubbed.
+type Skip struct {
+	Site   Site
+	Reason string
+}
+
+// FindSites parses src and returns the syscall sites recognized by opts, in
+// source order, without modifying anything. It returns a *ParseError if src
+// cannot be parsed; with Options.BestEffort, the sites found in the partial
+// AST are returned with it.
+func FindSites(filename string, src []byte, opts *Options) ([]Site, error) {
+	return findSites(filename, src, opts, new(Result))
+}
+
+// Visit parses src and calls fn for each syscall site recognized by the
+// default Options, in source order, without modifying anything. Each site
+// has its positions, enclosing function and results set, so that linters
+// and other tools can reuse the detection of Stub; the positions have no
+// filename. Visit stops at the first error returned by fn and returns it, or
+// returns a *ParseError if src cannot be parsed. Use FindSites to pass a
+// filename or Options.
+func Visit(src []byte, fn func(site Site) error) error {
+	sites, err := FindSites("", src, nil)
+	if err != nil {
+		return err
+	}
+	for _, site := range sites {
+		if err := fn(site); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findSites is FindSites, recording the time spent parsing and inspecting
+// in res.Timing, with Options.Unmatched, the unmatched calls in
+// res.Unmatched, and whether the file is excluded by Options.GOOS and
+// GOARCH in res.Excluded.
+func findSites(filename string, src []byte, opts *Options, res *Result) ([]Site, error) {
+	if ctxt := opts.target(); ctxt != nil && !buildsFor(*ctxt, filename, src) {
+		res.Excluded = true
+		return nil, nil
+	}
+	timing := &res.Timing
+	start := time.Now()
+	// A FileSet per file costs little next to the AST; see
+	// BenchmarkStubTree.
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	timing.Parse = since(&start)
+	var parseErr error
+	if err != nil {
+		// ModeFuncBody and ModeEntry parse the source again to
+		// rewrite function bodies, so they need it to be valid.
+		if !opts.bestEffort() || node == nil || node.Name == nil || opts.mode() == ModeFuncBody || opts.mode() == ModeEntry {
+			return nil, &ParseError{Path: filename, Err: err}
+		}
+		parseErr = &ParseError{Path: filename, Err: err, Partial: true}
+	}
+	defer func() { timing.Inspect = since(&start) }()
+
+	m := newMatcher(node, opts)
+	off := offRegions(fset, node)
+	var sites []Site
+	for _, decl := range node.Decls {
+		var enclosing string
+		var results []string
+		var isInit bool
+		var lits []*ast.FuncLit
+		if fd, ok := decl.(*ast.FuncDecl); ok {
+			enclosing = fd.Name.Name
+			results = resultTypes(fd, fset, src)
+			isInit = fd.Recv == nil && fd.Name.Name == "init"
+		}
+		ast.Inspect(decl, func(n ast.Node) bool {
+			if lit, ok := n.(*ast.FuncLit); ok {
+				lits = append(lits, lit)
+			}
+			return true
+		})
+		inspectDecl(decl, m, func(pos token.Pos, call *ast.CallExpr, funcName string, terminal, conditional bool) {
+			site := Site{
+				File:        filename,
+				Func:        funcName,
+				Call:        extractCallFromAST(call, fset, src),
+				Pos:         fset.Position(pos),
+				CallPos:     fset.Position(call.Pos()),
+				Enclosing:   enclosing,
+				Package:     node.Name.Name,
+				Results:     results,
+				Terminal:    terminal,
+				Conditional: conditional,
+			}
+			site.Off = inRegions(site.Pos.Line, off)
+			site.Literal = slices.ContainsFunc(lits, func(lit *ast.FuncLit) bool { return lit.Pos() <= pos && pos < lit.End() })
+			site.Init = isInit && !site.Literal
+			site.Test = strings.HasSuffix(filename, "_test.go")
+			if opts != nil && opts.Trace {
+				site.Path = astPath(node, call)
+			}
+			sites = append(sites, site)
+		})
+	}
+	if opts.unmatched() {
+		res.Unmatched = unmatchedCalls(fset, node, m)
+	}
+	return sites, parseErr
+}
+
+// resultTypes returns the source text of the result types of fd, repeated
+// for each name sharing a type.
+func resultTypes(fd *ast.FuncDecl, fset *token.FileSet, src []byte) []string {
+	if fd.Type.Results == nil {
+		return nil
+	}
+	var types []string
+	for _, field := range fd.Type.Results.List {
+		typ := string(src[fset.Position(field.Type.Pos()).Offset:fset.Position(field.Type.End()).Offset])
+		for range max(len(field.Names), 1) {
+			types = append(types, typ)
+		}
+	}
+	return types
+}
+
+// Stub inserts the statement produced by opts before every syscall site in
+// src. If the spliced source cannot be formatted, Output holds the unformatted
+// source and FormatErr the reason.
+//
+// A statement containing several sites, such as
+// xs := []uintptr{Syscall(...), Syscall6(...)}, is guarded once, by the
+// statement of its first site: the panic makes the rest of the statement
+// unreachable, so the later sites are in neither Sites nor Skipped. Except
+// in ModeNop, which replaces every call, FindSites is the way to list them.
+func Stub(filename string, src []byte, opts *Options) (*Result, error) {
+	// The parser skips a leading byte order mark, but the columns it
+	// reports then no longer match the bytes of the first line.
+	orig := src
+	src = bytes.TrimPrefix(src, utf8BOM)
+	res := &Result{}
+	sites, err := findSites(filename, src, opts, res)
+	if err != nil {
+		if pe, ok := err.(*ParseError); !ok || !pe.Partial {
+			return nil, err
+		}
+		res.ParseErr = err
+	}
+	start := time.Now()
+	defer func() { res.Timing.Splice = since(&start) - res.Timing.Format }()
+
+	res.StaleAnnotation = staleAnnotation(src)
+	if len(sites) == 0 {
+		return res, nil
+	}
+	if opts.stubTag() == StubTagFiles && !buildsForJS(filename, src) {
+		// Including the files already split by an earlier run.
+		return res, nil
+	}
+
+	lines := bytes.Split(src, []byte("\n"))
+	// Insertions are collected first and applied in a single pass, as
+	// splicing each one into lines would be quadratic in the number of
+	// sites.
+	var insertions []insertion
+	var stmtInsertions []stmtInsertion
+	// stubbed holds the offsets of statements that already had a line
+	// inserted: one panic makes the rest of the statement unreachable.
+	stubbed := make(map[int]bool)
+	insertedLine := make(map[int]bool)
+
+	var replacements []replacement
+	// ModeNop replaces each call rather than guarding its statement.
+	perCall := opts.mode() == ModeNop
+	// ModeErrno rewrites the statements it recognizes, and stubs the other
+	// sites as usual.
+	var rewrites map[int]replacement
+	var rewritten []replacement
+	if opts.mode() == ModeErrno {
+		if rewrites, err = errnoRewrites(filename, src); err != nil {
+			return nil, err
+		}
+	}
+	// ModeEntry stubs function bodies once, whatever their number of sites.
+	var bodies []entryBody
+	entered := make(map[int]bool) // offsets of the bodies stubbed
+	if opts.mode() == ModeEntry {
+		if bodies, err = entryBodies(filename, src); err != nil {
+			return nil, err
+		}
+	}
+	// A line cannot be spliced before a statement that does not start its
+	// line, as in one-line function bodies, so such files take the AST
+	// path instead.
+	strict := opts.strictPositions() || slices.ContainsFunc(sites, func(s Site) bool { return !startsLine(src, s.Pos) })
+	if res.ParseErr != nil {
+		// The AST path needs a source that parses.
+		strict = false
+	}
+
+	for _, site := range sites {
+		pos := site.Pos
+		lineIdx := pos.Line - 1
+		// at is where lines are inserted: above the directive comments
+		// applying to the statement, if any, which must stay next to it.
+		at := lineIdx
+		for at > 0 && at <= len(lines) && isDirectiveLine(lines[at-1]) {
+			at--
+		}
+
+		if stubbed[pos.Offset] && !perCall {
+			continue
+		}
+		if insertedLine[lineIdx] && !strict && !perCall {
+			// Another statement on this line was already stubbed.
+			continue
+		}
+		if at > 0 && at <= len(lines) && bytes.Contains(lines[at-1], []byte(stubPrefix)) && !perCall {
+			continue
+		}
+
+		if site.Off {
+			res.Skipped = append(res.Skipped, Skip{Site: site, Reason: "disabled by //wasmstub:off"})
+			continue
+		}
+		if site.Terminal {
+			res.Skipped = append(res.Skipped, Skip{Site: site, Reason: "already terminal"})
+			continue
+		}
+		if site.Conditional && !perCall && !opts.stubConditional() {
+			// A panic before the statement would run even when the
+			// call does not. ModeNop replaces the call itself.
+			res.Skipped = append(res.Skipped, Skip{Site: site, Reason: "conditional on the left operand of && or ||"})
+			continue
+		}
+		if opts.skip(site) {
+			res.Internal++
+			continue
+		}
+		if opts.skipFunc(site.Enclosing) {
+			res.Skipped = append(res.Skipped, Skip{Site: site, Reason: fmt.Sprintf("in skipped function %s", site.Enclosing)})
+			continue
+		}
+		if site.Test && opts.skipTests() {
+			res.Tests++
+			continue
+		}
+		if r, ok := opts.rule(site.Func); ok && r.Action == ActionIgnore {
+			res.Skipped = append(res.Skipped, Skip{Site: site, Reason: "ignored by rule"})
+			continue
+		}
+		if site.Init {
+			switch policy := opts.initPolicy(); {
+			case policy == InitSkip:
+				res.Skipped = append(res.Skipped, Skip{Site: site, Reason: "in init, which runs when the package is loaded (see -init-policy)"})
+				res.Init++
+				continue
+			case policy == InitLazy && (opts.mode() == ModeFuncBody || opts.mode() == ModeEntry):
+				res.Skipped = append(res.Skipped, Skip{Site: site, Reason: fmt.Sprintf("the lazy init policy does not apply to mode %s", opts.mode())})
+				res.Init++
+				continue
+			}
+		}
+		if lineIdx < 0 || lineIdx > len(lines) {
+			res.Skipped = append(res.Skipped, Skip{Site: site, Reason: fmt.Sprintf("line index %d out of range [0, %d]", lineIdx, len(lines))})
+			continue
+		}
+		if opts.mode() == ModeFuncBody {
+			stubbed[pos.Offset] = true
+			res.Sites = append(res.Sites, site)
+			opts.observer().SiteStubbed(site)
+			continue
+		}
+		if opts.mode() == ModeEntry {
+			b := bodyAt(bodies, pos.Offset)
+			if b == nil {
+				res.Skipped = append(res.Skipped, Skip{Site: site, Reason: "entry mode only stubs function declarations"})
+				continue
+			}
+			if b.stubbed {
+				// Stubbed by an earlier run.
+				continue
+			}
+			if !entered[b.lbrace] {
+				replacements = append(replacements, replacement{offset: b.lbrace + 1, text: "\n" + entryPanic(b.name) + ";"})
+				entered[b.lbrace] = true
+			}
+			res.Sites = append(res.Sites, site)
+			opts.observer().SiteStubbed(site)
+			continue
+		}
+		if perCall {
+			lit, ok := nopFuncLit(site)
+			if !ok {
+				res.Skipped = append(res.Skipped, Skip{Site: site, Reason: "nop mode only replaces calls of raw syscall functions by name"})
+				continue
+			}
+			replacements = append(replacements, replacement{offset: site.CallPos.Offset, length: len(site.Func), text: lit})
+			res.Sites = append(res.Sites, site)
+			opts.observer().SiteStubbed(site)
+			continue
+		}
+		if r, ok := rewrites[site.CallPos.Offset]; ok {
+			rewritten = append(rewritten, r)
+			stubbed[pos.Offset] = true
+			res.Sites = append(res.Sites, site)
+			opts.observer().SiteStubbed(site)
+			continue
+		}
+		if res.ParseErr != nil && !startsLine(src, pos) {
+			res.Skipped = append(res.Skipped, Skip{Site: site, Reason: "does not start its line in a file with parse errors"})
+			continue
+		}
+		var text string
+		if site.Init && opts.initPolicy() == InitLazy {
+			text = lazyInsert(site)
+		} else if text, err = opts.insert(site); err != nil {
+			return nil, fmt.Errorf("%s: %w", pos, err)
+		}
+		if opts.stubTag() == StubTagGOOS {
+			text = guardInsert(text)
+		}
+		if insertedBefore(lines, at, text) {
+			// Stubbed by an earlier run with the same insertion.
+			continue
+		}
+
+		if strict {
+			stmtInsertions = append(stmtInsertions, stmtInsertion{offset: pos.Offset, text: text})
+		} else {
+			indent := indentAt(src, pos)
+			var newLines [][]byte
+			for _, l := range strings.Split(text, "\n") {
+				newLines = append(newLines, append(append([]byte{}, indent...), l...))
+			}
+			insertions = append(insertions, insertion{index: at, lines: newLines, site: site})
+		}
+		stubbed[pos.Offset] = true
+		insertedLine[lineIdx] = true
+		res.Sites = append(res.Sites, site)
+		opts.observer().SiteStubbed(site)
+	}
+
+	for _, site := range res.Sites {
+		if site.Init {
+			res.Init++
+		}
+	}
+
+	var modified []byte
+	if opts.mode() == ModeFuncBody {
+		modified, err = replaceFuncBodies(filename, src, res.Sites)
+	} else if perCall || opts.mode() == ModeEntry {
+		modified = replaceRanges(src, replacements)
+	} else if strict {
+		// Rewrites go first, as insertStmts prints the file again.
+		for i := range stmtInsertions {
+			stmtInsertions[i].offset = shiftOffset(stmtInsertions[i].offset, rewritten)
+		}
+		modified, err = insertStmts(filename, replaceRanges(src, rewritten), stmtInsertions)
+	} else {
+		slices.SortStableFunc(insertions, func(a, b insertion) int { return a.index - b.index })
+		n := len(lines)
+		var skipped []insertion
+		lines, insertions, skipped = spliceLines(lines, insertions)
+		for _, ins := range skipped {
+			res.Sites = slices.DeleteFunc(res.Sites, func(s Site) bool { return s.CallPos == ins.site.CallPos })
+			res.Skipped = append(res.Skipped, Skip{Site: ins.site, Reason: fmt.Sprintf("line index %d out of range [0, %d]", ins.index, n)})
+		}
+		// Rewrites go last, shifted by the lines inserted before them.
+		for i := range rewritten {
+			line := lineAt(src, rewritten[i].offset)
+			for _, ins := range insertions {
+				if ins.index <= line {
+					for _, l := range ins.lines {
+						rewritten[i].offset += len(l) + 1
+					}
+				}
+			}
+		}
+		modified = replaceRanges(bytes.Join(lines, []byte("\n")), rewritten)
+	}
+	if err == nil && opts.renameStubbedFuncs() {
+		modified, err = renameStubbed(filename, modified)
+	}
+	if err == nil && opts.mode() == ModeShim && len(res.Sites) > 0 {
+		modified, err = addImport(modified, opts.ShimPkg)
+	}
+	if err == nil && opts.stubTag() == StubTagGOOS && len(res.Sites) > 0 {
+		modified, err = addImport(modified, "runtime")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if opts.dedupPanics() {
+		modified, res.Deduped = dedupPanics(modified)
+	}
+	res.UnusedImports = newlyUnusedImports(src, modified)
+	if len(res.UnusedImports) > 0 && opts.pruneImports() {
+		if modified, err = pruneImports(modified, res.UnusedImports); err != nil {
+			return nil, err
+		}
+	}
+	if opts != nil && opts.KeepUnformatted {
+		res.Unformatted = modified
+	}
+	formatStart := time.Now()
+	formatted, err := format.Source(modified)
+	res.Timing.Format = time.Since(formatStart)
+	switch {
+	case err != nil:
+		res.Output = modified
+		res.FormatErr = &FormatError{Path: filename, Err: err}
+	case opts.keepGofmtGroups():
+		// The inserted lines are already indented like their statements,
+		// so the spliced source is kept as is and only checked against
+		// what gofmt would have produced.
+		res.Output = modified
+		res.NotGofmt = !bytes.Equal(formatted, modified)
+	default:
+		res.Output = formatted
+		res.Formatted = true
+	}
+	if opts.annotate() {
+		res.Output = annotate(res.Output)
+	}
+	if opts.keepBOM() && len(src) < len(orig) {
+		res.Output = append(append([]byte{}, utf8BOM...), res.Output...)
+	}
+	res.Changed = !bytes.Equal(res.Output, orig)
+	if opts.stubTag() == StubTagFiles && res.Changed {
+		res.Variant, res.VariantPath = res.Output, variantPath(filename)
+		if res.Output, err = excludeJS(orig); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// insertedBefore reports whether the lines of text, as inserted before
+// lines[at], are already there, inserted by an earlier run.
+func insertedBefore(lines [][]byte, at int, text string) bool {
+	want := strings.Split(text, "\n")
+	if at < len(want) || at > len(lines) {
+		return false
+	}
+	for i, line := range want {
+		if strings.TrimSpace(string(lines[at-len(want)+i])) != strings.TrimSpace(line) {
+			return false
+		}
+	}
+	return true
+}
+
+// inspectDecl calls match for every syscall call recognized by m in the
+// statements of decl, passing the position of the innermost statement
+// containing the call. Calls inside function literals belong to the
+// statements of the literal's body.
+func inspectDecl(decl ast.Decl, m *matcher, match func(pos token.Pos, call *ast.CallExpr, funcName string, terminal, conditional bool)) {
+	ast.Inspect(decl, func(n ast.Node) bool {
+		stmt, ok := n.(ast.Stmt)
+		if !ok {
+			return true
+		}
+		for _, expr := range stmtExprs(stmt) {
+			findCalls(expr, false, false, m, func(call *ast.CallExpr, funcName string, terminal, conditional bool) {
+				match(stmt.Pos(), call, funcName, terminal, conditional)
+			})
+		}
+		return true
+	})
+}
+
+// stmtExprs returns the expressions evaluated by stmt itself, not counting
+// those of nested statements.
+func stmtExprs(stmt ast.Stmt) []ast.Expr {
+	switch stmt := stmt.(type) {
+	case *ast.ExprStmt:
+		// Handle direct calls like: SyscallNoError(...)
+		return []ast.Expr{stmt.X}
+	case *ast.AssignStmt:
+		// Handle assignments like: _, _, e1 := Syscall6(...), including
+		// assignment operators like: n += count(Syscall(...))
+		return stmt.Rhs
+	case *ast.IncDecStmt:
+		// Handle calls in the operand, like: hits[index(Syscall(...))]++
+		return []ast.Expr{stmt.X}
+	case *ast.GoStmt:
+		// Handle goroutines calling or given syscalls, like:
+		// go obj.Method(Syscall(...)). The arguments are evaluated
+		// before the goroutine starts, the call itself in it.
+		return []ast.Expr{stmt.Call}
+	}
+	return nil
+}
+
+// findCalls calls match for every syscall call in expr, including calls
+// nested in the arguments of other calls or the elements of composite
+// literals, but not those in function literals. terminal reports whether
+// expr is an argument of panic or os.Exit, and conditional whether it is the
+// right operand of && or ||.
+func findCalls(expr ast.Expr, terminal, conditional bool, m *matcher, match func(call *ast.CallExpr, funcName string, terminal, conditional bool)) {
+	ast.Inspect(expr, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.BinaryExpr:
+			if n.Op == token.LAND || n.Op == token.LOR {
+				// The left operand is always evaluated.
+				findCalls(n.X, terminal, conditional, m, match)
+				findCalls(n.Y, terminal, true, m, match)
+				return false
+			}
+		case *ast.CallExpr:
+			if name, ok := m.matchCall(n); ok {
+				match(n, name, terminal, conditional)
+			}
+			if isTerminalCall(n) {
+				for _, arg := range n.Args {
+					findCalls(arg, true, conditional, m, match)
+				}
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// isTerminalCall reports whether call is a call of panic or os.Exit.
+func isTerminalCall(call *ast.CallExpr) bool {
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		return fun.Name == "panic"
+	case *ast.SelectorExpr:
+		x, ok := fun.X.(*ast.Ident)
+		return ok && x.Name == "os" && fun.Sel.Name == "Exit"
+	}
+	return false
+}
+
+// matcher recognizes syscall calls in a single file.
+type matcher struct {
+	opts *Options
+
+	funcs map[string]bool // names of functions called by identifier to match
+	only  map[string]bool // if not nil, the only names to match
+
+	// syscallPkg holds the names under which the file imports package
+	// syscall.
+	syscallPkg map[string]bool
+}
+
+func newMatcher(file *ast.File, opts *Options) *matcher {
+	m := &matcher{opts: opts, funcs: syscallFuncs, syscallPkg: make(map[string]bool)}
+	if opts != nil && len(opts.OnlyFuncs) > 0 {
+		m.only = make(map[string]bool)
+		for _, name := range opts.OnlyFuncs {
+			m.only[name] = true
+		}
+		m.funcs = m.only
+	} else if opts != nil && len(opts.Funcs) > 0 {
+		m.funcs = maps.Clone(syscallFuncs)
+		for _, name := range opts.Funcs {
+			m.funcs[name] = true
+		}
+	}
+	for _, imp := range file.Imports {
+		if imp.Path.Value != `"syscall"` {
+			continue
+		}
+		name := "syscall"
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		m.syscallPkg[name] = true
+	}
+	return m
+}
+
+// matchCall reports whether call is a syscall call and returns the name of
+// the called function.
+func (m *matcher) matchCall(call *ast.CallExpr) (string, bool) {
+	if ident := calleeIdent(call.Fun); ident != nil && m.funcs[ident.Name] && !isShadowed(ident) {
+		return ident.Name, true
+	}
+	if m.opts != nil && m.opts.Windows {
+		name, ok := matchWindowsCall(call, m.syscallPkg)
+		if ok && (m.only == nil || m.only[name]) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// calleeIdent returns the identifier naming the function called by fun,
+// looking through parentheses and generic instantiations such as
+// call[T](...) or call[K, V](...), or nil if fun is not such a name.
+func calleeIdent(fun ast.Expr) *ast.Ident {
+	for {
+		switch f := fun.(type) {
+		case *ast.Ident:
+			return f
+		case *ast.ParenExpr:
+			fun = f.X
+		case *ast.IndexExpr:
+			fun = f.X
+		case *ast.IndexListExpr:
+			fun = f.X
+		default:
+			return nil
+		}
+	}
+}
+
+// isShadowed reports whether ident was resolved by the parser to a local
+// declaration other than a function, such as a variable or parameter named
+// Syscall. Package-level functions declared in other files are unresolved
+// and declarations in the same file resolve to functions, so both are
+// still matched. This only uses the parser's scope resolution, which knows
+// nothing about other files or types, but it is enough to rule out
+// obvious false positives.
+func isShadowed(ident *ast.Ident) bool {
+	return ident.Obj != nil && ident.Obj.Kind != ast.Fun
+}
+
+func extractCallFromAST(call *ast.CallExpr, fset *token.FileSet, content []byte) string {
+	start := fset.Position(call.Pos()).Offset
+	end := fset.Position(call.End()).Offset
+
+	if start >= 0 && end <= len(content) && start < end {
+		return string(content[start:end])
+	}
+
+	// Fallback (shouldn't happen)
+	return "syscall"
+}
+
+// indentAt returns a copy of the indentation of the line holding the
+// statement at pos. It is taken from the original content rather than from
+// the spliced lines so that it stays correct after earlier insertions, which
+// matters when format.Source fails and the spliced output is written as is.
+func indentAt(content []byte, pos token.Position) []byte {
+	lineStart := pos.Offset - (pos.Column - 1)
+	if lineStart < 0 || pos.Offset > len(content) {
+		return []byte{}
+	}
+	return append([]byte{}, getIndentBytes(content[lineStart:pos.Offset])...)
+}
+
+// startsLine reports whether only indentation precedes pos on its line.
+func startsLine(content []byte, pos token.Position) bool {
+	lineStart := pos.Offset - (pos.Column - 1)
+	if lineStart < 0 || pos.Offset > len(content) {
+		return true
+	}
+	return len(getIndentBytes(content[lineStart:pos.Offset])) == pos.Offset-lineStart
+}
+
+func getIndentBytes(line []byte) []byte {
+	for i := 0; i < len(line); i++ {
+		if line[i] != ' ' && line[i] != '\t' {
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// insertion is a group of lines to insert before lines[index], for site.
+type insertion struct {
+	index int
+	lines [][]byte
+	site  Site
+}
+
+// spliceLines returns lines with the insertions applied, allocating the
+// result once, and the insertions applied and skipped. Insertions must be
+// sorted by index. An index of len(lines) appends; an insertion with an
+// index outside [0, len(lines)] is skipped rather than a panic, so that bad
+// offset arithmetic only costs its site, reported as skipped, and not the
+// run.
+func spliceLines(lines [][]byte, insertions []insertion) (result [][]byte, applied, skipped []insertion) {
+	n := len(lines)
+	for _, ins := range insertions {
+		if ins.index < 0 || ins.index > len(lines) {
+			skipped = append(skipped, ins)
+			continue
+		}
+		applied = append(applied, ins)
+		n += len(ins.lines)
+	}
+
+	result = make([][]byte, 0, n)
+	prev := 0
+	for _, ins := range applied {
+		result = append(result, lines[prev:ins.index]...)
+		result = append(result, ins.lines...)
+		prev = ins.index
+	}
+	return append(result, lines[prev:]...), applied, skipped
+}
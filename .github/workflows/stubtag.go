@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/build/constraint"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// A StubTag selects how stubs are confined to js/wasm builds, so that the
+// stubbed tree still runs its real syscalls on the other platforms.
+//
+// StubTagGOOS keeps a single file and tests runtime.GOOS at run time: the
+// code for every platform stays in one place, which keeps the diff against
+// upstream small, but the checks are compiled everywhere and the stubbed
+// statements count as reachable for the compiler and go vet. StubTagFiles
+// moves the stubs into a separate file constrained to js, as if written by
+// hand as a _js.go file: each build only compiles its own code, at the cost
+// of a second copy of every stubbed file to keep in sync with upstream.
+type StubTag string
+
+const (
+	// StubTagGOOS wraps every inserted statement in
+	// if runtime.GOOS == "js" { ... }. It only applies where the mode
+	// inserts a statement: with ModeInsert and ModeShim.
+	StubTagGOOS StubTag = "goos"
+
+	// StubTagFiles leaves the stubbed file as is apart from its build
+	// constraint, which gains && !js, and writes the stubbed source to a
+	// variant file whose name ends in _js.go. See variantPath. Files that
+	// do not build for js/wasm in the first place, such as those named
+	// _linux.go or constrained to //go:build linux, are left alone.
+	StubTagFiles StubTag = "files"
+)
+
+// parseStubTag returns the StubTag named s, or "" if s is empty.
+func parseStubTag(s string) (StubTag, error) {
+	switch t := StubTag(s); t {
+	case "", StubTagGOOS, StubTagFiles:
+		return t, nil
+	}
+	return "", fmt.Errorf("unknown stub tag %q (want goos or files)", s)
+}
+
+// guardLine starts the blocks inserted by StubTagGOOS.
+const guardLine = `if runtime.GOOS == "js" {`
+
+// guardInsert wraps the lines of text, as returned by Options.insert, in a
+// block run only on js.
+func guardInsert(text string) string {
+	return guardLine + "\n\t" + strings.ReplaceAll(text, "\n", "\n\t") + "\n}"
+}
+
+// variantSuffix is appended to the names of the files written by
+// StubTagFiles, before any _test suffix.
+const variantSuffix = "_wasmstub_js"
+
+// variantPath returns the path of the file StubTagFiles writes the stubbed
+// source of filename to: foo.go becomes foo_wasmstub_js.go and foo_test.go
+// foo_wasmstub_js_test.go. The _js suffix is what constrains it to js.
+func variantPath(filename string) string {
+	stem := strings.TrimSuffix(filename, ".go")
+	if stem, ok := strings.CutSuffix(stem, "_test"); ok {
+		return stem + variantSuffix + "_test.go"
+	}
+	return stem + variantSuffix + ".go"
+}
+
+// buildsForJS reports whether the file named filename with content src is
+// part of js/wasm builds, from its name and build constraint.
+func buildsForJS(filename string, src []byte) bool {
+	return buildsFor(wasmContext, filename, src)
+}
+
+// excludeJS returns src with its //go:build constraint C replaced by
+// (C) && !js, or with //go:build !js added if it has none. Any // +build
+// lines, superseded by //go:build since Go 1.17, are removed rather than
+// kept out of sync.
+func excludeJS(src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.PackageClauseOnly|parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	header := fset.Position(f.Package).Offset
+	var expr constraint.Expr = &constraint.NotExpr{X: &constraint.TagExpr{Tag: "js"}}
+	lines := bytes.SplitAfter(src[:header], []byte("\n"))
+	var out []byte
+	found := false
+	for _, line := range lines {
+		text := string(bytes.TrimSpace(line))
+		switch {
+		case constraint.IsGoBuild(text) && !found:
+			x, err := constraint.Parse(text)
+			if err != nil {
+				return nil, err
+			}
+			expr = &constraint.AndExpr{X: x, Y: expr}
+			out = append(out, "//go:build "+expr.String()+"\n"...)
+			found = true
+		case constraint.IsPlusBuild(text):
+		default:
+			out = append(out, line...)
+		}
+	}
+	if !found {
+		out = append([]byte("//go:build "+expr.String()+"\n\n"), out...)
+	}
+	return append(out, src[header:]...), nil
+}
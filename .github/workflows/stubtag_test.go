@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestStubTagGOOSIdempotent(t *testing.T) {
+	src, err := os.ReadFile("testdata/stub_tag_goos.input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &Options{StubTag: StubTagGOOS}
+	res, err := Stub("p.go", src, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := Stub("p.go", res.Output, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Changed {
+		t.Errorf("stubbing again changed the file:\n%s", again.Output)
+	}
+	out, n := Undo(res.Output)
+	if n != 3 || string(out) != string(src) {
+		t.Errorf("Undo removed %d stubs, leaving:\n%s\nwant 3, leaving:\n%s", n, out, src)
+	}
+}
+
+func TestStubTagFilesIdempotent(t *testing.T) {
+	src, err := os.ReadFile("testdata/stub_tag_files.input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &Options{StubTag: StubTagFiles}
+	res, err := Stub("p.go", src, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.VariantPath != "p_wasmstub_js.go" {
+		t.Errorf("VariantPath = %q, want p_wasmstub_js.go", res.VariantPath)
+	}
+	for name, out := range map[string][]byte{"p.go": res.Output, res.VariantPath: res.Variant} {
+		again, err := Stub(name, out, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again.Changed || again.Variant != nil {
+			t.Errorf("stubbing %s again changed it:\n%s", name, again.Output)
+		}
+	}
+}
+
+func TestBuildsForJS(t *testing.T) {
+	tests := []struct {
+		name, src string
+		want      bool
+	}{
+		{"p.go", "package p\n", true},
+		{"p_linux.go", "package p\n", false},
+		{"p_wasmstub_js.go", "package p\n", true},
+		{"p.go", "//go:build linux\n\npackage p\n", false},
+		{"p.go", "//go:build unix || js\n\npackage p\n", true},
+		{"p.go", "//go:build (unix || js) && !js\n\npackage p\n", false},
+	}
+	for _, tt := range tests {
+		if got := buildsForJS(tt.name, []byte(tt.src)); got != tt.want {
+			t.Errorf("buildsForJS(%q, %q) = %v, want %v", tt.name, tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestExcludeJS(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"package p\n", "//go:build !js\n\npackage p\n"},
+		{"//go:build linux\n// +build linux\n\npackage p\n", "//go:build linux && !js\n\npackage p\n"},
+		{"// Copyright.\n\n//go:build a || b\n\n// Package p.\npackage p\n", "// Copyright.\n\n//go:build (a || b) && !js\n\n// Package p.\npackage p\n"},
+	}
+	for _, tt := range tests {
+		got, err := excludeJS([]byte(tt.src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("excludeJS(%q) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestVariantPath(t *testing.T) {
+	for in, want := range map[string]string{
+		"dir/foo.go":      "dir/foo_wasmstub_js.go",
+		"dir/foo_test.go": "dir/foo_wasmstub_js_test.go",
+	} {
+		if got := variantPath(in); got != want {
+			t.Errorf("variantPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// runSummary is the object written by -summary-json: the totals of a run,
+// small enough to keep as a CI artifact for every build.
+type runSummary struct {
+	Files      int            `json:"files"`    // files processed
+	Modified   int            `json:"modified"` // files changed, or that would be with -check, -diff or -dry-run
+	Sites      int            `json:"sites"`
+	PerFunc    map[string]int `json:"per_func"` // stubbed sites per called function
+	Mode       Mode           `json:"mode"`
+	DurationMS int64          `json:"duration_ms"`
+	ExitStatus int            `json:"exit_status"`
+}
+
+// writeSummary writes to file the summary of a run that took duration and
+// exited with status. obs is nil if the run stopped before processing files.
+func writeSummary(file string, obs *cliObserver, mode Mode, duration time.Duration, status int) error {
+	s := runSummary{
+		PerFunc:    map[string]int{},
+		Mode:       mode,
+		DurationMS: duration.Milliseconds(),
+		ExitStatus: status,
+	}
+	if obs != nil {
+		s.Files = len(obs.processed)
+		s.Modified = obs.changed
+		s.Sites = obs.sites
+		for name, n := range obs.perFunc {
+			s.PerFunc[name] = n
+		}
+	}
+	data, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(file, append(data, '\n'))
+}
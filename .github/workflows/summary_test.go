@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSummaryJSON(t *testing.T) {
+	dir := filepath.Dir(writeTemp(t, "p.go", "package p\n\nfunc f() {\n\tSyscall(1, 2, 3)\n\tRawSyscall(1, 2, 3)\n}\n"))
+	writeFile(t, filepath.Join(dir, "q.go"), "package p\n")
+	out := filepath.Join(t.TempDir(), "summary.json")
+
+	if got := run([]string{"stub", "-log-level=error", "-check", "-summary-json", out, dir}); got != exitNeedsChange {
+		t.Fatalf("exit %d, want %d", got, exitNeedsChange)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got runSummary
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	got.DurationMS = 0
+	want := runSummary{
+		Files:      2,
+		Modified:   1,
+		Sites:      2,
+		PerFunc:    map[string]int{"Syscall": 1, "RawSyscall": 1},
+		Mode:       ModeInsert,
+		ExitStatus: exitNeedsChange,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got summary %+v, want %+v", got, want)
+	}
+}
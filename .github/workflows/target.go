@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"go/build"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// targetContext returns the build context of GOOS and GOARCH, with cgo
+// disabled as for wasm. An empty GOOS defaults to js and an empty GOARCH to
+// wasm.
+func targetContext(goos, goarch string) build.Context {
+	ctxt := build.Default
+	ctxt.GOOS = cmp.Or(goos, "js")
+	ctxt.GOARCH = cmp.Or(goarch, "wasm")
+	ctxt.CgoEnabled = false
+	return ctxt
+}
+
+// buildsFor reports whether the file named filename with content src is
+// part of the builds of ctxt, from its name and build constraint.
+func buildsFor(ctxt build.Context, filename string, src []byte) bool {
+	ctxt.OpenFile = func(string) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(src)), nil
+	}
+	name := filepath.Base(filename)
+	if !strings.HasSuffix(name, ".go") {
+		// Such as the .input files of the tests.
+		name += ".go"
+	}
+	ok, err := ctxt.MatchFile(filepath.Dir(filename), name)
+	return err == nil && ok
+}
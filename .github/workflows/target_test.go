@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestTarget(t *testing.T) {
+	const body = "\npackage p\n\nfunc f() {\n\tSyscall(1, 2, 3)\n}\n"
+	tests := []struct {
+		name, constraint string
+		opts             Options
+		excluded         bool
+	}{
+		{"p.go", "", Options{}, false},
+		{"p_linux.go", "", Options{}, false},
+		{"p_linux.go", "", Options{GOOS: "js"}, true},
+		{"p.go", "//go:build darwin\n", Options{GOOS: "linux", GOARCH: "arm"}, true},
+		{"p.go", "//go:build linux\n", Options{GOOS: "linux", GOARCH: "arm"}, false},
+		{"p_arm.go", "", Options{GOOS: "linux", GOARCH: "arm"}, false},
+		{"p_wasm.go", "", Options{GOARCH: "wasm"}, false},
+		{"p.go", "//go:build !wasm\n", Options{GOOS: "wasip1"}, true},
+	}
+	for _, tt := range tests {
+		res, err := Stub(tt.name, []byte(tt.constraint+body), &tt.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Excluded != tt.excluded || res.Changed == tt.excluded {
+			t.Errorf("%s with %q for %s/%s: excluded %v, changed %v; want excluded %v",
+				tt.name, tt.constraint, tt.opts.GOOS, tt.opts.GOARCH, res.Excluded, res.Changed, tt.excluded)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package selfcheck
+
+import "unsafe"
+
+func read(fd int, p []byte) (n int, err error) {
+	var _p0 unsafe.Pointer
+	if len(p) > 0 {
+		_p0 = unsafe.Pointer(&p[0])
+	} else {
+		_p0 = unsafe.Pointer(&_zero)
+	}
+	r0, _, e1 := Syscall(SYS_READ, uintptr(fd), uintptr(_p0), uintptr(len(p)))
+	n = int(r0)
+	if e1 != 0 {
+		err = errnoErr(e1)
+	}
+	return
+}
+
+func Getpid() (pid int) {
+	r0, _ := RawSyscallNoError(SYS_GETPID, 0, 0, 0)
+	pid = int(r0)
+	return
+}
+
+func Sync() {
+	Syscall(SYS_SYNC, 0, 0, 0)
+}
+
+func oneLine() { RawSyscall(SYS_SYNC, 0, 0, 0) }
+
+func literals() []uintptr {
+	xs := []uintptr{0, 0}
+	xs[0], _, _ = Syscall6(SYS_GETPID, 0, 0, 0, 0, 0, 0)
+	ys := [2]Errno{1: ENOSYS}
+	_, _, ys[0] = Syscall(
+		SYS_SYNC,
+		0,
+		0,
+		0,
+	)
+	return append(xs, uintptr(ys[0]))
+}
+
+func counted(hits map[uintptr]int) {
+	hits[uintptr(len(hits))]++
+	go Syscall(SYS_SYNC, 0, 0, 0)
+	defer func() {
+		Syscall(SYS_SYNC, 0, 0, 0)
+	}()
+}
+
+func directives() {
+	//go:nocheckptr
+	RawSyscall(SYS_SYNC, 0, 0, 0)
+}
+
+var pid uintptr
+
+func init() {
+	pid, _, _ = RawSyscall(SYS_GETPID, 0, 0, 0)
+}
@@ -0,0 +1,30 @@
+// Package selfcheck is the corpus of TestSelfCheck. It declares the raw
+// syscall functions and calls them in the contexts the tool stubs, so that
+// the stubbed package can be type-checked and vetted.
+package selfcheck
+
+type Errno uintptr
+
+func (e Errno) Error() string { return "errno" }
+
+const (
+	ENOSYS Errno = 38
+
+	SYS_READ   = 0
+	SYS_GETPID = 39
+	SYS_SYNC   = 162
+)
+
+var _zero uintptr
+
+func Syscall(trap, a1, a2, a3 uintptr) (r1, r2 uintptr, err Errno)              { return }
+func Syscall6(trap, a1, a2, a3, a4, a5, a6 uintptr) (r1, r2 uintptr, err Errno) { return }
+func RawSyscall(trap, a1, a2, a3 uintptr) (r1, r2 uintptr, err Errno)           { return }
+func RawSyscallNoError(trap, a1, a2, a3 uintptr) (r1, r2 uintptr)               { return }
+
+func errnoErr(e Errno) error {
+	if e == 0 {
+		return nil
+	}
+	return e
+}
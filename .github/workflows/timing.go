@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"time"
+)
+
+// Timing is the time Stub spent in each of its phases on one file.
+type Timing struct {
+	Parse   time.Duration // parsing the source
+	Inspect time.Duration // finding the sites in the AST
+	Splice  time.Duration // building the stubbed source
+	Format  time.Duration // format.Source
+}
+
+// Total returns the sum of the phases.
+func (t Timing) Total() time.Duration {
+	return t.Parse + t.Inspect + t.Splice + t.Format
+}
+
+func (t *Timing) add(u Timing) {
+	t.Parse += u.Parse
+	t.Inspect += u.Inspect
+	t.Splice += u.Splice
+	t.Format += u.Format
+}
+
+// since returns the time elapsed since *start and resets *start to now.
+func since(start *time.Time) time.Duration {
+	now := time.Now()
+	d := now.Sub(*start)
+	*start = now
+	return d
+}
+
+// slowestFiles is the number of files listed by -timing.
+const slowestFiles = 10
+
+// fileTiming is the Timing of one file processed by the command.
+type fileTiming struct {
+	path string
+	Timing
+}
+
+// printTimings writes the aggregate time of each phase over files, then the
+// n slowest files.
+func printTimings(w io.Writer, files []fileTiming, n int) {
+	var total Timing
+	for _, f := range files {
+		total.add(f.Timing)
+	}
+	fmt.Fprintf(w, "Timing over %d files: parse %v, inspect %v, splice %v, format %v, total %v\n",
+		len(files), total.Parse, total.Inspect, total.Splice, total.Format, total.Total())
+
+	slowest := slices.Clone(files)
+	slices.SortStableFunc(slowest, func(a, b fileTiming) int { return int(b.Total() - a.Total()) })
+	for _, f := range slowest[:min(n, len(slowest))] {
+		fmt.Fprintf(w, "  %v %s (parse %v, inspect %v, splice %v, format %v)\n",
+			f.Total(), f.path, f.Parse, f.Inspect, f.Splice, f.Format)
+	}
+}
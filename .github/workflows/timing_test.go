@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestPrintTimings(t *testing.T) {
+	files := []fileTiming{
+		{"a.go", Timing{Parse: 1 * time.Millisecond, Format: 2 * time.Millisecond}},
+		{"b.go", Timing{Parse: 4 * time.Millisecond, Inspect: 1 * time.Millisecond}},
+		{"c.go", Timing{Splice: 1 * time.Millisecond}},
+	}
+	var b bytes.Buffer
+	printTimings(&b, files, 2)
+	const want = `Timing over 3 files: parse 5ms, inspect 1ms, splice 1ms, format 2ms, total 9ms
+  5ms b.go (parse 4ms, inspect 1ms, splice 0s, format 0s)
+  3ms a.go (parse 1ms, inspect 0s, splice 0s, format 2ms)
+`
+	if got := b.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestStubTiming(t *testing.T) {
+	res, err := Stub("p.go", []byte("package p\n\nfunc f() {\n\tSyscall(1, 2, 3)\n}\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tm := res.Timing; tm.Parse <= 0 || tm.Format <= 0 || tm.Splice < 0 {
+		t.Errorf("got timing %+v, want positive parse and format durations", tm)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"reflect"
+	"strings"
+)
+
+// astPath returns the chain of nodes from file down to target, such as
+//
+//	File > FuncDecl(f) > BlockStmt > IfStmt.Init > AssignStmt > CallExpr(Syscall6)
+//
+// Compound statements are qualified with the field leading to the next node,
+// declarations and calls with their name. It returns nil if target is not in
+// file.
+func astPath(file *ast.File, target ast.Node) []string {
+	var stack []ast.Node
+	var found []ast.Node
+	ast.Inspect(file, func(n ast.Node) bool {
+		if found != nil {
+			return false
+		}
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return true
+		}
+		stack = append(stack, n)
+		if n == target {
+			found = append([]ast.Node(nil), stack...)
+			return false
+		}
+		return true
+	})
+
+	var path []string
+	for i, n := range found {
+		var child ast.Node
+		if i+1 < len(found) {
+			child = found[i+1]
+		}
+		path = append(path, nodeLabel(n, child))
+	}
+	return path
+}
+
+// nodeLabel returns the label of n in an AST path whose next node is child.
+func nodeLabel(n, child ast.Node) string {
+	name := strings.TrimPrefix(reflect.TypeOf(n).String(), "*ast.")
+	switch n := n.(type) {
+	case *ast.FuncDecl:
+		return fmt.Sprintf("%s(%s)", name, n.Name.Name)
+	case *ast.CallExpr:
+		if fun := callName(n); fun != "" {
+			return fmt.Sprintf("%s(%s)", name, fun)
+		}
+	case *ast.IfStmt, *ast.ForStmt, *ast.RangeStmt, *ast.SwitchStmt, *ast.TypeSwitchStmt,
+		*ast.SelectStmt, *ast.CaseClause, *ast.CommClause:
+		if field := fieldName(n, child); field != "" {
+			return name + "." + field
+		}
+	}
+	return name
+}
+
+// callName returns the name of the function called by call if it is an
+// identifier or a selector, and "" otherwise.
+func callName(call *ast.CallExpr) string {
+	if ident := calleeIdent(call.Fun); ident != nil {
+		return ident.Name
+	}
+	switch fun := call.Fun.(type) {
+	case *ast.SelectorExpr:
+		if x, ok := fun.X.(*ast.Ident); ok {
+			return x.Name + "." + fun.Sel.Name
+		}
+		return fun.Sel.Name
+	}
+	return ""
+}
+
+// fieldName returns the name of the field of parent holding child, directly
+// or as an element of a slice.
+func fieldName(parent, child ast.Node) string {
+	if child == nil {
+		return ""
+	}
+	v := reflect.ValueOf(parent).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		switch f.Kind() {
+		case reflect.Interface, reflect.Pointer:
+			if !f.IsNil() && f.Interface() == child {
+				return v.Type().Field(i).Name
+			}
+		case reflect.Slice:
+			for j := 0; j < f.Len(); j++ {
+				if el := f.Index(j); el.CanInterface() && el.Interface() == child {
+					return v.Type().Field(i).Name
+				}
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	const src = `package p
+
+func f() {
+	if _, _, e := Syscall6(1, 2, 3, 4, 5, 6, 7); e != 0 {
+		return
+	}
+	for i := 0; i < 3; i++ {
+		check(RawSyscall(1, 2, 3))
+	}
+}
+`
+	sites, err := FindSites("p.go", []byte(src), &Options{Trace: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"File > FuncDecl(f) > BlockStmt > IfStmt.Init > AssignStmt > CallExpr(Syscall6)",
+		"File > FuncDecl(f) > BlockStmt > ForStmt.Body > BlockStmt > ExprStmt > CallExpr(check) > CallExpr(RawSyscall)",
+	}
+	if len(sites) != len(want) {
+		t.Fatalf("got %d sites, want %d", len(sites), len(want))
+	}
+	for i, site := range sites {
+		if got := strings.Join(site.Path, " > "); got != want[i] {
+			t.Errorf("site %d path:\ngot  %s\nwant %s", i, got, want[i])
+		}
+	}
+
+	sites, err = FindSites("p.go", []byte(src), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sites[0].Path != nil {
+		t.Errorf("Path computed without Trace: %q", sites[0].Path)
+	}
+}
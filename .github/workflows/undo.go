@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// Undo removes the lines inserted by DefaultInsert and InitLazy from src,
+// along with the blocks of ModeShim before them and those of StubTagGOOS
+// wrapping them, and returns the result and the number of stubs removed. A
+// stub is only removed if the following line calls the function named in
+// its message, so that bodies replaced by ModeFuncBody and panics inserted
+// by ModeEntry are left alone. The imports left unused, of the shim package
+// or runtime, are removed too, so that stubbing and undoing round-trips.
+func Undo(src []byte) ([]byte, int) {
+	lines := bytes.SplitAfter(src, []byte("\n"))
+	var kept [][]byte
+	n := 0
+	for i := 0; i < len(lines); i++ {
+		if strings.TrimSpace(string(lines[i])) == guardLine && i+2 < len(lines) &&
+			strings.TrimSpace(string(lines[i+2])) == "}" && undoable(lines, i+1, i+3) {
+			n++
+			i += 2
+			continue
+		}
+		// The statement may follow directive comments, which Stub
+		// inserts above.
+		if undoable(lines, i, i+1) {
+			n++
+			kept = kept[:shimStart(kept, lines[i])]
+			continue
+		}
+		kept = append(kept, lines[i])
+	}
+	out := bytes.Join(kept, nil)
+	if n == 0 {
+		return out, 0
+	}
+	// Removing the stubs themselves never leaves an import unused.
+	if unused := newlyUnusedImports(src, out); len(unused) > 0 {
+		// gofmt drops the blank lines left by the imports.
+		if pruned, err := pruneImports(out, unused); err == nil {
+			if formatted, err := format.Source(pruned); err == nil {
+				out = formatted
+			}
+		}
+	}
+	return out, n
+}
+
+// shimStart returns the index of the first line of the if statement of
+// ModeShim ending kept, as inserted before the stub line, or len(kept) if
+// there is none.
+func shimStart(kept [][]byte, stub []byte) int {
+	indent := string(getIndentBytes(stub))
+	last := len(kept) - 1
+	if last < 0 || string(kept[last]) != indent+"}\n" {
+		return len(kept)
+	}
+	for j := last - 1; j >= 0; j-- {
+		line := string(kept[j])
+		rest, ok := strings.CutPrefix(line, indent)
+		if !ok || rest == "" || rest == "\n" {
+			break
+		}
+		if rest[0] == '\t' || rest[0] == ' ' {
+			// Inside the block.
+			continue
+		}
+		if strings.HasPrefix(rest, "if ") && strings.Contains(rest, ".Available(") && strings.HasSuffix(rest, "{\n") {
+			return j
+		}
+		break
+	}
+	return len(kept)
+}
+
+// undoable reports whether lines[i] is a stub whose call is made by the
+// statement at lines[next], past any directive comments.
+func undoable(lines [][]byte, i, next int) bool {
+	text := strings.TrimSpace(string(lines[i]))
+	for next < len(lines) && isDirectiveLine(lines[next]) {
+		next++
+	}
+	call, ok := strings.CutPrefix(text, stubPrefix)
+	if !ok {
+		call, ok = strings.CutPrefix(text, lazyPrefix)
+	}
+	if !ok || next >= len(lines) {
+		return false
+	}
+	name, _, _ := strings.Cut(call, "(")
+	return name != "" && bytes.Contains(lines[next], []byte(name+"("))
+}
+
+// undoDirectory runs Undo on every Go file under dir and returns the total
+// number of lines removed. With diff, the changes are printed rather than
+// written.
+func undoDirectory(dir string, diff bool) (int, error) {
+	total := 0
+	err := walkGoFiles(dir, func(path string, _ fs.DirEntry) error {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		out, n := Undo(content)
+		if n == 0 {
+			return nil
+		}
+		total += n
+		if diff {
+			os.Stdout.Write(unifiedDiff(path+".orig", path, content, out, 3))
+			return nil
+		}
+		if err := writeFileAtomic(path, out); err != nil {
+			return fmt.Errorf("processing %s: %w", path, err)
+		}
+		logger.infof("Processed: %s", path)
+		return nil
+	})
+	return total, err
+}
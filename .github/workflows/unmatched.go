@@ -0,0 +1,64 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"regexp"
+)
+
+// syscallLikeRE matches the names of functions that probably make raw
+// syscalls, such as rawVforkSyscall or the sysvicall6 of Solaris, which a
+// plain "syscall" would miss.
+var syscallLikeRE = regexp.MustCompile(`(?i)sys.*call`)
+
+// Unmatched is a call of a function whose name matches syscallLikeRE but
+// that the matcher did not recognize.
+type Unmatched struct {
+	Name string // name of the called function
+	Pos  token.Position
+}
+
+// unmatchedCalls returns the calls in file, including those in function
+// literals, of functions whose name looks like that of a syscall function
+// but which m does not match. Calls of local variables, such as a
+// parameter named syscallFn, are left out.
+func unmatchedCalls(fset *token.FileSet, file *ast.File, m *matcher) []Unmatched {
+	var calls []Unmatched
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		var ident *ast.Ident
+		switch fun := call.Fun.(type) {
+		case *ast.SelectorExpr:
+			ident = fun.Sel
+		default:
+			ident = calleeIdent(fun)
+		}
+		if ident == nil || !syscallLikeRE.MatchString(ident.Name) || isShadowed(ident) {
+			return true
+		}
+		if _, ok := m.matchCall(call); !ok {
+			calls = append(calls, Unmatched{Name: ident.Name, Pos: fset.Position(call.Pos())})
+		}
+		return true
+	})
+	return calls
+}
+
+// logUnmatched logs calls and adds them to counts, the number of calls per
+// name.
+func logUnmatched(calls []Unmatched, counts map[string]int) {
+	for _, u := range calls {
+		logger.infof("%s: %s looks like a syscall function but is not matched (see -funcs)", u.Pos, u.Name)
+		counts[u.Name]++
+	}
+}
+
+// summarizeUnmatched logs the totals accumulated by logUnmatched, if any.
+func summarizeUnmatched(counts map[string]int) {
+	if len(counts) > 0 {
+		logger.infof("Unmatched syscall-like calls: %s", formatCounts(counts))
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestUnmatched(t *testing.T) {
+	const src = `package p
+
+func f(syscallFn func()) {
+	Syscall(1, 2, 3)
+	sysvicall6(4, 5, 6)
+	syscallFn()
+	go func() { rawVforkSyscall(7) }()
+	unix.SyscallN(8)
+}
+`
+	res, err := Stub("p.go", []byte(src), &Options{Unmatched: true, Funcs: []string{"SyscallN"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, u := range res.Unmatched {
+		names = append(names, u.Name)
+	}
+	if want := []string{"sysvicall6", "rawVforkSyscall", "SyscallN"}; !slices.Equal(names, want) {
+		t.Errorf("got unmatched %v, want %v", names, want)
+	}
+	if res.Unmatched[0].Pos.Line != 5 {
+		t.Errorf("sysvicall6 at %v, want line 5", res.Unmatched[0].Pos)
+	}
+
+	res, err = Stub("p.go", []byte(src), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Unmatched != nil {
+		t.Errorf("without Options.Unmatched, got %v", res.Unmatched)
+	}
+}
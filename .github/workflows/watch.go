@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// fileState is what watch compares to detect that a file changed.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// watch polls the Go files under dir every interval and processes those that
+// were created or modified, once they have not changed for debounce, until
+// stop is closed. Files present when watch starts are assumed to have been
+// processed already. Once watching, errors are logged rather than returned,
+// and files removed while being looked at are skipped.
+//
+// Polling is used instead of file system notifications to keep the tool
+// free of dependencies; its cost is one walk of the tree per interval.
+func (r *runner) watch(dir string, interval, debounce time.Duration, stop <-chan struct{}) error {
+	known, err := scanGoFiles(dir, r.opts.excludeRules())
+	if err != nil {
+		return err
+	}
+	pending := make(map[string]time.Time) // path -> time of last change seen
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+
+		current, err := scanGoFiles(dir, r.opts.excludeRules())
+		if err != nil {
+			// Try again at the next tick, as if nothing changed.
+			logger.errorf("watching %s: %v", dir, err)
+			continue
+		}
+		now := time.Now()
+		for path, st := range current {
+			if old, ok := known[path]; !ok || old != st {
+				pending[path] = now
+			}
+		}
+		known = current
+
+		for path, changed := range pending {
+			if now.Sub(changed) < debounce {
+				continue
+			}
+			delete(pending, path)
+			if _, ok := current[path]; !ok {
+				continue // removed while pending
+			}
+			if _, err := r.processFile(path); errors.Is(err, fs.ErrNotExist) {
+				continue // removed since the scan
+			} else if err != nil {
+				logger.errorf("processing %s: %v", path, err)
+			}
+			// Record our own write so that it is not seen as a change.
+			if st, err := statFile(path); err == nil {
+				known[path] = st
+			}
+		}
+	}
+}
+
+// scanGoFiles returns the state of every Go file under dir not excluded by
+// exclude or ignore files. Files removed since their directory was read are
+// skipped, and those that cannot be examined are logged and skipped, so
+// that one of them does not stop the scan of the others.
+func scanGoFiles(dir string, exclude []ignoreRule) (map[string]fileState, error) {
+	files := make(map[string]fileState)
+	err := walkGoFilesExcluding(dir, exclude, func(path string, d fs.DirEntry) error {
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			logger.errorf("watching %s: %v", path, err)
+			return nil
+		}
+		files[path] = fileState{info.ModTime(), info.Size()}
+		return nil
+	})
+	return files, err
+}
+
+func statFile(path string) (fileState, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}, err
+	}
+	return fileState{info.ModTime(), info.Size()}, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.go")
+	writeFile(t, existing, "package p\n\nfunc f() {\n\tSyscall(1, 2, 3)\n}\n")
+
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- new(runner).watch(dir, 10*time.Millisecond, 30*time.Millisecond, stop)
+	}()
+
+	// Give watch time to record the initial state, then add a file.
+	time.Sleep(50 * time.Millisecond)
+	added := filepath.Join(dir, "added.go")
+	writeFile(t, added, "package p\n\nfunc g() {\n\tRawSyscall(1, 2, 3)\n}\n")
+
+	waitStubbed(t, added)
+	close(stop)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(readFile(t, existing), "panic(") {
+		t.Error("unchanged file present at start was processed")
+	}
+}
+
+func TestWatchLogsErrors(t *testing.T) {
+	defer func(w io.Writer) { logger.w = w }(logger.w)
+	logger.w = io.Discard
+
+	dir := t.TempDir()
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- new(runner).watch(dir, 10*time.Millisecond, 30*time.Millisecond, stop)
+	}()
+
+	// An ignore file that cannot be read fails the scans while it is
+	// there, without ending the watch.
+	time.Sleep(50 * time.Millisecond)
+	bad := filepath.Join(dir, ignoreFile)
+	if err := os.Mkdir(bad, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := os.Remove(bad); err != nil {
+		t.Fatal(err)
+	}
+	added := filepath.Join(dir, "added.go")
+	writeFile(t, added, "package p\n\nfunc g() {\n\tRawSyscall(1, 2, 3)\n}\n")
+
+	waitStubbed(t, added)
+	close(stop)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitStubbed waits for watch to stub the file at path.
+func waitStubbed(t *testing.T, path string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(readFile(t, path), "panic(") {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s was not stubbed", path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"go/ast"
+	"strings"
+)
+
+// windowsSyscallFuncs are the functions of package syscall used by
+// x/sys/windows to call DLL procedures by address.
+var windowsSyscallFuncs = map[string]bool{
+	"SyscallN":  true,
+	"Syscall":   true,
+	"Syscall6":  true,
+	"Syscall9":  true,
+	"Syscall12": true,
+	"Syscall15": true,
+	"Syscall18": true,
+}
+
+// matchWindowsCall reports whether call looks like a DLL procedure call as
+// made by x/sys/windows, returning the name used for the site. syscallPkg
+// holds the names under which package syscall is imported.
+//
+//	syscall.SyscallN(procX.Addr(), ...)   // "SyscallN"
+//	procX.Call(...)                      // "Call"
+//
+// Without type information the receiver of Call cannot be checked to be a
+// *LazyProc or *Proc, so the heuristic relies on naming instead: the
+// receiver must be an identifier or field whose name starts with "proc"
+// (in any case), as in the generated zsyscall_windows.go. Procs stored
+// under other names, such as the "p" receiver inside dll_windows.go, are
+// not matched, and unrelated values named proc* that have a Call method
+// are.
+func matchWindowsCall(call *ast.CallExpr, syscallPkg map[string]bool) (string, bool) {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return "", false
+	}
+	switch x := sel.X.(type) {
+	case *ast.Ident:
+		if syscallPkg[x.Name] && x.Obj == nil && windowsSyscallFuncs[sel.Sel.Name] {
+			return sel.Sel.Name, true
+		}
+		if sel.Sel.Name == "Call" && isProcName(x.Name) {
+			return "Call", true
+		}
+	case *ast.SelectorExpr:
+		if sel.Sel.Name == "Call" && isProcName(x.Sel.Name) {
+			return "Call", true
+		}
+	}
+	return "", false
+}
+
+func isProcName(name string) bool {
+	return strings.HasPrefix(strings.ToLower(name), "proc")
+}
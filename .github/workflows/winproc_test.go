@@ -0,0 +1,81 @@
+package main
+
+import "testing"
+
+func TestWindowsCalls(t *testing.T) {
+	const src = `package windows
+
+import "syscall"
+
+func GetTickCount64() (ms uint64) {
+	r0, _, _ := syscall.SyscallN(procGetTickCount64.Addr())
+	ms = uint64(r0)
+	return
+}
+
+func beep() {
+	procMessageBeep.Call(0)
+	d.procBeep.Call(1)
+	p.Call(2)
+	other.SyscallN(3)
+}
+`
+	const want = `package windows
+
+import "syscall"
+
+func GetTickCount64() (ms uint64) {
+	panic("syscall not supported in wasm: syscall.SyscallN(procGetTickCount64.Addr())")
+	r0, _, _ := syscall.SyscallN(procGetTickCount64.Addr())
+	ms = uint64(r0)
+	return
+}
+
+func beep() {
+	panic("syscall not supported in wasm: procMessageBeep.Call(0)")
+	procMessageBeep.Call(0)
+	panic("syscall not supported in wasm: d.procBeep.Call(1)")
+	d.procBeep.Call(1)
+	p.Call(2)
+	other.SyscallN(3)
+}
+`
+	res, err := Stub("w.go", []byte(src), &Options{Windows: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(res.Output); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	sites, err := FindSites("w.go", []byte(src), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sites) != 0 {
+		t.Errorf("without Windows, got %d sites, want 0", len(sites))
+	}
+}
+
+func TestWindowsRenamedSyscallImport(t *testing.T) {
+	const src = `package windows
+
+import sc "syscall"
+
+func f() {
+	sc.SyscallN(procA.Addr())
+	syscall.SyscallN(procB.Addr())
+}
+
+func g(sc notSyscall) {
+	sc.SyscallN(procC.Addr())
+}
+`
+	sites, err := FindSites("w.go", []byte(src), &Options{Windows: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sites) != 1 || sites[0].Call != "sc.SyscallN(procA.Addr())" {
+		t.Errorf("got sites %+v, want only sc.SyscallN(procA.Addr())", sites)
+	}
+}
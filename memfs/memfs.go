@@ -0,0 +1,430 @@
+// Package memfs implements an in-memory file system for programs compiled
+// to wasm, where there is no file system to load assets from, save high
+// scores or keep configuration in.
+//
+// An FS is used directly, through io/fs or, on linux targets such as the
+// wasm targets of TinyGo, through the file syscalls of
+// golang.org/x/sys/unix once Register routes them to it with
+// golang.org/x/sys/sysjs. Persist keeps its content in a Store, such as the
+// IndexedDB database returned by IndexedDB in the browser.
+//
+// Names are those of io/fs, slash-separated and unrooted, such as
+// "saves/scores.txt": see fs.ValidPath. There are no permissions: the
+// modes of files and directories are recorded, but not enforced.
+package memfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// An FS is an in-memory file system. It is safe for concurrent use.
+type FS struct {
+	mu    sync.Mutex
+	root  *node
+	store Store // see Persist
+}
+
+// A node is a file or directory of an FS.
+type node struct {
+	mode     fs.FileMode
+	modTime  time.Time
+	data     []byte           // of files
+	children map[string]*node // of directories
+}
+
+// New returns an empty FS.
+func New() *FS {
+	return &FS{root: &node{mode: fs.ModeDir | 0755, modTime: time.Now(), children: make(map[string]*node)}}
+}
+
+// clean returns the name in fsys of the path p, relative to the root of
+// fsys whether rooted or not.
+func clean(p string) string {
+	if p = strings.TrimPrefix(path.Clean("/"+p), "/"); p == "" {
+		return "."
+	}
+	return p
+}
+
+// checkName returns an error for the operation op if name is not valid for
+// fs.ValidPath.
+func checkName(op, name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return nil
+}
+
+// lookup returns the node at the clean path name. fsys.mu must be held.
+func (fsys *FS) lookup(op, name string) (*node, error) {
+	n := fsys.root
+	if name == "" || name == "." {
+		return n, nil
+	}
+	for elem := range strings.SplitSeq(name, "/") {
+		if !n.mode.IsDir() {
+			return nil, &fs.PathError{Op: op, Path: name, Err: syscall.ENOTDIR}
+		}
+		child, ok := n.children[elem]
+		if !ok {
+			return nil, &fs.PathError{Op: op, Path: name, Err: syscall.ENOENT}
+		}
+		n = child
+	}
+	return n, nil
+}
+
+// parent returns the directory containing the clean path name and the last
+// element of name. fsys.mu must be held.
+func (fsys *FS) parent(op, name string) (*node, string, error) {
+	if name == "" || name == "." {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: syscall.EEXIST}
+	}
+	dir, elem := path.Split(name)
+	d, err := fsys.lookup(op, strings.TrimSuffix(dir, "/"))
+	if err != nil {
+		return nil, "", err
+	}
+	if !d.mode.IsDir() {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: syscall.ENOTDIR}
+	}
+	return d, elem, nil
+}
+
+// Mkdir creates the directory name with mode perm.
+func (fsys *FS) Mkdir(name string, perm fs.FileMode) error {
+	if err := checkName("mkdir", name); err != nil {
+		return err
+	}
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	d, elem, err := fsys.parent("mkdir", name)
+	if err != nil {
+		return err
+	}
+	if _, ok := d.children[elem]; ok {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: syscall.EEXIST}
+	}
+	d.children[elem] = &node{mode: fs.ModeDir | perm.Perm(), modTime: time.Now(), children: make(map[string]*node)}
+	d.modTime = time.Now()
+	return fsys.save()
+}
+
+// MkdirAll creates the directory name and any missing parents with mode
+// perm, like os.MkdirAll.
+func (fsys *FS) MkdirAll(name string, perm fs.FileMode) error {
+	if err := checkName("mkdir", name); err != nil {
+		return err
+	}
+	if name == "." {
+		return nil
+	}
+	var dir string
+	for elem := range strings.SplitSeq(name, "/") {
+		dir = path.Join(dir, elem)
+		err := fsys.Mkdir(dir, perm)
+		if err == nil || errors.Is(err, fs.ErrExist) {
+			if fi, err := fsys.Stat(dir); err != nil {
+				return err
+			} else if !fi.IsDir() {
+				return &fs.PathError{Op: "mkdir", Path: dir, Err: syscall.ENOTDIR}
+			}
+			continue
+		}
+		return err
+	}
+	return nil
+}
+
+// Remove removes the file or empty directory name.
+func (fsys *FS) Remove(name string) error {
+	if err := checkName("remove", name); err != nil {
+		return err
+	}
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	d, elem, err := fsys.parent("remove", name)
+	if err != nil {
+		return err
+	}
+	n, ok := d.children[elem]
+	if !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: syscall.ENOENT}
+	}
+	if len(n.children) > 0 {
+		return &fs.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+	}
+	delete(d.children, elem)
+	d.modTime = time.Now()
+	return fsys.save()
+}
+
+// ReadFile returns the content of the file name.
+func (fsys *FS) ReadFile(name string) ([]byte, error) {
+	if err := checkName("open", name); err != nil {
+		return nil, err
+	}
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	n, err := fsys.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if n.mode.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: syscall.EISDIR}
+	}
+	return slices.Clone(n.data), nil
+}
+
+// WriteFile writes data to the file name, creating it with mode perm if
+// needed, like os.WriteFile.
+func (fsys *FS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	f, err := fsys.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	return err
+}
+
+// Stat returns a FileInfo describing the file or directory name.
+func (fsys *FS) Stat(name string) (fs.FileInfo, error) {
+	if err := checkName("stat", name); err != nil {
+		return nil, err
+	}
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	n, err := fsys.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return n.info(path.Base(name)), nil
+}
+
+// ReadDir returns the entries of the directory name, sorted by name. It
+// implements fs.ReadDirFS.
+func (fsys *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if err := checkName("readdir", name); err != nil {
+		return nil, err
+	}
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	n, err := fsys.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !n.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: syscall.ENOTDIR}
+	}
+	return n.entries(), nil
+}
+
+func (n *node) entries() []fs.DirEntry {
+	var entries []fs.DirEntry
+	for _, elem := range slices.Sorted(maps.Keys(n.children)) {
+		entries = append(entries, fs.FileInfoToDirEntry(n.children[elem].info(elem)))
+	}
+	return entries
+}
+
+// Open opens the file or directory name for reading. It implements fs.FS.
+func (fsys *FS) Open(name string) (fs.File, error) {
+	f, err := fsys.OpenFile(name, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// OpenFile opens the file or directory name with the os.O_* flags flag,
+// creating it with mode perm if needed with os.O_CREATE, like os.OpenFile.
+func (fsys *FS) OpenFile(name string, flag int, perm fs.FileMode) (*File, error) {
+	if err := checkName("open", name); err != nil {
+		return nil, err
+	}
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	n, err := fsys.lookup("open", name)
+	switch {
+	case err == nil && flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: syscall.EEXIST}
+	case errors.Is(err, fs.ErrNotExist) && flag&os.O_CREATE != 0:
+		d, elem, err := fsys.parent("open", name)
+		if err != nil {
+			return nil, err
+		}
+		n = &node{mode: perm.Perm(), modTime: time.Now()}
+		d.children[elem] = n
+		d.modTime = n.modTime
+	case err != nil:
+		return nil, err
+	}
+	writable := flag&(os.O_WRONLY|os.O_RDWR) != 0
+	if n.mode.IsDir() && writable {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: syscall.EISDIR}
+	}
+	if flag&os.O_TRUNC != 0 && writable {
+		n.data = nil
+		n.modTime = time.Now()
+	}
+	return &File{fsys: fsys, n: n, name: name, flag: flag}, nil
+}
+
+// fileInfo implements fs.FileInfo for a node.
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (n *node) info(name string) fileInfo {
+	return fileInfo{name: name, size: int64(len(n.data)), mode: n.mode, modTime: n.modTime}
+}
+
+func (fi fileInfo) Name() string       { return fi.name }
+func (fi fileInfo) Size() int64        { return fi.size }
+func (fi fileInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi fileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi fileInfo) Sys() any           { return nil }
+
+// A File is a file or directory opened with OpenFile. It implements
+// fs.File, io.ReadWriteSeeker and fs.ReadDirFile.
+type File struct {
+	fsys    *FS
+	n       *node
+	name    string
+	flag    int
+	offset  int64
+	dirRead int // entries already returned by ReadDir
+	closed  bool
+	dirty   bool // whether the file was written since it was opened
+}
+
+func (f *File) check(op string, write bool) error {
+	switch {
+	case f.closed:
+		return &fs.PathError{Op: op, Path: f.name, Err: fs.ErrClosed}
+	case write && f.flag&(os.O_WRONLY|os.O_RDWR) == 0, !write && f.flag&os.O_WRONLY != 0:
+		return &fs.PathError{Op: op, Path: f.name, Err: syscall.EBADF}
+	}
+	return nil
+}
+
+// Read reads from the file at its offset.
+func (f *File) Read(p []byte) (int, error) {
+	f.fsys.mu.Lock()
+	defer f.fsys.mu.Unlock()
+	if err := f.check("read", false); err != nil {
+		return 0, err
+	}
+	if f.n.mode.IsDir() {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: syscall.EISDIR}
+	}
+	if f.offset >= int64(len(f.n.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.n.data[f.offset:])
+	f.offset += int64(n)
+	return n, nil
+}
+
+// Write writes to the file at its offset, or at its end with os.O_APPEND.
+func (f *File) Write(p []byte) (int, error) {
+	f.fsys.mu.Lock()
+	defer f.fsys.mu.Unlock()
+	if err := f.check("write", true); err != nil {
+		return 0, err
+	}
+	if f.flag&os.O_APPEND != 0 {
+		f.offset = int64(len(f.n.data))
+	}
+	if end := f.offset + int64(len(p)); end > int64(len(f.n.data)) {
+		f.n.data = append(f.n.data, make([]byte, end-int64(len(f.n.data)))...)
+	}
+	copy(f.n.data[f.offset:], p)
+	f.offset += int64(len(p))
+	f.n.modTime = time.Now()
+	f.dirty = true
+	return len(p), nil
+}
+
+// Seek sets the offset of the next Read or Write, like os.File.Seek.
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	f.fsys.mu.Lock()
+	defer f.fsys.mu.Unlock()
+	if f.closed {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrClosed}
+	}
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += int64(len(f.n.data))
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: syscall.EINVAL}
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+// Stat returns a FileInfo describing the file.
+func (f *File) Stat() (fs.FileInfo, error) {
+	f.fsys.mu.Lock()
+	defer f.fsys.mu.Unlock()
+	if f.closed {
+		return nil, &fs.PathError{Op: "stat", Path: f.name, Err: fs.ErrClosed}
+	}
+	return f.n.info(path.Base(f.name)), nil
+}
+
+// ReadDir reads the entries of the directory, like os.File.ReadDir.
+func (f *File) ReadDir(count int) ([]fs.DirEntry, error) {
+	f.fsys.mu.Lock()
+	defer f.fsys.mu.Unlock()
+	if f.closed {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: fs.ErrClosed}
+	}
+	if !f.n.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: syscall.ENOTDIR}
+	}
+	entries := f.n.entries()[min(f.dirRead, len(f.n.children)):]
+	if count > 0 {
+		if len(entries) == 0 {
+			return nil, io.EOF
+		}
+		entries = entries[:min(count, len(entries))]
+	}
+	f.dirRead += len(entries)
+	return entries, nil
+}
+
+// Close closes the file, saving the FS to its Store if the file was
+// written.
+func (f *File) Close() error {
+	f.fsys.mu.Lock()
+	defer f.fsys.mu.Unlock()
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+	if f.dirty || f.flag&os.O_CREATE != 0 {
+		return f.fsys.save()
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package memfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFS(t *testing.T) {
+	fsys := New()
+	if err := fsys.MkdirAll("assets/levels", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.WriteFile("assets/levels/1.txt", []byte("#####\n#@ $#\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.WriteFile("scores", []byte("alice 42\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(fsys, "assets/levels/1.txt", "scores"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := fsys.ReadFile("assets/levels/1.txt")
+	if err != nil || string(data) != "#####\n#@ $#\n" {
+		t.Errorf("ReadFile = %q, %v", data, err)
+	}
+	fi, err := fsys.Stat("scores")
+	if err != nil || fi.Name() != "scores" || fi.Size() != 9 || fi.Mode() != 0600 {
+		t.Errorf("Stat = %v, %v; want scores of 9 bytes with mode 0600", fi, err)
+	}
+}
+
+func TestFileOffsets(t *testing.T) {
+	fsys := New()
+	f, err := fsys.OpenFile("f", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(f, "hello")
+	if _, err := f.Seek(1, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(f, "EL")
+	f.Seek(7, io.SeekStart)
+	io.WriteString(f, "!")
+	f.Close()
+	if data, _ := fsys.ReadFile("f"); string(data) != "hELlo\x00\x00!" {
+		t.Errorf("after writes at offsets: %q", data)
+	}
+
+	f, _ = fsys.OpenFile("f", os.O_WRONLY|os.O_APPEND, 0)
+	io.WriteString(f, "?")
+	if _, err := f.Read(make([]byte, 1)); !errors.Is(err, syscall.EBADF) {
+		t.Errorf("Read of a write-only file: got %v, want EBADF", err)
+	}
+	f.Close()
+	if _, err := f.Write(nil); !errors.Is(err, fs.ErrClosed) {
+		t.Errorf("Write after Close: got %v, want ErrClosed", err)
+	}
+	if data, _ := fsys.ReadFile("f"); string(data) != "hELlo\x00\x00!?" {
+		t.Errorf("after append: %q", data)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	fsys := New()
+	fsys.MkdirAll("d/e", 0755)
+	fsys.WriteFile("f", nil, 0644)
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"ReadFile missing", second(fsys.ReadFile("missing")), syscall.ENOENT},
+		{"ReadFile dir", second(fsys.ReadFile("d")), syscall.EISDIR},
+		{"Stat through file", second(fsys.Stat("f/g")), syscall.ENOTDIR},
+		{"Mkdir existing", fsys.Mkdir("d", 0755), syscall.EEXIST},
+		{"Mkdir missing parent", fsys.Mkdir("x/y", 0755), syscall.ENOENT},
+		{"MkdirAll through file", fsys.MkdirAll("f/g", 0755), syscall.ENOTDIR},
+		{"Remove non-empty", fsys.Remove("d"), syscall.ENOTEMPTY},
+		{"OpenFile exclusive", second(fsys.OpenFile("f", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)), syscall.EEXIST},
+		{"OpenFile dir for writing", second(fsys.OpenFile("d", os.O_WRONLY, 0)), syscall.EISDIR},
+		{"Open rooted", second(fsys.Open("/f")), fs.ErrInvalid},
+		{"WriteFile with dot-dot", fsys.WriteFile("d/../g", nil, 0644), fs.ErrInvalid},
+	}
+	for _, tt := range tests {
+		if !errors.Is(tt.err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.err, tt.want)
+		}
+	}
+	if err := fsys.Remove("d/e"); err != nil {
+		t.Errorf("Remove empty directory: %v", err)
+	}
+}
+
+func second[T any](_ T, err error) error { return err }
+
+// mapStore is a Store kept in memory.
+type mapStore struct {
+	snapshot []byte
+	saves    int
+}
+
+func (s *mapStore) Load() ([]byte, error) { return s.snapshot, nil }
+
+func (s *mapStore) Save(snapshot []byte) error {
+	s.snapshot = snapshot
+	s.saves++
+	return nil
+}
+
+func TestPersist(t *testing.T) {
+	store := &mapStore{}
+	fsys := New()
+	fsys.WriteFile("assets", []byte("not persisted"), 0644)
+	if err := fsys.Persist(store); err != nil {
+		t.Fatal(err)
+	}
+	if store.saves != 0 {
+		t.Errorf("Persist with an empty store saved %d times", store.saves)
+	}
+	fsys.MkdirAll("save/slot1", 0755)
+	fsys.WriteFile("save/slot1/scores", []byte("bob 7\n"), 0644)
+	if _, err := fsys.ReadFile("save/slot1/scores"); err != nil {
+		t.Fatal(err)
+	}
+	if store.saves != 3 {
+		t.Errorf("got %d saves after two Mkdirs and a WriteFile, want 3", store.saves)
+	}
+
+	restored := New()
+	if err := restored.Persist(store); err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(restored, "assets", "save/slot1/scores"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := restored.ReadFile("save/slot1/scores"); string(data) != "bob 7\n" {
+		t.Errorf("restored scores = %q", data)
+	}
+
+	if err := New().Persist(&mapStore{snapshot: []byte(`[{"path":"a/b"}]`)}); err == nil {
+		t.Error("Persist of a snapshot with an orphan entry succeeded")
+	}
+}
@@ -0,0 +1,267 @@
+package memfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/sysjs"
+	"golang.org/x/sys/unix"
+)
+
+// Register routes the file syscalls of golang.org/x/sys/unix stubbed with
+// -mode=shim to fsys, through golang.org/x/sys/sysjs: openat, read, write,
+// close, mkdirat, unlinkat and, on amd64, arm64, riscv64, 386 and arm,
+// fstat and fstatat. Functions such as unix.Open, unix.Read and unix.Mkdir
+// then work on fsys.
+//
+// The current directory is the root of fsys. The file descriptors returned
+// by openat start at 3; read, write, close and fstat on other descriptors,
+// such as stdin and stdout, are passed on to the handlers registered before
+// Register, if any, and fail with EBADF otherwise.
+//
+// Register replaces the file syscalls routed to an FS by a previous call.
+func Register(fsys *FS) {
+	b := &bridge{fsys: fsys, files: make(map[uintptr]*File), next: 3, prev: make(map[uintptr]sysjs.SyscallHandler)}
+	handlers := map[uintptr]sysjs.HandlerFunc{
+		unix.SYS_OPENAT:   b.openat,
+		unix.SYS_READ:     b.read,
+		unix.SYS_WRITE:    b.write,
+		unix.SYS_CLOSE:    b.close,
+		unix.SYS_MKDIRAT:  b.mkdirat,
+		unix.SYS_UNLINKAT: b.unlinkat,
+	}
+	if fstat, fstatat, ok := statTraps(); ok {
+		handlers[fstat] = b.fstat
+		handlers[fstatat] = b.fstatat
+	}
+	registered.Lock()
+	defer registered.Unlock()
+	for trap, h := range handlers {
+		if registered.bridge == nil {
+			b.prev[trap] = sysjs.Lookup(trap)
+		} else {
+			b.prev[trap] = registered.bridge.prev[trap]
+		}
+		sysjs.Register(trap, h)
+	}
+	registered.bridge = b
+}
+
+// registered holds the bridge of the last call to Register.
+var registered struct {
+	sync.Mutex
+	bridge *bridge
+}
+
+// A bridge implements syscalls on an FS.
+type bridge struct {
+	fsys *FS
+	prev map[uintptr]sysjs.SyscallHandler // by trap
+
+	mu    sync.Mutex
+	files map[uintptr]*File // by file descriptor
+	next  uintptr
+}
+
+// file returns the file open as fd, or nil.
+func (b *bridge) file(fd uintptr) *File {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.files[fd]
+}
+
+// pass passes a syscall on fd, which is not a file of b, on to the
+// previous handler of trap.
+func (b *bridge) pass(trap uintptr, args []uintptr) (r1, r2 uintptr, err error) {
+	if h := b.prev[trap]; h != nil {
+		return h.Syscall(trap, args...)
+	}
+	return 0, 0, syscall.EBADF
+}
+
+// resolve returns the path of the file named by the path argument p
+// relative to the directory file descriptor dirfd.
+func (b *bridge) resolve(dirfd, p uintptr) (string, error) {
+	name := cString(p)
+	if path.IsAbs(name) || int(int32(dirfd)) == unix.AT_FDCWD {
+		return clean(name), nil
+	}
+	dir := b.file(dirfd)
+	if dir == nil {
+		return "", syscall.EBADF
+	}
+	return clean(path.Join(dir.name, name)), nil
+}
+
+// openat(dirfd, path, flags, mode)
+func (b *bridge) openat(trap uintptr, args ...uintptr) (r1, r2 uintptr, err error) {
+	name, err := b.resolve(args[0], args[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	flags := int(args[2])
+	f, err := b.fsys.OpenFile(name, flags&(os.O_RDONLY|os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_EXCL|os.O_TRUNC), fs.FileMode(args[3]).Perm())
+	if err != nil {
+		return 0, 0, errno(err)
+	}
+	if flags&unix.O_DIRECTORY != 0 && !f.n.mode.IsDir() {
+		f.Close()
+		return 0, 0, syscall.ENOTDIR
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	fd := b.next
+	b.next++
+	b.files[fd] = f
+	return fd, 0, nil
+}
+
+// read(fd, p, n)
+func (b *bridge) read(trap uintptr, args ...uintptr) (r1, r2 uintptr, err error) {
+	f := b.file(args[0])
+	if f == nil {
+		return b.pass(trap, args)
+	}
+	n, err := f.Read(buffer(args[1], args[2]))
+	if err == io.EOF {
+		err = nil
+	}
+	return uintptr(n), 0, errno(err)
+}
+
+// write(fd, p, n)
+func (b *bridge) write(trap uintptr, args ...uintptr) (r1, r2 uintptr, err error) {
+	f := b.file(args[0])
+	if f == nil {
+		return b.pass(trap, args)
+	}
+	n, err := f.Write(buffer(args[1], args[2]))
+	return uintptr(n), 0, errno(err)
+}
+
+// close(fd)
+func (b *bridge) close(trap uintptr, args ...uintptr) (r1, r2 uintptr, err error) {
+	b.mu.Lock()
+	f := b.files[args[0]]
+	delete(b.files, args[0])
+	b.mu.Unlock()
+	if f == nil {
+		return b.pass(trap, args)
+	}
+	return 0, 0, errno(f.Close())
+}
+
+// mkdirat(dirfd, path, mode)
+func (b *bridge) mkdirat(trap uintptr, args ...uintptr) (r1, r2 uintptr, err error) {
+	name, err := b.resolve(args[0], args[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	return 0, 0, errno(b.fsys.Mkdir(name, fs.FileMode(args[2]).Perm()))
+}
+
+// unlinkat(dirfd, path, flags)
+func (b *bridge) unlinkat(trap uintptr, args ...uintptr) (r1, r2 uintptr, err error) {
+	name, err := b.resolve(args[0], args[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	fi, err := b.fsys.Stat(name)
+	switch {
+	case err != nil:
+		return 0, 0, errno(err)
+	case args[2]&unix.AT_REMOVEDIR != 0 && !fi.IsDir():
+		return 0, 0, syscall.ENOTDIR
+	case args[2]&unix.AT_REMOVEDIR == 0 && fi.IsDir():
+		return 0, 0, syscall.EISDIR
+	}
+	return 0, 0, errno(b.fsys.Remove(name))
+}
+
+// fstat(fd, stat)
+func (b *bridge) fstat(trap uintptr, args ...uintptr) (r1, r2 uintptr, err error) {
+	f := b.file(args[0])
+	if f == nil {
+		return b.pass(trap, args)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, 0, errno(err)
+	}
+	fillStat((*unix.Stat_t)(ptr(args[1])), fi)
+	return 0, 0, nil
+}
+
+// fstatat(dirfd, path, stat, flags)
+func (b *bridge) fstatat(trap uintptr, args ...uintptr) (r1, r2 uintptr, err error) {
+	name, err := b.resolve(args[0], args[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	fi, err := b.fsys.Stat(name)
+	if err != nil {
+		return 0, 0, errno(err)
+	}
+	fillStat((*unix.Stat_t)(ptr(args[2])), fi)
+	return 0, 0, nil
+}
+
+func fillStat(st *unix.Stat_t, fi fs.FileInfo) {
+	*st = unix.Stat_t{}
+	st.Mode = uint32(fi.Mode().Perm())
+	if fi.IsDir() {
+		st.Mode |= unix.S_IFDIR
+	} else {
+		st.Mode |= unix.S_IFREG
+	}
+	st.Nlink = 1
+	st.Size = fi.Size()
+	st.Blksize = 4096
+	st.Blocks = (fi.Size() + 511) / 512
+	st.Mtim = unix.NsecToTimespec(fi.ModTime().UnixNano())
+	st.Atim = st.Mtim
+	st.Ctim = st.Mtim
+}
+
+// errno returns the syscall.Errno of err, as returned by the methods of FS
+// and File, or nil.
+func errno(err error) error {
+	var e syscall.Errno
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &e):
+		return e
+	case errors.Is(err, fs.ErrClosed):
+		return syscall.EBADF
+	}
+	return syscall.EIO
+}
+
+// ptr returns the pointer passed to a syscall as the argument arg.
+func ptr(arg uintptr) unsafe.Pointer {
+	return *(*unsafe.Pointer)(unsafe.Pointer(&arg))
+}
+
+// buffer returns the buffer of n bytes at p.
+func buffer(p, n uintptr) []byte {
+	if n == 0 {
+		return nil
+	}
+	return unsafe.Slice((*byte)(ptr(p)), n)
+}
+
+// cString returns the NUL-terminated string at p.
+func cString(p uintptr) string {
+	var n uintptr
+	for *(*byte)(unsafe.Add(ptr(p), n)) != 0 {
+		n++
+	}
+	return string(buffer(p, n))
+}
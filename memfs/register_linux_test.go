@@ -0,0 +1,104 @@
+package memfs
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+	"unsafe"
+
+	"golang.org/x/sys/sysjs"
+	"golang.org/x/sys/unix"
+)
+
+// cstrs keeps the strings returned by cstr reachable, as the uintptr
+// arguments do not.
+var cstrs [][]byte
+
+// cstr returns s as a NUL-terminated string argument.
+func cstr(s string) uintptr {
+	b := append([]byte(s), 0)
+	cstrs = append(cstrs, b)
+	return uintptr(unsafe.Pointer(&b[0]))
+}
+
+// fdcwd is AT_FDCWD as a syscall argument.
+var fdcwd = func() uintptr { fd := unix.AT_FDCWD; return uintptr(fd) }()
+
+func TestRegister(t *testing.T) {
+	var stdout []byte
+	sysjs.Register(unix.SYS_WRITE, sysjs.HandlerFunc(func(trap uintptr, args ...uintptr) (r1, r2 uintptr, err error) {
+		if args[0] != 1 {
+			return 0, 0, syscall.EBADF
+		}
+		stdout = append(stdout, buffer(args[1], args[2])...)
+		return args[2], 0, nil
+	}))
+	fsys := New()
+	Register(fsys)
+	defer func() {
+		for _, trap := range []uintptr{unix.SYS_OPENAT, unix.SYS_READ, unix.SYS_WRITE, unix.SYS_CLOSE, unix.SYS_MKDIRAT, unix.SYS_UNLINKAT} {
+			sysjs.Register(trap, nil)
+		}
+		if fstat, fstatat, ok := statTraps(); ok {
+			sysjs.Register(fstat, nil)
+			sysjs.Register(fstatat, nil)
+		}
+	}()
+
+	if _, _, err := sysjs.Do(unix.SYS_MKDIRAT, fdcwd, cstr("/saves"), 0755); err != nil {
+		t.Fatalf("mkdirat: %v", err)
+	}
+	dirfd, _, err := sysjs.Do(unix.SYS_OPENAT, fdcwd, cstr("saves"), unix.O_RDONLY|unix.O_DIRECTORY, 0)
+	if err != nil {
+		t.Fatalf("openat of a directory: %v", err)
+	}
+	fd, _, err := sysjs.Do(unix.SYS_OPENAT, dirfd, cstr("scores"), unix.O_WRONLY|unix.O_CREAT|unix.O_CLOEXEC, 0644)
+	if err != nil {
+		t.Fatalf("openat relative to a directory: %v", err)
+	}
+	data := []byte("carol 99\n")
+	if n, _, err := sysjs.Do(unix.SYS_WRITE, fd, uintptr(unsafe.Pointer(&data[0])), uintptr(len(data))); n != uintptr(len(data)) || err != nil {
+		t.Fatalf("write = %d, %v", n, err)
+	}
+	if _, _, err := sysjs.Do(unix.SYS_CLOSE, fd); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if got, _ := fsys.ReadFile("saves/scores"); string(got) != string(data) {
+		t.Errorf("file written through syscalls holds %q, want %q", got, data)
+	}
+
+	if _, _, err := sysjs.Do(unix.SYS_WRITE, 1, uintptr(unsafe.Pointer(&data[0])), 5); err != nil || string(stdout) != "carol" {
+		t.Errorf("write to stdout: got %q, %v; want it passed on to the previous handler", stdout, err)
+	}
+	if _, _, err := sysjs.Do(unix.SYS_READ, 42, uintptr(unsafe.Pointer(&data[0])), 1); !errors.Is(err, syscall.EBADF) {
+		t.Errorf("read of an unknown descriptor: got %v, want EBADF", err)
+	}
+
+	if fstat, _, ok := statTraps(); ok {
+		var st unix.Stat_t
+		fd, _, _ = sysjs.Do(unix.SYS_OPENAT, fdcwd, cstr("saves/scores"), unix.O_RDONLY, 0)
+		if _, _, err := sysjs.Do(fstat, fd, uintptr(unsafe.Pointer(&st))); err != nil {
+			t.Fatalf("fstat: %v", err)
+		}
+		if st.Size != int64(len(data)) || st.Mode != unix.S_IFREG|0644 {
+			t.Errorf("fstat: got size %d and mode %o, want %d and %o", st.Size, st.Mode, len(data), unix.S_IFREG|0644)
+		}
+		buf := make([]byte, 64)
+		if n, _, err := sysjs.Do(unix.SYS_READ, fd, uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf))); string(buf[:n]) != string(data) || err != nil {
+			t.Errorf("read = %q, %v", buf[:n], err)
+		}
+		if n, _, err := sysjs.Do(unix.SYS_READ, fd, uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf))); n != 0 || err != nil {
+			t.Errorf("read at end of file = %d, %v; want 0, nil", n, err)
+		}
+	}
+
+	if _, _, err := sysjs.Do(unix.SYS_UNLINKAT, fdcwd, cstr("saves"), unix.AT_REMOVEDIR); !errors.Is(err, syscall.ENOTEMPTY) {
+		t.Errorf("unlinkat of a non-empty directory: got %v, want ENOTEMPTY", err)
+	}
+	if _, _, err := sysjs.Do(unix.SYS_UNLINKAT, fdcwd, cstr("saves/scores"), 0); err != nil {
+		t.Errorf("unlinkat: %v", err)
+	}
+	if _, _, err := sysjs.Do(unix.SYS_OPENAT, fdcwd, cstr("saves/scores"), unix.O_RDONLY, 0); !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("openat of a removed file: got %v, want ENOENT", err)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build linux && (386 || arm)
+
+package memfs
+
+import "golang.org/x/sys/unix"
+
+// statTraps returns the syscalls behind unix.Fstat and unix.Fstatat.
+func statTraps() (fstat, fstatat uintptr, ok bool) {
+	return unix.SYS_FSTAT64, unix.SYS_FSTATAT64, true
+}
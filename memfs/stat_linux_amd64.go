@@ -0,0 +1,8 @@
+package memfs
+
+import "golang.org/x/sys/unix"
+
+// statTraps returns the syscalls behind unix.Fstat and unix.Fstatat.
+func statTraps() (fstat, fstatat uintptr, ok bool) {
+	return unix.SYS_FSTAT, unix.SYS_NEWFSTATAT, true
+}
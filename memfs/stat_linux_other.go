@@ -0,0 +1,9 @@
+//go:build linux && !amd64 && !arm64 && !riscv64 && !386 && !arm
+
+package memfs
+
+// statTraps reports that unix.Fstat and unix.Fstatat are not routed to an
+// FS: they use statx or other syscalls on these platforms.
+func statTraps() (fstat, fstatat uintptr, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,105 @@
+package memfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"time"
+)
+
+// A Store keeps the content of an FS across runs of a program, as a
+// snapshot encoded by the FS. See Persist.
+type Store interface {
+	// Load returns the last snapshot saved, or nil if there is none.
+	Load() ([]byte, error)
+
+	// Save replaces the snapshot.
+	Save(snapshot []byte) error
+}
+
+// Persist loads the content of fsys from the snapshot in store, if there
+// is one, and then saves a new snapshot to store after every change: when a
+// file written or created is closed, and after Mkdir and Remove.
+//
+// The whole FS is saved every time, which suits the small files of games,
+// such as high scores and configuration, better than large assets: those
+// are best written to an FS that is not persisted, or before calling
+// Persist.
+func (fsys *FS) Persist(store Store) error {
+	data, err := store.Load()
+	if err != nil {
+		return err
+	}
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	if data != nil {
+		root, err := decode(data)
+		if err != nil {
+			return err
+		}
+		fsys.root = root
+	}
+	fsys.store = store
+	return nil
+}
+
+// save saves fsys to its Store, if any. fsys.mu must be held.
+func (fsys *FS) save() error {
+	if fsys.store == nil {
+		return nil
+	}
+	return fsys.store.Save(fsys.root.encode())
+}
+
+// An entry is a file or directory in a snapshot, which is a JSON array of
+// the entries of an FS, parents first.
+type entry struct {
+	Path    string      `json:"path"`
+	Mode    fs.FileMode `json:"mode"`
+	ModTime time.Time   `json:"mod_time"`
+	Data    []byte      `json:"data,omitempty"`
+}
+
+func (n *node) encode() []byte {
+	var entries []entry
+	var walk func(name string, n *node)
+	walk = func(name string, n *node) {
+		for _, elem := range slices.Sorted(maps.Keys(n.children)) {
+			child := n.children[elem]
+			p := path.Join(name, elem)
+			entries = append(entries, entry{Path: p, Mode: child.mode, ModTime: child.modTime, Data: child.data})
+			walk(p, child)
+		}
+	}
+	walk("", n)
+	data, err := json.Marshal(entries)
+	if err != nil {
+		panic(err) // entries always marshal
+	}
+	return data
+}
+
+func decode(data []byte) (*node, error) {
+	var entries []entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("memfs: bad snapshot: %w", err)
+	}
+	root := New().root
+	nodes := map[string]*node{".": root}
+	for _, e := range entries {
+		dir, ok := nodes[path.Dir(e.Path)]
+		if !ok || !dir.mode.IsDir() || !fs.ValidPath(e.Path) || e.Path == "." {
+			return nil, fmt.Errorf("memfs: bad snapshot: unexpected entry %q", e.Path)
+		}
+		n := &node{mode: e.Mode, modTime: e.ModTime, data: e.Data}
+		if n.mode.IsDir() {
+			n.children = make(map[string]*node)
+		}
+		dir.children[path.Base(e.Path)] = n
+		nodes[e.Path] = n
+	}
+	return root, nil
+}
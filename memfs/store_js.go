@@ -0,0 +1,108 @@
+//go:build js && wasm
+
+package memfs
+
+import (
+	"errors"
+	"syscall/js"
+)
+
+// snapshotKey is the key of the snapshot in the object store of an
+// IndexedDB database.
+const snapshotKey = "snapshot"
+
+// indexedDB is a Store kept in an object store of an IndexedDB database.
+type indexedDB struct {
+	db    js.Value
+	store string
+}
+
+// IndexedDB returns a Store keeping snapshots in the object store named
+// store of the IndexedDB database name of the page, creating them if
+// needed.
+//
+// IndexedDB is asynchronous: IndexedDB and the Load and Save methods of the
+// Store wait for the browser to complete their requests, and so must not be
+// called from the JavaScript event loop, such as in a js.Func callback.
+func IndexedDB(name, store string) (Store, error) {
+	factory := js.Global().Get("indexedDB")
+	if factory.IsUndefined() {
+		return nil, errors.New("memfs: IndexedDB not available")
+	}
+	req := factory.Call("open", name)
+	upgrade := js.FuncOf(func(this js.Value, args []js.Value) any {
+		db := req.Get("result")
+		if !db.Get("objectStoreNames").Call("contains", store).Bool() {
+			db.Call("createObjectStore", store)
+		}
+		return nil
+	})
+	defer upgrade.Release()
+	req.Set("onupgradeneeded", upgrade)
+	db, err := await(req)
+	if err != nil {
+		return nil, err
+	}
+	if !db.Get("objectStoreNames").Call("contains", store).Bool() {
+		// The database exists without the object store: create it in a
+		// new version.
+		version := db.Get("version").Int()
+		db.Call("close")
+		req = factory.Call("open", name, version+1)
+		req.Set("onupgradeneeded", upgrade)
+		if db, err = await(req); err != nil {
+			return nil, err
+		}
+	}
+	return &indexedDB{db: db, store: store}, nil
+}
+
+func (s *indexedDB) objectStore(mode string) js.Value {
+	return s.db.Call("transaction", s.store, mode).Call("objectStore", s.store)
+}
+
+// Load returns the snapshot of s, or nil if there is none.
+func (s *indexedDB) Load() ([]byte, error) {
+	v, err := await(s.objectStore("readonly").Call("get", snapshotKey))
+	if err != nil || v.IsUndefined() {
+		return nil, err
+	}
+	data := make([]byte, v.Get("length").Int())
+	js.CopyBytesToGo(data, v)
+	return data, nil
+}
+
+// Save replaces the snapshot of s.
+func (s *indexedDB) Save(snapshot []byte) error {
+	v := js.Global().Get("Uint8Array").New(len(snapshot))
+	js.CopyBytesToJS(v, snapshot)
+	_, err := await(s.objectStore("readwrite").Call("put", v, snapshotKey))
+	return err
+}
+
+// await waits for the IDBRequest req to complete and returns its result.
+func await(req js.Value) (js.Value, error) {
+	type result struct {
+		v   js.Value
+		err error
+	}
+	done := make(chan result, 1)
+	onSuccess := js.FuncOf(func(this js.Value, args []js.Value) any {
+		done <- result{v: req.Get("result")}
+		return nil
+	})
+	defer onSuccess.Release()
+	onError := js.FuncOf(func(this js.Value, args []js.Value) any {
+		msg := "request failed"
+		if e := req.Get("error"); !e.IsNull() && !e.IsUndefined() {
+			msg = e.Get("message").String()
+		}
+		done <- result{err: errors.New("memfs: IndexedDB: " + msg)}
+		return nil
+	})
+	defer onError.Release()
+	req.Set("onsuccess", onSuccess)
+	req.Set("onerror", onError)
+	r := <-done
+	return r.v, r.err
+}
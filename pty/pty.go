@@ -0,0 +1,22 @@
+// Package pty allocates pseudo terminals and starts programs on them, for
+// terminal multiplexers and the shells of games:
+//
+//	p, err := pty.Open()
+//	if err != nil {
+//		return err
+//	}
+//	defer p.Close()
+//	p.Resize(24, 80)
+//	cmd := exec.Command("sh")
+//	if err := p.Start(cmd); err != nil {
+//		return err
+//	}
+//	go io.Copy(p, os.Stdin)
+//	io.Copy(os.Stdout, p)
+//	cmd.Process.Wait()
+//
+// On unix systems a Pty is the controller side of a pseudo terminal
+// allocated with posix_openpt, grantpt and unlockpt, and programs run on
+// the terminal side named by ptsname, as their controlling terminal. On
+// Windows it is a pseudo console, made with CreatePseudoConsole.
+package pty
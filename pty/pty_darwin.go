@@ -0,0 +1,31 @@
+package pty
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+func openpt() (int, error) {
+	return unix.Open("/dev/ptmx", unix.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC, 0)
+}
+
+// unlockpt grants and unlocks the terminal side of the controller fd, and
+// returns its name.
+func unlockpt(fd int) (string, error) {
+	if err := unix.IoctlSetInt(fd, unix.TIOCPTYGRANT, 0); err != nil {
+		return "", err
+	}
+	if err := unix.IoctlSetInt(fd, unix.TIOCPTYUNLK, 0); err != nil {
+		return "", err
+	}
+	return ptsname(fd)
+}
+
+func ptsname(fd int) (string, error) {
+	var name [128]byte // the size encoded in TIOCPTYGNAME
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), unix.TIOCPTYGNAME, uintptr(unsafe.Pointer(&name[0]))); errno != 0 {
+		return "", errno
+	}
+	return unix.ByteSliceToString(name[:]), nil
+}
@@ -0,0 +1,30 @@
+package pty
+
+import (
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+func openpt() (int, error) {
+	fd, _, errno := unix.Syscall(unix.SYS_POSIX_OPENPT, unix.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC, 0, 0)
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(fd), nil
+}
+
+// unlockpt returns the name of the terminal side of the controller fd.
+// grantpt and unlockpt have nothing to do on FreeBSD, where posix_openpt
+// returns terminals ready to use.
+func unlockpt(fd int) (string, error) {
+	return ptsname(fd)
+}
+
+func ptsname(fd int) (string, error) {
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		return "", err
+	}
+	return "/dev/pts/" + strconv.Itoa(n), nil
+}
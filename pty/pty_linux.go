@@ -0,0 +1,29 @@
+package pty
+
+import (
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+func openpt() (int, error) {
+	return unix.Open("/dev/ptmx", unix.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC, 0)
+}
+
+// unlockpt unlocks the terminal side of the controller fd, and returns its
+// name. grantpt has nothing to do on Linux, where devpts sets the owner
+// and mode of terminals.
+func unlockpt(fd int) (string, error) {
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		return "", err
+	}
+	return ptsname(fd)
+}
+
+func ptsname(fd int) (string, error) {
+	n, err := unix.IoctlGetUint32(fd, unix.TIOCGPTN)
+	if err != nil {
+		return "", err
+	}
+	return "/dev/pts/" + strconv.Itoa(int(n)), nil
+}
@@ -0,0 +1,24 @@
+package pty
+
+import "golang.org/x/sys/unix"
+
+func openpt() (int, error) {
+	return unix.Open("/dev/ptmx", unix.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC, 0)
+}
+
+// unlockpt grants the terminal side of the controller fd, and returns its
+// name. Terminals are not locked on NetBSD.
+func unlockpt(fd int) (string, error) {
+	if err := unix.IoctlSetInt(fd, unix.TIOCGRANTPT, 0); err != nil {
+		return "", err
+	}
+	return ptsname(fd)
+}
+
+func ptsname(fd int) (string, error) {
+	ptm, err := unix.IoctlGetPtmget(fd, unix.TIOCPTSNAME)
+	if err != nil {
+		return "", err
+	}
+	return unix.ByteSliceToString(ptm.Sn[:]), nil
+}
@@ -0,0 +1,23 @@
+//go:build !darwin && !freebsd && !linux && !netbsd && !windows
+
+package pty
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// A Pty is a pseudo terminal. There are none on this platform.
+type Pty struct{}
+
+// Open fails with errors.ErrUnsupported.
+func Open() (*Pty, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func (p *Pty) Name() string                   { return "" }
+func (p *Pty) Read(b []byte) (int, error)     { return 0, errors.ErrUnsupported }
+func (p *Pty) Write(b []byte) (int, error)    { return 0, errors.ErrUnsupported }
+func (p *Pty) Resize(rows, cols uint16) error { return errors.ErrUnsupported }
+func (p *Pty) Start(cmd *exec.Cmd) error      { return errors.ErrUnsupported }
+func (p *Pty) Close() error                   { return errors.ErrUnsupported }
@@ -0,0 +1,131 @@
+//go:build darwin || freebsd || linux || netbsd
+
+package pty
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// A Pty is a pseudo terminal. Reads return the output of the programs
+// running on it, and writes are their input, as typed on the terminal.
+type Pty struct {
+	controller *os.File
+
+	mu  sync.Mutex
+	tty *os.File // until Start
+}
+
+// Open allocates a pseudo terminal.
+func Open() (*Pty, error) {
+	fd, err := openpt()
+	if err != nil {
+		return nil, err
+	}
+	controller := os.NewFile(uintptr(fd), "/dev/ptmx")
+	name, err := unlockpt(fd)
+	if err != nil {
+		controller.Close()
+		return nil, err
+	}
+	tty, err := os.OpenFile(name, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		controller.Close()
+		return nil, err
+	}
+	return &Pty{controller: controller, tty: tty}, nil
+}
+
+// Name returns the name of the terminal side of p, such as /dev/pts/3.
+func (p *Pty) Name() string {
+	name, _ := ptsname(int(p.controller.Fd()))
+	return name
+}
+
+// Read reads the output of the programs running on p. It returns io.EOF
+// once they all exited, after Start.
+func (p *Pty) Read(b []byte) (int, error) {
+	n, err := p.controller.Read(b)
+	if errors.Is(err, syscall.EIO) {
+		// Linux reports the last close of the terminal side as EIO.
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Write writes the input of the programs running on p.
+func (p *Pty) Write(b []byte) (int, error) {
+	return p.controller.Write(b)
+}
+
+// Resize sets the size of p, in characters, and sends SIGWINCH to its
+// foreground programs.
+func (p *Pty) Resize(rows, cols uint16) error {
+	return unix.IoctlSetWinsize(int(p.controller.Fd()), unix.TIOCSWINSZ, &unix.Winsize{Row: rows, Col: cols})
+}
+
+// Start starts cmd on p, in a new session with p as its controlling
+// terminal (TIOCSCTTY), and with p as the standard input, output and error
+// it does not have yet. Start closes the terminal side of p in the calling
+// process, so that Read returns io.EOF once the programs on p exit: it can
+// only be called once.
+func (p *Pty) Start(cmd *exec.Cmd) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.tty == nil {
+		return errors.New("pty: already started")
+	}
+	if cmd.Stdin == nil {
+		cmd.Stdin = p.tty
+	}
+	if cmd.Stdout == nil {
+		cmd.Stdout = p.tty
+	}
+	if cmd.Stderr == nil {
+		cmd.Stderr = p.tty
+	}
+	// The controlling terminal is given by its descriptor in the child:
+	// that of the first standard file on p, or an extra one otherwise.
+	ctty := -1
+	for fd, f := range []any{cmd.Stdin, cmd.Stdout, cmd.Stderr} {
+		if f == p.tty {
+			ctty = fd
+			break
+		}
+	}
+	extraFiles := cmd.ExtraFiles
+	if ctty < 0 {
+		cmd.ExtraFiles = append(cmd.ExtraFiles, p.tty)
+		ctty = 2 + len(cmd.ExtraFiles)
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = ctty
+	if err := cmd.Start(); err != nil {
+		cmd.ExtraFiles = extraFiles
+		return err
+	}
+	err := p.tty.Close()
+	p.tty = nil
+	return err
+}
+
+// Close closes p, which hangs up the programs running on it.
+func (p *Pty) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.tty != nil {
+		p.tty.Close()
+		p.tty = nil
+	}
+	return p.controller.Close()
+}
@@ -0,0 +1,93 @@
+//go:build darwin || freebsd || linux || netbsd
+
+package pty
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestStart(t *testing.T) {
+	p, err := Open()
+	if err != nil {
+		t.Skipf("Open: %v", err)
+	}
+	defer p.Close()
+	if !strings.HasPrefix(p.Name(), "/dev/") {
+		t.Errorf("Name() = %q, want a device", p.Name())
+	}
+	if err := p.Resize(24, 100); err != nil {
+		t.Fatalf("Resize: %v", err)
+	}
+	// stty reads the size of its standard input, and tty tells it is the
+	// terminal of p.
+	cmd := exec.Command("sh", "-c", "stty size; test -t 0 && tty")
+	if err := p.Start(cmd); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if len(cmd.ExtraFiles) != 0 {
+		t.Errorf("ExtraFiles = %v, want none with p as the standard input", cmd.ExtraFiles)
+	}
+	if err := p.Start(exec.Command("true")); err == nil {
+		t.Errorf("second Start succeeded")
+	}
+	out, err := io.ReadAll(p)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	want := "24 100\r\n" + p.Name() + "\r\n"
+	if !bytes.Equal(out, []byte(want)) {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+}
+
+func TestStartExtraFiles(t *testing.T) {
+	p, err := Open()
+	if err != nil {
+		t.Skipf("Open: %v", err)
+	}
+	defer p.Close()
+	null, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer null.Close()
+
+	// A failed Start leaves the command as it was.
+	cmd := exec.Command("/nonexistent/command")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = null, null, null
+	if err := p.Start(cmd); err == nil {
+		t.Fatalf("Start of a missing command succeeded")
+	}
+	if len(cmd.ExtraFiles) != 0 {
+		t.Errorf("ExtraFiles = %v after a failed Start, want none", cmd.ExtraFiles)
+	}
+
+	// With no standard file on p, p is passed as an extra file, and is
+	// still the controlling terminal.
+	cmd = exec.Command("sh", "-c", "echo ok >/dev/tty")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = null, null, null
+	if err := p.Start(cmd); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if len(cmd.ExtraFiles) != 1 {
+		t.Errorf("ExtraFiles = %v, want the terminal", cmd.ExtraFiles)
+	}
+	out, err := io.ReadAll(p)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if want := "ok\r\n"; string(out) != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+}
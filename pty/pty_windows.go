@@ -0,0 +1,207 @@
+package pty
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"unicode/utf16"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// A Pty is a pseudo console. Reads return the output of the programs
+// running on it, as virtual terminal sequences, and writes are their
+// input, as typed on the console.
+type Pty struct {
+	in  *os.File // input of the console
+	out *os.File // output of the console
+
+	mu      sync.Mutex
+	console windows.Handle // 0 once closed
+	reading int            // reads in progress
+	outDone bool           // whether out is closed, once read to its end after Close
+}
+
+// Open creates a pseudo console of 24 rows and 80 columns, the size of a
+// new terminal. Use Resize to change it.
+func Open() (*Pty, error) {
+	var inR, inW, outR, outW windows.Handle
+	if err := windows.CreatePipe(&inR, &inW, nil, 0); err != nil {
+		return nil, err
+	}
+	if err := windows.CreatePipe(&outR, &outW, nil, 0); err != nil {
+		windows.CloseHandle(inR)
+		windows.CloseHandle(inW)
+		return nil, err
+	}
+	var console windows.Handle
+	err := windows.CreatePseudoConsole(windows.Coord{X: 80, Y: 24}, inR, outW, 0, &console)
+	// The console has its own handles to its ends of the pipes.
+	windows.CloseHandle(inR)
+	windows.CloseHandle(outW)
+	if err != nil {
+		windows.CloseHandle(inW)
+		windows.CloseHandle(outR)
+		return nil, err
+	}
+	return &Pty{in: os.NewFile(uintptr(inW), "conpty-in"), out: os.NewFile(uintptr(outR), "conpty-out"), console: console}, nil
+}
+
+// Name returns the empty string: pseudo consoles have no name.
+func (p *Pty) Name() string {
+	return ""
+}
+
+// Read reads the output of the programs running on p. Once p is closed,
+// it returns the last output of the console, then io.EOF.
+func (p *Pty) Read(b []byte) (int, error) {
+	p.mu.Lock()
+	if p.outDone {
+		p.mu.Unlock()
+		return 0, io.EOF
+	}
+	p.reading++
+	p.mu.Unlock()
+	n, err := p.out.Read(b)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.reading--
+	if err != nil && p.console == 0 && p.reading == 0 && !p.outDone {
+		// The console is gone, and no other read is using out.
+		p.outDone = true
+		p.out.Close()
+	}
+	return n, err
+}
+
+// Write writes the input of the programs running on p.
+func (p *Pty) Write(b []byte) (int, error) {
+	return p.in.Write(b)
+}
+
+// Resize sets the size of p, in characters.
+func (p *Pty) Resize(rows, cols uint16) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.console == 0 {
+		return os.ErrClosed
+	}
+	return windows.ResizePseudoConsole(p.console, windows.Coord{X: int16(cols), Y: int16(rows)})
+}
+
+// Start starts cmd attached to p, with PROC_THREAD_ATTRIBUTE_PSEUDOCONSOLE.
+// The standard files and SysProcAttr of cmd are not used: the program
+// reads and writes the console. os/exec cannot start programs on a pseudo
+// console, so Start creates the process itself from cmd.Path, cmd.Args,
+// cmd.Env and cmd.Dir and only sets cmd.Process: wait for it with
+// cmd.Process.Wait rather than cmd.Wait.
+func (p *Pty) Start(cmd *exec.Cmd) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.console == 0 {
+		return os.ErrClosed
+	}
+	if cmd.Process != nil {
+		return errors.New("pty: already started")
+	}
+	if cmd.Err != nil {
+		return cmd.Err
+	}
+	attrs, err := windows.NewProcThreadAttributeList(1)
+	if err != nil {
+		return err
+	}
+	defer attrs.Delete()
+	if err := attrs.UpdatePseudoConsole(p.console); err != nil {
+		return err
+	}
+	si := &windows.StartupInfoEx{
+		StartupInfo:             windows.StartupInfo{Cb: uint32(unsafe.Sizeof(windows.StartupInfoEx{}))},
+		ProcThreadAttributeList: attrs.List(),
+	}
+
+	path, err := windows.UTF16PtrFromString(cmd.Path)
+	if err != nil {
+		return err
+	}
+	args := cmd.Args
+	if len(args) == 0 {
+		args = []string{cmd.Path}
+	}
+	cmdLine, err := windows.UTF16PtrFromString(windows.ComposeCommandLine(args))
+	if err != nil {
+		return err
+	}
+	var dir *uint16
+	if cmd.Dir != "" {
+		if dir, err = windows.UTF16PtrFromString(cmd.Dir); err != nil {
+			return err
+		}
+	}
+	var env *uint16
+	if cmd.Env != nil {
+		block, err := envBlock(cmd.Env)
+		if err != nil {
+			return err
+		}
+		env = &block[0]
+	}
+
+	var pi windows.ProcessInformation
+	flags := uint32(windows.EXTENDED_STARTUPINFO_PRESENT | windows.CREATE_UNICODE_ENVIRONMENT)
+	if err := windows.CreateProcess(path, cmdLine, nil, nil, false, flags, env, dir, &si.StartupInfo, &pi); err != nil {
+		return err
+	}
+	defer windows.CloseHandle(pi.Thread)
+	defer windows.CloseHandle(pi.Process)
+	proc, err := os.FindProcess(int(pi.ProcessId))
+	if err != nil {
+		return err
+	}
+	cmd.Process = proc
+	return nil
+}
+
+// envBlock returns the environment block of the "key=value" strings of env.
+func envBlock(env []string) ([]uint16, error) {
+	var block []uint16
+	for _, kv := range env {
+		for _, r := range kv {
+			if r == 0 {
+				return nil, windows.ERROR_INVALID_PARAMETER
+			}
+		}
+		block = append(block, utf16.Encode([]rune(kv))...)
+		block = append(block, 0)
+	}
+	if len(block) == 0 {
+		block = append(block, 0)
+	}
+	return append(block, 0), nil
+}
+
+// Close closes p, which ends the programs running on it. Their last output
+// goes to the reads in progress, which then return io.EOF, or is discarded
+// if there are none, so that all of it is read by reading p until io.EOF
+// from before Close, as with io.Copy in the example of the package.
+func (p *Pty) Close() error {
+	p.mu.Lock()
+	if p.console == 0 {
+		p.mu.Unlock()
+		return os.ErrClosed
+	}
+	console := p.console
+	p.console = 0
+	drain := p.reading == 0
+	p.mu.Unlock()
+	if drain {
+		// ClosePseudoConsole waits for the last output of the console to
+		// be read on some versions of Windows. Read closes out at its end.
+		go io.Copy(io.Discard, p)
+	}
+	windows.ClosePseudoConsole(console)
+	return p.in.Close()
+}
@@ -0,0 +1,82 @@
+// Package sysjs dispatches the raw syscalls of packages stubbed for wasm,
+// such as golang.org/x/sys/unix, to handlers registered by the program.
+//
+// The fork workflow stubs golang.org/x/sys/unix with -mode=shim and
+// -shim-pkg=golang.org/x/sys/sysjs, so that every raw syscall first asks
+// this package whether a handler is registered for its trap number:
+//
+//	if sysjs.Available(SYS_IOCTL) {
+//		_, _, err := sysjs.Do(SYS_IOCTL, uintptr(fd), uintptr(req), uintptr(arg))
+//		return err
+//	}
+//	panic("syscall not supported in wasm: Syscall(SYS_IOCTL, ...)")
+//
+// A game compiled to wasm can then provide JS-backed implementations of the
+// syscalls it needs, such as ioctl(TIOCGWINSZ) for the window size or read
+// and write on stdin and stdout, and the others still panic.
+//
+// Trap numbers are those of the platform the stubbed package is built for,
+// such as unix.SYS_IOCTL.
+package sysjs
+
+import (
+	"sync"
+	"syscall"
+)
+
+// A SyscallHandler implements raw syscalls. Syscall performs the syscall
+// trap with args and returns its two results and nil, or an error if it
+// failed, usually a syscall.Errno.
+type SyscallHandler interface {
+	Syscall(trap uintptr, args ...uintptr) (r1, r2 uintptr, err error)
+}
+
+// HandlerFunc adapts a function to a SyscallHandler.
+type HandlerFunc func(trap uintptr, args ...uintptr) (r1, r2 uintptr, err error)
+
+// Syscall calls f(trap, args...).
+func (f HandlerFunc) Syscall(trap uintptr, args ...uintptr) (r1, r2 uintptr, err error) {
+	return f(trap, args...)
+}
+
+var (
+	mu       sync.RWMutex
+	handlers = make(map[uintptr]SyscallHandler)
+)
+
+// Register registers h as the handler of the syscall trap, replacing any
+// previous one. A nil h unregisters it.
+func Register(trap uintptr, h SyscallHandler) {
+	mu.Lock()
+	defer mu.Unlock()
+	if h == nil {
+		delete(handlers, trap)
+		return
+	}
+	handlers[trap] = h
+}
+
+// Lookup returns the handler registered for the syscall trap, or nil if
+// there is none. Handlers covering part of a syscall, such as reads of
+// some file descriptors only, use it to pass the rest on to the handler they
+// replace.
+func Lookup(trap uintptr) SyscallHandler {
+	mu.RLock()
+	defer mu.RUnlock()
+	return handlers[trap]
+}
+
+// Available reports whether a handler is registered for the syscall trap.
+func Available(trap uintptr) bool {
+	return Lookup(trap) != nil
+}
+
+// Do performs the syscall trap with args using its registered handler. It
+// fails with syscall.ENOSYS if there is none.
+func Do(trap uintptr, args ...uintptr) (r1, r2 uintptr, err error) {
+	h := Lookup(trap)
+	if h == nil {
+		return 0, 0, syscall.ENOSYS
+	}
+	return h.Syscall(trap, args...)
+}
@@ -0,0 +1,35 @@
+package sysjs_test
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+
+	"golang.org/x/sys/sysjs"
+)
+
+func TestDispatch(t *testing.T) {
+	const trap = 1 << 20
+	if sysjs.Available(trap) {
+		t.Fatalf("Available(%d) before Register", trap)
+	}
+	if _, _, err := sysjs.Do(trap); !errors.Is(err, syscall.ENOSYS) {
+		t.Errorf("Do without a handler: got %v, want ENOSYS", err)
+	}
+
+	sysjs.Register(trap, sysjs.HandlerFunc(func(trap uintptr, args ...uintptr) (r1, r2 uintptr, err error) {
+		return args[0] + args[1], uintptr(len(args)), nil
+	}))
+	if !sysjs.Available(trap) || sysjs.Lookup(trap) == nil {
+		t.Fatalf("Available(%d) = false after Register", trap)
+	}
+	r1, r2, err := sysjs.Do(trap, 2, 3, 0)
+	if r1 != 5 || r2 != 3 || err != nil {
+		t.Errorf("Do = %d, %d, %v; want 5, 3, nil", r1, r2, err)
+	}
+
+	sysjs.Register(trap, nil)
+	if sysjs.Available(trap) || sysjs.Lookup(trap) != nil {
+		t.Errorf("Available(%d) after unregistering", trap)
+	}
+}
@@ -0,0 +1,108 @@
+//go:build unix
+
+package term
+
+import "sync"
+
+// guardModes are the modes a Guard resets: those of the mouse, and the
+// modes of a ModeStack.
+var guardModes = []Mode{1006, Mode(MouseMotion), Mode(MouseDrags), Mode(MouseClicks), ModeFocus, ModeBracketedPaste}
+
+// A Guard restores a terminal to the state it was in when the Guard was
+// made, however the program ends, so that a game crashing does not leave
+// the terminal of its player in raw mode, on the alternate screen or
+// reporting the mouse:
+//
+//	guard, err := term.NewGuard(fd)
+//	if err != nil {
+//		return err
+//	}
+//	defer guard.Restore()
+//	defer guard.RestoreOnPanic()
+//	if _, err := term.MakeRaw(fd); err != nil {
+//		return err
+//	}
+//
+// The state restored is that of GetState, the screen, cursor visibility
+// and origin mode of EnterAltScreen and its functions, and the modes of
+// EnableMouse and ModeStack, which are reset, as they are assumed to be
+// when the Guard is made.
+//
+// Until Restore, SIGHUP, SIGINT, SIGQUIT, SIGTERM and SIGSEGV sent to the
+// program restore the terminal and then end the program, as described for
+// EnterAltScreen. This overrides the handling of these signals by the
+// program: one handling them itself with signal.Notify, such as a game
+// asking before quitting on SIGINT, would be ended all the same, and must
+// call LeaveSignals. Faults of the program, such as nil pointer
+// dereferences, are panics rather than signals in Go: they restore the
+// terminal in the goroutines deferring RestoreOnPanic. Nothing restores it
+// after os.Exit.
+type Guard struct {
+	fd     int
+	state  *State
+	screen screenState
+	saved  int // screen states saved by EnterAltScreen
+	once   sync.Once
+	err    error // of Restore
+}
+
+// NewGuard records the state of the terminal fd, to be restored by the
+// Guard.
+func NewGuard(fd int) (*Guard, error) {
+	state, err := GetState(fd)
+	if err != nil {
+		return nil, err
+	}
+	g := &Guard{fd: fd, state: state}
+	screens.Lock()
+	if s := screens.m[fd]; s != nil {
+		g.screen, g.saved = s.cur, len(s.saved)
+	}
+	screens.Unlock()
+	restoreOnSignal(g, func() { g.Restore() })
+	return g, nil
+}
+
+// Restore puts the terminal back in the state recorded by NewGuard, and
+// returns the first error doing so. Only the first call restores the
+// terminal; the later ones return the same error.
+func (g *Guard) Restore() error {
+	g.once.Do(func() {
+		restoreOnSignal(g, nil)
+		leaveSignals(g, false)
+		errs := []error{
+			writeAll(g.fd, modeSequence(guardModes, 'l')),
+			changeScreen(g.fd, func(s *screen) error {
+				s.saved = s.saved[:min(g.saved, len(s.saved))]
+				return s.set(g.screen)
+			}),
+			Restore(g.fd, g.state),
+		}
+		for _, err := range errs {
+			if err != nil {
+				g.err = err
+				break
+			}
+		}
+	})
+	return g.err
+}
+
+// LeaveSignals leaves the signals listed for Guard to the program, which
+// handles them itself and must call Restore before it ends. Until Restore,
+// they neither restore terminals nor end the program, including those
+// changed by EnterAltScreen.
+func (g *Guard) LeaveSignals() {
+	leaveSignals(g, true)
+}
+
+// RestoreOnPanic restores the terminal if the goroutine is panicking, and
+// panics again with the same value. It must be deferred, as with recover,
+// at the start of the goroutines of the program, or of those that may
+// panic.
+func (g *Guard) RestoreOnPanic() {
+	if v := recover(); v != nil {
+		g.Restore()
+		panic(v)
+	}
+}
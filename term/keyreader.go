@@ -0,0 +1,56 @@
+package term
+
+import "time"
+
+// A KeyReader reads the keys typed on a terminal without blocking, for
+// game loops polling the input once per frame:
+//
+//	keys, err := term.NewKeyReader(int(os.Stdin.Fd()))
+//	if err != nil {
+//		return err
+//	}
+//	defer keys.Close()
+//	for {
+//		events, err := keys.Poll(time.Second / 60)
+//		...
+//	}
+//
+// On unix systems the terminal is in raw mode with VMIN and VTIME 0, so that
+// reads return at once, and the escape sequences of special keys are
+// decoded as sent by xterm-compatible terminals. On Windows the key events
+// of the console are read with ReadConsoleInput. On js/wasm the keys are
+// those of the terminal set with termjs.SetDefault, which the KeyReader
+// reads from until the program exits.
+//
+// A KeyReader is not safe for concurrent use.
+type KeyReader struct {
+	fd  int
+	old *State
+	in  *keyInput
+}
+
+// NewKeyReader puts the terminal fd in raw mode for non-blocking reads of
+// its keys. Close puts it back in its previous state.
+func NewKeyReader(fd int) (*KeyReader, error) {
+	old, err := makeKeyRaw(fd)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyReader{fd: fd, old: old, in: newKeyInput(fd)}, nil
+}
+
+// Poll returns the keys typed since the last call, waiting up to timeout
+// for one if there are none: a timeout of 0 does not wait, and a negative
+// one waits until a key is typed. It returns no events if none were typed
+// in time.
+//
+// On terminals sending escape sequences, the Escape key is reported by a
+// later Poll, once no sequence followed it within a short delay.
+func (r *KeyReader) Poll(timeout time.Duration) ([]KeyEvent, error) {
+	return r.in.poll(timeout)
+}
+
+// Close puts the terminal back in the state it was in before NewKeyReader.
+func (r *KeyReader) Close() error {
+	return Restore(r.fd, r.old)
+}
@@ -0,0 +1,37 @@
+//go:build unix || (js && wasm)
+
+package term
+
+import "time"
+
+// keyInput decodes the keys of a terminal sending them as bytes.
+type keyInput struct {
+	src     byteSource
+	buf     [256]byte
+	pending []byte // start of a sequence read by the last poll
+}
+
+// A byteSource returns the input of a terminal.
+type byteSource interface {
+	// read reads the input available, waiting up to timeout for some as
+	// described for KeyReader.Poll. It returns 0 if there is none.
+	read(p []byte, timeout time.Duration) (int, error)
+}
+
+func (in *keyInput) poll(timeout time.Duration) ([]KeyEvent, error) {
+	if len(in.pending) > 0 && (timeout < 0 || timeout > escapeDelay) {
+		// Wait for the rest of the sequence only.
+		timeout = escapeDelay
+	}
+	n, err := in.src.read(in.buf[:], timeout)
+	if err != nil {
+		return nil, err
+	}
+	in.pending = append(in.pending, in.buf[:n]...)
+	events, rest := decodeKeys(in.pending, n == 0)
+	in.pending = append(in.pending[:0], rest...)
+	return events, nil
+}
+
+// escapeDelay is how long poll waits for the rest of an escape sequence.
+const escapeDelay = 50 * time.Millisecond
@@ -0,0 +1,92 @@
+//go:build js && wasm
+
+package term
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/sys/termjs"
+)
+
+// makeKeyRaw puts the terminal set with termjs.SetDefault in raw mode.
+func makeKeyRaw(fd int) (*State, error) {
+	return MakeRaw(fd)
+}
+
+func newKeyInput(fd int) *keyInput {
+	return &keyInput{src: &termSource{}}
+}
+
+var (
+	inputOnce sync.Once
+	input     = make(chan []byte) // closed once the terminal is
+)
+
+// readInput sends the input of t on input until t is closed.
+func readInput(t *termjs.Terminal) {
+	for {
+		buf := make([]byte, 256)
+		n, err := t.Read(buf)
+		if err == termjs.ErrClosed {
+			close(input)
+			return
+		}
+		if n > 0 {
+			input <- buf[:n]
+		}
+	}
+}
+
+// termSource reads the input of the terminal set with termjs.SetDefault.
+// Reads of termjs.Terminal block, so the input is read by a goroutine,
+// started by the first read and shared by every KeyReader, until the
+// terminal is closed.
+type termSource struct {
+	rest []byte // received but not returned yet
+}
+
+func (s *termSource) read(p []byte, timeout time.Duration) (int, error) {
+	if len(s.rest) == 0 {
+		t, err := terminal()
+		if err != nil {
+			return 0, err
+		}
+		inputOnce.Do(func() { go readInput(t) })
+		data, ok, err := receive(timeout)
+		if !ok || err != nil {
+			return 0, err
+		}
+		s.rest = data
+	}
+	n := copy(p, s.rest)
+	s.rest = s.rest[n:]
+	return n, nil
+}
+
+// receive receives from input, waiting up to timeout. It returns ok ==
+// false if nothing was received in time.
+func receive(timeout time.Duration) (data []byte, ok bool, err error) {
+	select {
+	case data, ok = <-input:
+	default:
+		if timeout == 0 {
+			return nil, false, nil
+		}
+		var expired <-chan time.Time
+		if timeout > 0 {
+			timer := time.NewTimer(timeout)
+			defer timer.Stop()
+			expired = timer.C
+		}
+		select {
+		case data, ok = <-input:
+		case <-expired:
+			return nil, false, nil
+		}
+	}
+	if !ok {
+		return nil, false, termjs.ErrClosed
+	}
+	return data, true, nil
+}
@@ -0,0 +1,22 @@
+//go:build !unix && !windows && !(js && wasm)
+
+package term
+
+import (
+	"errors"
+	"time"
+)
+
+func makeKeyRaw(fd int) (*State, error) {
+	return nil, errors.ErrUnsupported
+}
+
+type keyInput struct{}
+
+func newKeyInput(fd int) *keyInput {
+	return &keyInput{}
+}
+
+func (in *keyInput) poll(timeout time.Duration) ([]KeyEvent, error) {
+	return nil, errors.ErrUnsupported
+}
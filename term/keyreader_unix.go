@@ -0,0 +1,82 @@
+//go:build unix
+
+package term
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// makeKeyRaw puts the terminal fd in raw mode with VMIN and VTIME 0, so
+// that reads return what is available without waiting.
+func makeKeyRaw(fd int) (*State, error) {
+	termios, err := unix.IoctlGetTermios(fd, ioctlReadTermios)
+	if err != nil {
+		return nil, err
+	}
+	old := State{state{*termios}}
+	makeRaw(termios)
+	termios.Cc[unix.VMIN] = 0
+	termios.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, ioctlWriteTermios, termios); err != nil {
+		return nil, err
+	}
+	return &old, nil
+}
+
+func newKeyInput(fd int) *keyInput {
+	return &keyInput{src: fdSource(fd)}
+}
+
+// fdSource reads the input of a terminal file descriptor, waiting for it
+// with poll.
+type fdSource int
+
+func (fd fdSource) read(p []byte, timeout time.Duration) (int, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		ready, err := fd.wait(timeout, deadline)
+		if err == unix.EINTR {
+			// Such as SIGWINCH, which games get on every resize.
+			continue
+		}
+		if err != nil || !ready {
+			return 0, err
+		}
+		n, err := unix.Read(int(fd), p)
+		if err == unix.EINTR || err == unix.EAGAIN {
+			continue
+		}
+		return max(n, 0), err
+	}
+}
+
+// wait waits until deadline, or without limit if timeout is negative, for
+// input on fd, and reports whether there is some.
+func (fd fdSource) wait(timeout time.Duration, deadline time.Time) (ready bool, err error) {
+	msec := -1
+	if timeout >= 0 {
+		// Rounded up, so as not to spin before the deadline.
+		msec = int((max(time.Until(deadline), 0) + time.Millisecond - 1) / time.Millisecond)
+	}
+	fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+	n, err := unix.Poll(fds, msec)
+	if err != nil || n == 0 {
+		return false, err
+	}
+	if fds[0].Revents&unix.POLLNVAL == 0 || int(fd) >= unix.FD_SETSIZE {
+		return true, nil
+	}
+	// macOS does not poll terminal devices: use select, which only takes
+	// file descriptors below FD_SETSIZE.
+	var tv *unix.Timeval
+	if timeout >= 0 {
+		t := unix.NsecToTimeval(max(time.Until(deadline), 0).Nanoseconds())
+		tv = &t
+	}
+	var r unix.FdSet
+	r.Set(int(fd))
+	n, err = unix.Select(int(fd)+1, &r, nil, nil, tv)
+	return n > 0, err
+}
@@ -0,0 +1,153 @@
+package term
+
+import (
+	"time"
+	"unicode/utf16"
+
+	"golang.org/x/sys/windows"
+)
+
+// makeKeyRaw turns off the line input, echo and processing of ^C of the
+// console fd, and its virtual terminal input, as keyInput reads key events.
+func makeKeyRaw(fd int) (*State, error) {
+	h := windows.Handle(fd)
+	var mode uint32
+	if err := windows.GetConsoleMode(h, &mode); err != nil {
+		return nil, err
+	}
+	raw := mode &^ (windows.ENABLE_ECHO_INPUT | windows.ENABLE_PROCESSED_INPUT | windows.ENABLE_LINE_INPUT | windows.ENABLE_VIRTUAL_TERMINAL_INPUT)
+	if err := windows.SetConsoleMode(h, raw); err != nil {
+		return nil, err
+	}
+	return &State{state{mode: mode}}, nil
+}
+
+// keyInput reads the key events of a console.
+type keyInput struct {
+	console   windows.Handle
+	records   [64]windows.InputRecord
+	surrogate rune // first half of a surrogate pair, or 0
+}
+
+func newKeyInput(fd int) *keyInput {
+	return &keyInput{console: windows.Handle(fd)}
+}
+
+func (in *keyInput) poll(timeout time.Duration) ([]KeyEvent, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		wait := uint32(windows.INFINITE)
+		if timeout >= 0 {
+			wait = uint32(max(time.Until(deadline), 0).Milliseconds())
+		}
+		event, err := windows.WaitForSingleObject(in.console, wait)
+		if err != nil {
+			return nil, err
+		}
+		if event == uint32(windows.WAIT_TIMEOUT) {
+			return nil, nil
+		}
+		var pending uint32
+		if err := windows.GetNumberOfConsoleInputEvents(in.console, &pending); err != nil {
+			return nil, err
+		}
+		if pending == 0 {
+			// Read by another reader meanwhile: ReadConsoleInput would
+			// block without timeout.
+			continue
+		}
+		var n uint32
+		if err := windows.ReadConsoleInput(in.console, in.records[:min(int(pending), len(in.records))], &n); err != nil {
+			return nil, err
+		}
+		var events []KeyEvent
+		for _, rec := range in.records[:n] {
+			k, ok := rec.KeyEvent()
+			if !ok {
+				continue
+			}
+			e, ok := in.keyEvent(&k)
+			if !ok || k.KeyDown == 0 {
+				continue
+			}
+			for range max(k.RepeatCount, 1) {
+				events = append(events, e)
+			}
+		}
+		// Only key releases, focus or mouse events: wait for more.
+		if len(events) > 0 || timeout >= 0 && time.Now().After(deadline) {
+			return events, nil
+		}
+	}
+}
+
+// virtualKeys maps virtual key codes to the keys they are reported as.
+var virtualKeys = map[uint16]Key{
+	windows.VK_RETURN: KeyEnter,
+	windows.VK_TAB:    KeyTab,
+	windows.VK_BACK:   KeyBackspace,
+	windows.VK_ESCAPE: KeyEscape,
+	windows.VK_UP:     KeyUp,
+	windows.VK_DOWN:   KeyDown,
+	windows.VK_RIGHT:  KeyRight,
+	windows.VK_LEFT:   KeyLeft,
+	windows.VK_HOME:   KeyHome,
+	windows.VK_END:    KeyEnd,
+	windows.VK_PRIOR:  KeyPageUp,
+	windows.VK_NEXT:   KeyPageDown,
+	windows.VK_INSERT: KeyInsert,
+	windows.VK_DELETE: KeyDelete,
+}
+
+// keyEvent returns the key event of k, or ok == false for keys that type
+// nothing, such as Shift alone, and for the first half of surrogate pairs.
+func (in *keyInput) keyEvent(k *windows.KeyEventRecord) (e KeyEvent, ok bool) {
+	state := k.ControlKeyState
+	if state&(windows.LEFT_CTRL_PRESSED|windows.RIGHT_CTRL_PRESSED) != 0 {
+		e.Mod |= ModCtrl
+	}
+	if state&(windows.LEFT_ALT_PRESSED|windows.RIGHT_ALT_PRESSED) != 0 {
+		e.Mod |= ModAlt
+	}
+	if key, ok := virtualKeys[k.VirtualKeyCode]; ok {
+		if state&windows.SHIFT_PRESSED != 0 {
+			e.Mod |= ModShift
+		}
+		e.Key = key
+		return e, true
+	}
+	if k.VirtualKeyCode >= windows.VK_F1 && k.VirtualKeyCode <= windows.VK_F12 {
+		if state&windows.SHIFT_PRESSED != 0 {
+			e.Mod |= ModShift
+		}
+		e.Key = KeyF1 + Key(k.VirtualKeyCode-windows.VK_F1)
+		return e, true
+	}
+	r := rune(k.UnicodeChar)
+	switch {
+	case r == 0:
+		return e, false
+	case utf16.IsSurrogate(r) && in.surrogate == 0:
+		in.surrogate = r
+		return e, false
+	case in.surrogate != 0:
+		r = utf16.DecodeRune(in.surrogate, r)
+		in.surrogate = 0
+	}
+	// Control characters come as their code, as on unix terminals.
+	if r == 0x1b {
+		e.Key = KeyEscape
+		return e, true
+	}
+	if r < 0x20 {
+		ctrl, _, _ := decodeKey([]byte{byte(r)})
+		ctrl.Mod |= e.Mod
+		return ctrl, true
+	}
+	// AltGr is reported as Ctrl+Alt, but types the character.
+	if e.Mod == ModCtrl|ModAlt {
+		e.Mod = 0
+	}
+	e.Rune = r
+	return e, true
+}
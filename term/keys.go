@@ -0,0 +1,280 @@
+package term
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A Key is a key of the keyboard.
+type Key uint8
+
+const (
+	KeyRune Key = iota // a key typing the character in KeyEvent.Rune
+	KeyEnter
+	KeyTab
+	KeyBackspace
+	KeyEscape
+	KeyUp
+	KeyDown
+	KeyRight
+	KeyLeft
+	KeyHome
+	KeyEnd
+	KeyPageUp
+	KeyPageDown
+	KeyInsert
+	KeyDelete
+	KeyF1
+	KeyF2
+	KeyF3
+	KeyF4
+	KeyF5
+	KeyF6
+	KeyF7
+	KeyF8
+	KeyF9
+	KeyF10
+	KeyF11
+	KeyF12
+	KeyPasteBegin // start of pasted text, with ModeBracketedPaste
+	KeyPasteEnd   // end of pasted text
+	KeyFocusIn    // terminal focused, with ModeFocus
+	KeyFocusOut   // terminal unfocused
+)
+
+var keyNames = [...]string{
+	KeyRune:       "Rune",
+	KeyEnter:      "Enter",
+	KeyTab:        "Tab",
+	KeyBackspace:  "Backspace",
+	KeyEscape:     "Escape",
+	KeyUp:         "Up",
+	KeyDown:       "Down",
+	KeyRight:      "Right",
+	KeyLeft:       "Left",
+	KeyHome:       "Home",
+	KeyEnd:        "End",
+	KeyPageUp:     "PageUp",
+	KeyPageDown:   "PageDown",
+	KeyInsert:     "Insert",
+	KeyDelete:     "Delete",
+	KeyPasteBegin: "PasteBegin",
+	KeyPasteEnd:   "PasteEnd",
+	KeyFocusIn:    "FocusIn",
+	KeyFocusOut:   "FocusOut",
+}
+
+func (k Key) String() string {
+	switch {
+	case k >= KeyF1 && k <= KeyF12:
+		return "F" + strconv.Itoa(int(k-KeyF1+1))
+	case int(k) < len(keyNames):
+		return keyNames[k]
+	}
+	return "Key(" + strconv.Itoa(int(k)) + ")"
+}
+
+// A Mod is a set of modifier keys held down with a key.
+type Mod uint8
+
+// The bits of Mod have the values of the modifier parameter of xterm key
+// sequences minus one.
+const (
+	ModShift Mod = 1 << iota
+	ModAlt
+	ModCtrl
+)
+
+// A KeyEvent is a key typed on a terminal.
+//
+// Control characters are reported as their letter or symbol with ModCtrl,
+// such as 'c' for ^C, apart from those with keys of their own: Tab (^I),
+// Enter (^M) and Backspace (^H or DEL). Terminals do not tell letters typed
+// with Shift from capitals, so ModShift is only reported for the keys other
+// than KeyRune.
+type KeyEvent struct {
+	Key  Key
+	Rune rune // for KeyRune
+	Mod  Mod
+}
+
+func (e KeyEvent) String() string {
+	var b strings.Builder
+	for _, m := range []struct {
+		mod  Mod
+		name string
+	}{{ModCtrl, "Ctrl+"}, {ModAlt, "Alt+"}, {ModShift, "Shift+"}} {
+		if e.Mod&m.mod != 0 {
+			b.WriteString(m.name)
+		}
+	}
+	if e.Key == KeyRune {
+		b.WriteString(strconv.QuoteRune(e.Rune))
+	} else {
+		b.WriteString(e.Key.String())
+	}
+	return b.String()
+}
+
+// maxSequence is the length beyond which an unterminated escape sequence
+// is taken as garbage rather than waited for.
+const maxSequence = 32
+
+// decodeKeys returns the key events of the terminal input b, as sent by
+// xterm-compatible terminals, and the trailing bytes of a sequence not
+// complete yet. With flush, there are no more bytes to wait for: a lone
+// ESC is the Escape key, ESC [ and ESC O are [ and O typed with Alt, and
+// other incomplete sequences are dropped.
+func decodeKeys(b []byte, flush bool) (events []KeyEvent, rest []byte) {
+	for len(b) > 0 {
+		e, n, ok := decodeKey(b)
+		if n == 0 {
+			if !flush {
+				return events, b
+			}
+			switch {
+			case len(b) == 1 && b[0] == 0x1b:
+				events = append(events, KeyEvent{Key: KeyEscape})
+			case len(b) == 2 && b[0] == 0x1b && b[1] < utf8.RuneSelf:
+				// The start of a sequence with nothing after it.
+				events = append(events, KeyEvent{Rune: rune(b[1]), Mod: ModAlt})
+			}
+			return events, nil
+		}
+		if ok {
+			events = append(events, e)
+		}
+		b = b[n:]
+	}
+	return events, nil
+}
+
+// decodeKey decodes the first key event of b, of n bytes. It returns
+// n == 0 if b holds the start of a sequence only, and ok == false for
+// sequences that are not key events.
+func decodeKey(b []byte) (e KeyEvent, n int, ok bool) {
+	c := b[0]
+	switch {
+	case c == 0x1b:
+		if len(b) == 1 {
+			return e, 0, false
+		}
+		switch b[1] {
+		case '[':
+			if _, n := DecodeMouse(b); n >= 0 {
+				// Not a key, and X10 mouse sequences are not CSI ones.
+				return e, n, false
+			}
+			return decodeCSI(b)
+		case 'O':
+			if len(b) == 2 {
+				return e, 0, false
+			}
+			e, ok = finalKey(b[2])
+			return e, 3, ok
+		case 0x1b:
+			// Some terminals send Alt with special keys as ESC and their
+			// sequence.
+			if len(b) < 3 || b[2] != '[' && b[2] != 'O' {
+				return KeyEvent{Key: KeyEscape}, 1, true
+			}
+		}
+		e, n, ok = decodeKey(b[1:])
+		if n == 0 {
+			return e, 0, false
+		}
+		e.Mod |= ModAlt
+		return e, n + 1, ok
+	case c == '\r' || c == '\n':
+		return KeyEvent{Key: KeyEnter}, 1, true
+	case c == '\t':
+		return KeyEvent{Key: KeyTab}, 1, true
+	case c == 0x08 || c == 0x7f:
+		return KeyEvent{Key: KeyBackspace}, 1, true
+	case c == 0:
+		return KeyEvent{Rune: ' ', Mod: ModCtrl}, 1, true
+	case c < 0x1b:
+		return KeyEvent{Rune: rune('a' + c - 1), Mod: ModCtrl}, 1, true
+	case c < 0x20:
+		return KeyEvent{Rune: rune(c + 0x40), Mod: ModCtrl}, 1, true
+	case c < utf8.RuneSelf:
+		return KeyEvent{Rune: rune(c)}, 1, true
+	case !utf8.FullRune(b):
+		return e, 0, false
+	}
+	r, n := utf8.DecodeRune(b)
+	return KeyEvent{Rune: r}, n, true
+}
+
+// decodeCSI decodes the control sequence ESC [ params final at the start
+// of b.
+func decodeCSI(b []byte) (e KeyEvent, n int, ok bool) {
+	end := 2
+	for end < len(b) && (b[end] < 0x40 || b[end] > 0x7e) {
+		end++
+	}
+	if end == len(b) {
+		if len(b) < maxSequence {
+			return e, 0, false
+		}
+		return e, 1, false // not a sequence: drop the ESC
+	}
+	var params []int
+	for p := range strings.SplitSeq(string(b[2:end]), ";") {
+		v, _ := strconv.Atoi(p)
+		params = append(params, v)
+	}
+	if final := b[end]; final == '~' {
+		e, ok = tildeKey(params[0])
+	} else if final == 'Z' {
+		e, ok = KeyEvent{Key: KeyTab, Mod: ModShift}, true
+	} else if final == 'I' {
+		e, ok = KeyEvent{Key: KeyFocusIn}, true
+	} else if final == 'O' {
+		e, ok = KeyEvent{Key: KeyFocusOut}, true
+	} else {
+		e, ok = finalKey(final)
+	}
+	if len(params) > 1 && params[1] > 1 {
+		e.Mod |= Mod(params[1]-1) & (ModShift | ModAlt | ModCtrl)
+	}
+	return e, end + 1, ok
+}
+
+// finalKey returns the key of the final byte of ESC [ and ESC O sequences.
+func finalKey(final byte) (KeyEvent, bool) {
+	var k Key
+	switch final {
+	case 'A':
+		k = KeyUp
+	case 'B':
+		k = KeyDown
+	case 'C':
+		k = KeyRight
+	case 'D':
+		k = KeyLeft
+	case 'H':
+		k = KeyHome
+	case 'F':
+		k = KeyEnd
+	case 'P', 'Q', 'R', 'S':
+		k = KeyF1 + Key(final-'P')
+	default:
+		return KeyEvent{}, false
+	}
+	return KeyEvent{Key: k}, true
+}
+
+// tildeKeys maps the parameters of ESC [ n ~ sequences to their keys.
+var tildeKeys = map[int]Key{
+	1: KeyHome, 2: KeyInsert, 3: KeyDelete, 4: KeyEnd, 5: KeyPageUp, 6: KeyPageDown, 7: KeyHome, 8: KeyEnd,
+	11: KeyF1, 12: KeyF2, 13: KeyF3, 14: KeyF4, 15: KeyF5,
+	17: KeyF6, 18: KeyF7, 19: KeyF8, 20: KeyF9, 21: KeyF10, 23: KeyF11, 24: KeyF12,
+	200: KeyPasteBegin, 201: KeyPasteEnd,
+}
+
+func tildeKey(param int) (KeyEvent, bool) {
+	k, ok := tildeKeys[param]
+	return KeyEvent{Key: k}, ok
+}
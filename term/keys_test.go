@@ -0,0 +1,67 @@
+package term
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDecodeKeys(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []KeyEvent
+	}{
+		{"aZ", []KeyEvent{{Rune: 'a'}, {Rune: 'Z'}}},
+		{"é世", []KeyEvent{{Rune: 'é'}, {Rune: '世'}}},
+		{"\r\t\x7f\x08", []KeyEvent{{Key: KeyEnter}, {Key: KeyTab}, {Key: KeyBackspace}, {Key: KeyBackspace}}},
+		{"\x03\x00\x1c", []KeyEvent{{Rune: 'c', Mod: ModCtrl}, {Rune: ' ', Mod: ModCtrl}, {Rune: '\\', Mod: ModCtrl}}},
+		{"\x1b[A\x1b[B\x1b[C\x1b[D", []KeyEvent{{Key: KeyUp}, {Key: KeyDown}, {Key: KeyRight}, {Key: KeyLeft}}},
+		{"\x1bOA\x1bOH\x1bOP", []KeyEvent{{Key: KeyUp}, {Key: KeyHome}, {Key: KeyF1}}},
+		{"\x1b[1;5A\x1b[1;2D\x1b[1;3P", []KeyEvent{{Key: KeyUp, Mod: ModCtrl}, {Key: KeyLeft, Mod: ModShift}, {Key: KeyF1, Mod: ModAlt}}},
+		{"\x1b[3~\x1b[5;5~\x1b[15~\x1b[24~", []KeyEvent{{Key: KeyDelete}, {Key: KeyPageUp, Mod: ModCtrl}, {Key: KeyF5}, {Key: KeyF12}}},
+		{"\x1b[Z", []KeyEvent{{Key: KeyTab, Mod: ModShift}}},
+		{"\x1bx\x1b\x1b[A", []KeyEvent{{Rune: 'x', Mod: ModAlt}, {Key: KeyUp, Mod: ModAlt}}},
+		{"\x1b[200~ab\x1b[201~", []KeyEvent{{Key: KeyPasteBegin}, {Rune: 'a'}, {Rune: 'b'}, {Key: KeyPasteEnd}}},
+		{"\x1b[I\x1b[O\x1bOI", []KeyEvent{{Key: KeyFocusIn}, {Key: KeyFocusOut}}},
+		{"\x1b[99~\x1b[300~q", []KeyEvent{{Rune: 'q'}}}, // unknown sequences
+		{"\x1b\x1b", []KeyEvent{{Key: KeyEscape}, {Key: KeyEscape}}},
+	}
+	for _, tt := range tests {
+		got, rest := decodeKeys([]byte(tt.in), true)
+		if !slices.Equal(got, tt.want) || rest != nil {
+			t.Errorf("decodeKeys(%q) = %v, %q; want %v", tt.in, got, rest, tt.want)
+		}
+	}
+}
+
+func TestDecodeKeysIncomplete(t *testing.T) {
+	for _, in := range []string{"\x1b", "\x1b[", "\x1b[1;5", "\x1bO", "\xe4\xb8"} {
+		got, rest := decodeKeys([]byte("a"+in), false)
+		if !slices.Equal(got, []KeyEvent{{Rune: 'a'}}) || string(rest) != in {
+			t.Errorf("decodeKeys(%q) = %v, %q; want [a] and the incomplete sequence", "a"+in, got, rest)
+		}
+	}
+	if got, _ := decodeKeys([]byte("\x1b"), true); !slices.Equal(got, []KeyEvent{{Key: KeyEscape}}) {
+		t.Errorf("flushed ESC: got %v, want Escape", got)
+	}
+	for in, want := range map[string]KeyEvent{"\x1b[": {Rune: '[', Mod: ModAlt}, "\x1bO": {Rune: 'O', Mod: ModAlt}} {
+		if got, rest := decodeKeys([]byte("a"+in), true); !slices.Equal(got, []KeyEvent{{Rune: 'a'}, want}) || rest != nil {
+			t.Errorf("flushed %q: got %v, %q; want [a %v]", "a"+in, got, rest, want)
+		}
+	}
+	if got, _ := decodeKeys([]byte("\x1b[1;"), true); len(got) != 0 {
+		t.Errorf("flushed incomplete sequence: got %v, want none", got)
+	}
+}
+
+func TestKeyEventString(t *testing.T) {
+	for e, want := range map[KeyEvent]string{
+		{Rune: 'c', Mod: ModCtrl}:            "Ctrl+'c'",
+		{Key: KeyUp, Mod: ModShift | ModAlt}: "Alt+Shift+Up",
+		{Key: KeyF11}:                        "F11",
+		{Key: KeyPasteBegin}:                 "PasteBegin",
+	} {
+		if got := e.String(); got != want {
+			t.Errorf("%#v.String() = %q, want %q", e, got, want)
+		}
+	}
+}
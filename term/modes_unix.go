@@ -0,0 +1,100 @@
+//go:build unix
+
+package term
+
+import (
+	"slices"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// A Mode is an xterm private mode of a terminal, set with the sequence
+// ESC [ ? mode h and reset with ESC [ ? mode l. Other modes than those
+// below, such as a MouseMode, may be used too.
+type Mode int
+
+const (
+	ModeFocus          Mode = 1004 // report focus changes as KeyFocusIn and KeyFocusOut
+	ModeBracketedPaste Mode = 2004 // bracket pasted text with KeyPasteBegin and KeyPasteEnd
+)
+
+// A ModeStack sets modes of a terminal, such as ModeBracketedPaste, and
+// resets them in the reverse order, so that the parts of a program setting
+// the modes they need do not reset those of each other:
+//
+//	modes := term.NewModeStack(int(os.Stdout.Fd()))
+//	defer modes.Close()
+//	if err := modes.Push(term.ModeBracketedPaste, term.ModeFocus); err != nil {
+//		return err
+//	}
+//
+// Modes are assumed to be reset before the first Push, as they are in new
+// terminals.
+//
+// A ModeStack is not safe for concurrent use.
+type ModeStack struct {
+	write  func(s string) error
+	pushed [][]Mode
+}
+
+// NewModeStack returns a ModeStack for the terminal fd, with no modes set.
+func NewModeStack(fd int) *ModeStack {
+	return &ModeStack{write: func(s string) error { return writeAll(fd, s) }}
+}
+
+// Push sets modes, until the matching call of Pop.
+func (s *ModeStack) Push(modes ...Mode) error {
+	for _, m := range modes {
+		if m <= 0 {
+			return unix.EINVAL
+		}
+	}
+	if err := s.write(modeSequence(modes, 'h')); err != nil {
+		return err
+	}
+	s.pushed = append(s.pushed, slices.Clone(modes))
+	return nil
+}
+
+// Pop resets the modes set by the last call of Push, apart from those set
+// by an earlier one as well. It does nothing if there are no modes to pop.
+func (s *ModeStack) Pop() error {
+	if len(s.pushed) == 0 {
+		return nil
+	}
+	last := len(s.pushed) - 1
+	var reset []Mode
+	for _, m := range s.pushed[last] {
+		if !slices.ContainsFunc(s.pushed[:last], func(modes []Mode) bool { return slices.Contains(modes, m) }) {
+			reset = append(reset, m)
+		}
+	}
+	s.pushed = s.pushed[:last]
+	return s.write(modeSequence(reset, 'l'))
+}
+
+// Close pops all the modes of s, as before the program exits: a terminal
+// left in ModeBracketedPaste or ModeFocus sends their sequences to the
+// shell.
+func (s *ModeStack) Close() error {
+	var err error
+	for len(s.pushed) > 0 {
+		if e := s.Pop(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// modeSequence returns the sequence setting (with final h) or resetting
+// (with final l) modes.
+func modeSequence(modes []Mode, final byte) string {
+	var b []byte
+	for _, m := range modes {
+		b = append(b, "\x1b[?"...)
+		b = strconv.AppendInt(b, int64(m), 10)
+		b = append(b, final)
+	}
+	return string(b)
+}
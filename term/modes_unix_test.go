@@ -0,0 +1,33 @@
+//go:build unix
+
+package term
+
+import "testing"
+
+func TestModeStack(t *testing.T) {
+	var out string
+	s := &ModeStack{write: func(seq string) error {
+		out += seq
+		return nil
+	}}
+	steps := []struct {
+		op   func() error
+		want string
+	}{
+		{func() error { return s.Push(ModeBracketedPaste) }, "\x1b[?2004h"},
+		{func() error { return s.Push(ModeFocus, ModeBracketedPaste) }, "\x1b[?1004h\x1b[?2004h"},
+		{s.Pop, "\x1b[?1004l"}, // ModeBracketedPaste still pushed
+		{func() error { return s.Push(Mode(MouseClicks)) }, "\x1b[?1000h"},
+		{s.Close, "\x1b[?1000l\x1b[?2004l"},
+		{s.Pop, ""},
+	}
+	for i, step := range steps {
+		out = ""
+		if err := step.op(); err != nil || out != step.want {
+			t.Errorf("step %d: wrote %q, %v; want %q", i, out, err, step.want)
+		}
+	}
+	if err := s.Push(0); err == nil {
+		t.Error("Push(0) succeeded")
+	}
+}
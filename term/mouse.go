@@ -0,0 +1,118 @@
+package term
+
+import "strconv"
+
+// A MouseButton is a button of the mouse, or a direction of its wheel.
+type MouseButton uint8
+
+const (
+	MouseLeft MouseButton = iota
+	MouseMiddle
+	MouseRight
+	MouseNone // no button, as in motion without a button held
+	MouseWheelUp
+	MouseWheelDown
+	MouseWheelLeft
+	MouseWheelRight
+)
+
+var mouseButtonNames = [...]string{
+	MouseLeft:       "Left",
+	MouseMiddle:     "Middle",
+	MouseRight:      "Right",
+	MouseNone:       "None",
+	MouseWheelUp:    "WheelUp",
+	MouseWheelDown:  "WheelDown",
+	MouseWheelLeft:  "WheelLeft",
+	MouseWheelRight: "WheelRight",
+}
+
+func (b MouseButton) String() string {
+	if int(b) < len(mouseButtonNames) {
+		return mouseButtonNames[b]
+	}
+	return "MouseButton(" + strconv.Itoa(int(b)) + ")"
+}
+
+// A MouseAction is what happened to the mouse.
+type MouseAction uint8
+
+const (
+	MousePress   MouseAction = iota // button pressed, or wheel turned
+	MouseRelease                    // button released
+	MouseMove                       // mouse moved
+)
+
+// A MouseEvent is a mouse event reported by a terminal.
+//
+// X and Y are the column and row of the cell under the mouse, from 0 at the
+// top left. Terminals using the X10 encoding do not tell which button is
+// released, and report MouseNone, and cannot report positions beyond column
+// or row 222.
+type MouseEvent struct {
+	X, Y   int
+	Button MouseButton
+	Action MouseAction
+	Mod    Mod
+}
+
+// DecodeMouse decodes the mouse event at the start of b, the input of a
+// terminal reporting the mouse, as enabled by EnableMouse, and returns it
+// and its length n. It returns n == 0 if b holds the start of a mouse
+// sequence only, to decode again with the input read next, and n < 0 if b
+// does not start with a mouse sequence.
+//
+// Both the SGR encoding, ESC [ < b ; x ; y M or m, and the X10 one, ESC [ M
+// and three bytes, are decoded.
+func DecodeMouse(b []byte) (e MouseEvent, n int) {
+	const sgr = "\x1b[<"
+	for i := 0; i < len(sgr); i++ {
+		if i == len(b) {
+			return e, 0
+		}
+		if b[i] != sgr[i] && (i < 2 || b[i] != 'M') {
+			return e, -1
+		}
+	}
+	if b[2] == 'M' {
+		if len(b) < 6 {
+			return e, 0
+		}
+		cb := int(b[3]) - 32
+		return mouseEvent(cb, int(b[4])-33, int(b[5])-33, false), 6
+	}
+	var params [3]int
+	i := 0
+	for n = 3; n < len(b) && n < maxSequence; n++ {
+		switch c := b[n]; {
+		case c >= '0' && c <= '9':
+			params[i] = params[i]*10 + int(c-'0')
+		case c == ';' && i < len(params)-1:
+			i++
+		case (c == 'M' || c == 'm') && i == len(params)-1:
+			return mouseEvent(params[0], params[1]-1, params[2]-1, c == 'm'), n + 1
+		default:
+			return e, -1
+		}
+	}
+	if n == maxSequence {
+		return e, -1
+	}
+	return e, 0
+}
+
+// mouseEvent returns the event of the button code cb of xterm mouse
+// sequences, at x and y from 0. release is the final m of SGR sequences.
+func mouseEvent(cb, x, y int, release bool) MouseEvent {
+	e := MouseEvent{X: x, Y: y, Button: MouseButton(cb & 3), Mod: Mod(cb>>2) & (ModShift | ModAlt | ModCtrl)}
+	switch {
+	case cb&64 != 0:
+		e.Button += MouseWheelUp
+	case cb&32 != 0:
+		e.Action = MouseMove
+	case release || e.Button == MouseNone:
+		// X10 sequences report releases as button 3.
+		e.Action = MouseRelease
+	}
+	return e
+}
@@ -0,0 +1,47 @@
+package term
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDecodeMouse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want MouseEvent
+	}{
+		{"\x1b[<0;10;5M", MouseEvent{X: 9, Y: 4, Button: MouseLeft}},
+		{"\x1b[<2;1;1m", MouseEvent{Button: MouseRight, Action: MouseRelease}},
+		{"\x1b[<32;300;200M", MouseEvent{X: 299, Y: 199, Button: MouseLeft, Action: MouseMove}},
+		{"\x1b[<35;3;4M", MouseEvent{X: 2, Y: 3, Button: MouseNone, Action: MouseMove}},
+		{"\x1b[<65;1;1M", MouseEvent{Button: MouseWheelDown}},
+		{"\x1b[<17;1;1M", MouseEvent{Button: MouseMiddle, Mod: ModCtrl}},
+		{"\x1b[<12;1;1M", MouseEvent{Button: MouseLeft, Mod: ModShift | ModAlt}},
+		{"\x1b[M !!", MouseEvent{Button: MouseLeft}},
+		{"\x1b[M#*%", MouseEvent{X: 9, Y: 4, Button: MouseNone, Action: MouseRelease}},
+		{"\x1b[M`!!", MouseEvent{Button: MouseWheelUp}},
+	}
+	for _, tt := range tests {
+		got, n := DecodeMouse([]byte(tt.in + "x"))
+		if got != tt.want || n != len(tt.in) {
+			t.Errorf("DecodeMouse(%q) = %+v, %d; want %+v, %d", tt.in, got, n, tt.want, len(tt.in))
+		}
+	}
+	for _, in := range []string{"\x1b", "\x1b[", "\x1b[<", "\x1b[<0;10", "\x1b[M !"} {
+		if _, n := DecodeMouse([]byte(in)); n != 0 {
+			t.Errorf("DecodeMouse(%q) = %d, want 0 for an incomplete sequence", in, n)
+		}
+	}
+	for _, in := range []string{"a", "\x1b[A", "\x1bOP", "\x1b[<0;1M", "\x1b[<0;1;1;1M"} {
+		if _, n := DecodeMouse([]byte(in)); n >= 0 {
+			t.Errorf("DecodeMouse(%q) = %d, want < 0 for no mouse sequence", in, n)
+		}
+	}
+}
+
+func TestDecodeKeysSkipsMouse(t *testing.T) {
+	got, rest := decodeKeys([]byte("a\x1b[<0;10;5Mb\x1b[M !!c\x1b[M "), false)
+	if want := []KeyEvent{{Rune: 'a'}, {Rune: 'b'}, {Rune: 'c'}}; !slices.Equal(got, want) || string(rest) != "\x1b[M " {
+		t.Errorf("decodeKeys = %v, %q; want %v and the incomplete mouse sequence", got, rest, want)
+	}
+}
@@ -0,0 +1,64 @@
+//go:build unix
+
+package term
+
+import (
+	"strconv"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// A MouseMode is the set of mouse events a terminal reports, the number of
+// its xterm private mode.
+type MouseMode int
+
+const (
+	MouseClicks MouseMode = 1000 // presses and releases of buttons, and the wheel
+	MouseDrags  MouseMode = 1002 // and motion while a button is held
+	MouseMotion MouseMode = 1003 // and all motion
+)
+
+// EnableMouse makes the terminal fd report the mouse events of mode, in the
+// SGR encoding of mode 1006 if the terminal supports it and in the X10 one
+// otherwise, both decoded by DecodeMouse. It returns a function turning
+// reporting off again, which may be called more than once and is meant to
+// be deferred: a terminal left reporting the mouse after the program exits
+// sends its sequences to the shell.
+//
+// A KeyReader skips mouse sequences.
+func EnableMouse(fd int, mode MouseMode) (restore func() error, err error) {
+	switch mode {
+	case MouseClicks, MouseDrags, MouseMotion:
+	default:
+		return nil, unix.EINVAL
+	}
+	m := strconv.Itoa(int(mode))
+	if err := writeAll(fd, "\x1b[?"+m+"h\x1b[?1006h"); err != nil {
+		return nil, err
+	}
+	var (
+		once       sync.Once
+		restoreErr error
+	)
+	return func() error {
+		once.Do(func() { restoreErr = writeAll(fd, "\x1b[?1006l\x1b[?"+m+"l") })
+		return restoreErr
+	}, nil
+}
+
+// writeAll writes s to fd.
+func writeAll(fd int, s string) error {
+	b := []byte(s)
+	for len(b) > 0 {
+		n, err := unix.Write(fd, b)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		b = b[n:]
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+//go:build unix
+
+package term
+
+import "sync"
+
+// The modes of a terminal managed by EnterAltScreen and its functions.
+const (
+	modeOrigin        Mode = 6    // DECOM: cursor positions relative to the scrolling region
+	modeCursorVisible Mode = 25   // DECTCEM
+	modeAltScreen     Mode = 1049 // alternate screen, saving the cursor like DECSC
+)
+
+// screenState is the state of a terminal managed by EnterAltScreen and its
+// functions. Its zero value is the state of new terminals.
+type screenState struct {
+	alt          bool
+	cursorHidden bool
+	origin       bool
+}
+
+// A screen is a terminal in another state than that of new terminals.
+type screen struct {
+	write func(s string) error
+	cur   screenState
+	saved []screenState // by EnterAltScreen
+}
+
+// screens holds the terminals in another state than that of new terminals,
+// by file descriptor.
+var screens struct {
+	sync.Mutex
+	m map[int]*screen
+}
+
+// EnterAltScreen switches the terminal fd to its alternate screen, where
+// games draw without scrolling away the contents of the terminal, and
+// saves its cursor visibility and origin mode, to be restored by the
+// matching call of ExitAltScreen:
+//
+//	if err := term.EnterAltScreen(fd); err != nil {
+//		return err
+//	}
+//	defer term.ExitAltScreen(fd)
+//	term.SetCursorVisible(fd, false)
+//
+// Until the terminal is back in the state of new terminals, SIGHUP, SIGINT,
+// SIGQUIT, SIGTERM and SIGSEGV sent to the program restore it before ending
+// the program, as they do by default, even for programs handling them with
+// signal.Notify, unless left to them with Guard.LeaveSignals; those ignored
+// when the program started are left ignored.
+// A deferred ExitAltScreen restores the terminal after a panic of its
+// goroutine as well. A Guard restores the rest of the state of terminals
+// too, and after panics of other goroutines.
+func EnterAltScreen(fd int) error {
+	return changeScreen(fd, (*screen).enter)
+}
+
+// ExitAltScreen switches the terminal fd back to the screen, cursor
+// visibility and origin mode it had before the last call of
+// EnterAltScreen. It does nothing if there is no such call.
+func ExitAltScreen(fd int) error {
+	return changeScreen(fd, (*screen).exit)
+}
+
+// SetCursorVisible shows or hides the cursor of the terminal fd.
+func SetCursorVisible(fd int, visible bool) error {
+	return changeScreen(fd, func(s *screen) error {
+		st := s.cur
+		st.cursorHidden = !visible
+		return s.set(st)
+	})
+}
+
+// SetOriginMode sets or resets the origin mode of the terminal fd, where
+// cursor positions are relative to its scrolling region.
+func SetOriginMode(fd int, on bool) error {
+	return changeScreen(fd, func(s *screen) error {
+		st := s.cur
+		st.origin = on
+		return s.set(st)
+	})
+}
+
+// changeScreen applies change to the screen of the terminal fd, and keeps
+// the terminals restored on signals while they are changed.
+func changeScreen(fd int, change func(s *screen) error) error {
+	screens.Lock()
+	defer screens.Unlock()
+	s := screens.m[fd]
+	if s == nil {
+		s = &screen{write: func(seq string) error { return writeAll(fd, seq) }}
+	}
+	err := change(s)
+	if s.cur != (screenState{}) || len(s.saved) > 0 {
+		if screens.m == nil {
+			screens.m = make(map[int]*screen)
+		}
+		screens.m[fd] = s
+	} else {
+		delete(screens.m, fd)
+	}
+	if len(screens.m) > 0 {
+		restoreOnSignal(&screens, restoreScreens)
+	} else {
+		restoreOnSignal(&screens, nil)
+	}
+	return err
+}
+
+// restoreScreens puts the terminals back in the state of new terminals.
+func restoreScreens() {
+	screens.Lock()
+	defer screens.Unlock()
+	for fd, s := range screens.m {
+		s.saved = nil
+		s.set(screenState{})
+		delete(screens.m, fd)
+	}
+}
+
+// enter switches s to the alternate screen, saving its state.
+func (s *screen) enter() error {
+	prev := s.cur
+	st := s.cur
+	st.alt = true
+	if err := s.set(st); err != nil {
+		return err
+	}
+	s.saved = append(s.saved, prev)
+	return nil
+}
+
+// exit restores the state saved by the last call of enter.
+func (s *screen) exit() error {
+	if len(s.saved) == 0 {
+		return nil
+	}
+	last := len(s.saved) - 1
+	if err := s.set(s.saved[last]); err != nil {
+		return err
+	}
+	s.saved = s.saved[:last]
+	return nil
+}
+
+// set writes the sequences changing the state of s to st. The screen is
+// switched first, as the alternate screen saves and restores the origin
+// mode with the cursor.
+func (s *screen) set(st screenState) error {
+	var seq string
+	for _, m := range []struct {
+		mode     Mode
+		old, new bool
+	}{
+		{modeAltScreen, s.cur.alt, st.alt},
+		{modeCursorVisible, !s.cur.cursorHidden, !st.cursorHidden},
+		{modeOrigin, s.cur.origin, st.origin},
+	} {
+		switch {
+		case m.new && !m.old:
+			seq += modeSequence([]Mode{m.mode}, 'h')
+		case m.old && !m.new:
+			seq += modeSequence([]Mode{m.mode}, 'l')
+		}
+	}
+	if seq == "" {
+		return nil
+	}
+	if err := s.write(seq); err != nil {
+		return err
+	}
+	s.cur = st
+	return nil
+}
@@ -0,0 +1,41 @@
+//go:build unix
+
+package term
+
+import "testing"
+
+func TestScreen(t *testing.T) {
+	var out string
+	s := &screen{write: func(seq string) error {
+		out += seq
+		return nil
+	}}
+	visible := func(v bool) func() error {
+		return func() error {
+			st := s.cur
+			st.cursorHidden = !v
+			return s.set(st)
+		}
+	}
+	steps := []struct {
+		op   func() error
+		want string
+	}{
+		{s.enter, "\x1b[?1049h"},
+		{visible(false), "\x1b[?25l"},
+		{s.enter, ""},
+		{func() error { return s.set(screenState{alt: true, cursorHidden: true, origin: true}) }, "\x1b[?6h"},
+		{s.exit, "\x1b[?6l"},
+		{s.exit, "\x1b[?1049l\x1b[?25h"},
+		{s.exit, ""},
+	}
+	for i, step := range steps {
+		out = ""
+		if err := step.op(); err != nil || out != step.want {
+			t.Errorf("step %d: wrote %q, %v; want %q", i, out, err, step.want)
+		}
+	}
+	if s.cur != (screenState{}) || len(s.saved) != 0 {
+		t.Errorf("after the last exit: state %+v, %d saved; want the state of new terminals", s.cur, len(s.saved))
+	}
+}
@@ -0,0 +1,106 @@
+//go:build unix
+
+package term
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// restoreSignals are the signals restoring terminals before they end the
+// program.
+var restoreSignals = []os.Signal{unix.SIGHUP, unix.SIGINT, unix.SIGQUIT, unix.SIGTERM, unix.SIGSEGV}
+
+// onSignal holds the functions restoring terminals when one of
+// restoreSignals is received, the keys of leaveSignals, and the channel of
+// those signals, which is non-nil while there are functions and no keys.
+var onSignal struct {
+	sync.Mutex
+	restore map[any]func()
+	left    map[any]bool
+	signals chan os.Signal
+}
+
+// restoreOnSignal registers restore under key, to be called when one of
+// restoreSignals is received, before the signal ends the program with its
+// default action, even if the program handles it with signal.Notify,
+// unless it is left to the program with leaveSignals. A nil restore
+// unregisters key. The signals ignored when the program started are left
+// ignored.
+func restoreOnSignal(key any, restore func()) {
+	onSignal.Lock()
+	defer onSignal.Unlock()
+	if restore == nil {
+		delete(onSignal.restore, key)
+	} else {
+		if onSignal.restore == nil {
+			onSignal.restore = make(map[any]func())
+		}
+		onSignal.restore[key] = restore
+	}
+	updateSignals()
+}
+
+// leaveSignals registers key, while leave, as a program handling
+// restoreSignals itself, which stops the functions of restoreOnSignal from
+// being called and the signals from being handled at all.
+func leaveSignals(key any, leave bool) {
+	onSignal.Lock()
+	defer onSignal.Unlock()
+	if leave {
+		if onSignal.left == nil {
+			onSignal.left = make(map[any]bool)
+		}
+		onSignal.left[key] = true
+	} else {
+		delete(onSignal.left, key)
+	}
+	updateSignals()
+}
+
+// updateSignals handles restoreSignals while there are functions to call
+// and no keys of leaveSignals. onSignal must be locked.
+func updateSignals() {
+	handle := len(onSignal.restore) > 0 && len(onSignal.left) == 0
+	switch {
+	case handle && onSignal.signals == nil:
+		onSignal.signals = make(chan os.Signal, 1)
+		for _, sig := range restoreSignals {
+			// Signals ignored, as by nohup, are left so.
+			if !signal.Ignored(sig) {
+				signal.Notify(onSignal.signals, sig)
+			}
+		}
+		go handleSignal(onSignal.signals)
+	case !handle && onSignal.signals != nil:
+		signal.Stop(onSignal.signals)
+		close(onSignal.signals)
+		onSignal.signals = nil
+	}
+}
+
+// handleSignal calls the functions registered with restoreOnSignal once a
+// signal is received on c, and sends it again with its default action.
+func handleSignal(c chan os.Signal) {
+	sig, ok := <-c
+	if !ok {
+		return
+	}
+	onSignal.Lock()
+	restore := onSignal.restore
+	onSignal.restore = nil
+	if onSignal.signals == c {
+		signal.Stop(c)
+		onSignal.signals = nil
+	}
+	onSignal.Unlock()
+	// Not under the lock, as the functions may unregister themselves.
+	for _, f := range restore {
+		f()
+	}
+	signal.Reset(sig)
+	unix.Kill(unix.Getpid(), sig.(unix.Signal))
+}
@@ -0,0 +1,27 @@
+//go:build unix
+
+package term
+
+import "testing"
+
+func TestRestoreOnSignal(t *testing.T) {
+	a, b := new(int), new(int)
+	restoreOnSignal(a, func() {})
+	restoreOnSignal(b, func() {})
+	restoreOnSignal(a, nil)
+	if onSignal.signals == nil {
+		t.Error("signals not handled while a function is registered")
+	}
+	leaveSignals(a, true)
+	if onSignal.signals != nil {
+		t.Error("signals handled while left to the program")
+	}
+	leaveSignals(a, false)
+	if onSignal.signals == nil {
+		t.Error("signals not handled once no longer left to the program")
+	}
+	restoreOnSignal(b, nil)
+	if onSignal.signals != nil || len(onSignal.restore) != 0 {
+		t.Errorf("signals handled after unregistering all the functions: %d registered", len(onSignal.restore))
+	}
+}
@@ -0,0 +1,40 @@
+// Package term puts terminals in raw mode and back in the same way on
+// every platform, so that terminal games need neither a package per
+// platform nor build-tagged shims of their own:
+//
+//	if term.IsTerminal(int(os.Stdin.Fd())) {
+//		state, err := term.MakeRaw(int(os.Stdin.Fd()))
+//		if err != nil {
+//			return err
+//		}
+//		defer term.Restore(int(os.Stdin.Fd()), state)
+//	}
+//
+// On unix systems it uses the termios attributes of the terminal. On
+// Windows it sets the console mode, with virtual terminal sequences enabled
+// for input and output. On js/wasm it uses the terminal set with
+// termjs.SetDefault, whatever the file descriptor.
+//
+// A KeyReader reads the keys typed on a terminal without blocking, for
+// game loops. On unix systems EnterAltScreen switches terminals to their
+// alternate screen and restores them even on signals, EnableMouse makes
+// them report the mouse, in sequences decoded by DecodeMouse, and a
+// ModeStack sets other modes, such as bracketed paste. A Guard restores
+// all of this state, and raw mode, however the program ends. See
+// golang.org/x/sys/termsize for the size of terminals.
+package term
+
+// A State is the state of a terminal before MakeRaw, to give to Restore.
+type State struct {
+	state
+}
+
+// GetState returns the current state of the terminal fd, to give to
+// Restore.
+func GetState(fd int) (*State, error) {
+	s, err := getState(fd)
+	if err != nil {
+		return nil, err
+	}
+	return &State{*s}, nil
+}
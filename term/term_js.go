@@ -0,0 +1,70 @@
+//go:build js && wasm
+
+package term
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/termjs"
+)
+
+type state struct {
+	termios termjs.Termios
+}
+
+// terminal returns the terminal set with termjs.SetDefault, or ENOTTY if
+// there is none.
+func terminal() (*termjs.Terminal, error) {
+	t := termjs.Default()
+	if t == nil {
+		return nil, syscall.ENOTTY
+	}
+	return t, nil
+}
+
+// IsTerminal reports whether a terminal was set with termjs.SetDefault and
+// fd is one of the standard input, output and error, which are connected
+// to it.
+func IsTerminal(fd int) bool {
+	return termjs.Default() != nil && fd >= 0 && fd <= 2
+}
+
+// MakeRaw puts the terminal set with termjs.SetDefault in raw mode, with
+// termjs.MakeRaw, and returns its previous state.
+func MakeRaw(fd int) (*State, error) {
+	t, err := terminal()
+	if err != nil {
+		return nil, err
+	}
+	termios, err := t.Tcgetattr()
+	if err != nil {
+		return nil, err
+	}
+	old := State{state{*termios}}
+	termjs.MakeRaw(termios)
+	if err := t.Tcsetattr(termios); err != nil {
+		return nil, err
+	}
+	return &old, nil
+}
+
+func getState(fd int) (*state, error) {
+	t, err := terminal()
+	if err != nil {
+		return nil, err
+	}
+	termios, err := t.Tcgetattr()
+	if err != nil {
+		return nil, err
+	}
+	return &state{*termios}, nil
+}
+
+// Restore puts the terminal set with termjs.SetDefault back in state.
+func Restore(fd int, state *State) error {
+	t, err := terminal()
+	if err != nil {
+		return err
+	}
+	return t.Tcsetattr(&state.termios)
+}
@@ -0,0 +1,26 @@
+//go:build !unix && !windows && !(js && wasm)
+
+package term
+
+import "errors"
+
+type state struct{}
+
+// IsTerminal returns false: there are no terminals on this platform.
+func IsTerminal(fd int) bool {
+	return false
+}
+
+// MakeRaw fails with errors.ErrUnsupported.
+func MakeRaw(fd int) (*State, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func getState(fd int) (*state, error) {
+	return nil, errors.ErrUnsupported
+}
+
+// Restore fails with errors.ErrUnsupported.
+func Restore(fd int, state *State) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,35 @@
+//go:build unix
+
+package term
+
+import (
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestMakeRawTermios(t *testing.T) {
+	// The attributes of a new terminal in cooked mode.
+	termios := unix.Termios{
+		Iflag: unix.ICRNL | unix.IXON | unix.BRKINT,
+		Oflag: unix.OPOST | unix.ONLCR,
+		Lflag: unix.ECHO | unix.ECHOE | unix.ICANON | unix.ISIG | unix.IEXTEN,
+		Cflag: unix.CS7 | unix.PARENB | unix.CREAD,
+	}
+	makeRaw(&termios)
+	if termios.Iflag&(unix.ICRNL|unix.IXON|unix.BRKINT) != 0 {
+		t.Errorf("Iflag = %#x, want input translation and flow control off", termios.Iflag)
+	}
+	if termios.Oflag&unix.OPOST != 0 {
+		t.Errorf("Oflag = %#x, want OPOST off", termios.Oflag)
+	}
+	if termios.Lflag&(unix.ECHO|unix.ICANON|unix.ISIG|unix.IEXTEN) != 0 {
+		t.Errorf("Lflag = %#x, want echo, canonical mode and signals off", termios.Lflag)
+	}
+	if termios.Cflag&(unix.CSIZE|unix.PARENB|unix.CREAD) != unix.CS8|unix.CREAD {
+		t.Errorf("Cflag = %#x, want CS8 and CREAD without parity", termios.Cflag)
+	}
+	if termios.Cc[unix.VMIN] != 1 || termios.Cc[unix.VTIME] != 0 {
+		t.Errorf("VMIN, VTIME = %d, %d; want 1, 0", termios.Cc[unix.VMIN], termios.Cc[unix.VTIME])
+	}
+}
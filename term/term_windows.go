@@ -0,0 +1,59 @@
+package term
+
+import "golang.org/x/sys/windows"
+
+type state struct {
+	mode uint32
+
+	// restoreOut restores the mode of the console output, if MakeRaw
+	// changed it.
+	restoreOut func() error
+}
+
+// IsTerminal reports whether fd is a console handle.
+func IsTerminal(fd int) bool {
+	var mode uint32
+	return windows.GetConsoleMode(windows.Handle(fd), &mode) == nil
+}
+
+// MakeRaw puts the console input fd in raw mode and returns its previous
+// state: input is read a key at a time, without echo or processing of
+// ^C, and arrives as virtual terminal sequences. The console output, if
+// any, then processes virtual terminal sequences, without the carriage
+// return added to line feeds, like a unix terminal without OPOST.
+func MakeRaw(fd int) (*State, error) {
+	h := windows.Handle(fd)
+	var mode uint32
+	if err := windows.GetConsoleMode(h, &mode); err != nil {
+		return nil, err
+	}
+	raw := mode &^ (windows.ENABLE_ECHO_INPUT | windows.ENABLE_PROCESSED_INPUT | windows.ENABLE_LINE_INPUT)
+	raw |= windows.ENABLE_VIRTUAL_TERMINAL_INPUT
+	if err := windows.SetConsoleMode(h, raw); err != nil {
+		return nil, err
+	}
+	old := &State{state{mode: mode}}
+	if out, err := windows.GetStdHandle(windows.STD_OUTPUT_HANDLE); err == nil && out != h {
+		old.restoreOut, _ = windows.EnableVirtualTerminal(out)
+	}
+	return old, nil
+}
+
+func getState(fd int) (*state, error) {
+	var mode uint32
+	if err := windows.GetConsoleMode(windows.Handle(fd), &mode); err != nil {
+		return nil, err
+	}
+	return &state{mode: mode}, nil
+}
+
+// Restore puts the console fd, and its output if MakeRaw changed it, back
+// in state.
+func Restore(fd int, state *State) error {
+	if state.restoreOut != nil {
+		if err := state.restoreOut(); err != nil {
+			return err
+		}
+	}
+	return windows.SetConsoleMode(windows.Handle(fd), state.mode)
+}
@@ -0,0 +1,216 @@
+//go:build js && wasm
+
+package termjs
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"syscall/js"
+)
+
+// ErrClosed is returned by the methods of a closed Terminal.
+var ErrClosed = errors.New("termjs: terminal closed")
+
+// A Terminal is the terminal device of a program, backed by an xterm.js
+// terminal. It is safe for concurrent use.
+type Terminal struct {
+	term js.Value
+
+	mu      sync.Mutex
+	d       discipline
+	ready   chan struct{} // signaled when input arrives or t is closed; never closed
+	notify  []chan<- Winsize
+	closed  bool
+	funcs   []js.Func
+	handles []js.Value // disposables of the event handlers
+}
+
+var (
+	defaultMu   sync.Mutex
+	defaultTerm *Terminal
+)
+
+// SetDefault makes t the terminal of the program: the one packages taking
+// the file descriptor of a terminal, such as golang.org/x/sys/term and
+// golang.org/x/sys/termsize, use on js/wasm whatever the descriptor. A
+// program in the browser has one terminal at most.
+func SetDefault(t *Terminal) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultTerm = t
+}
+
+// Default returns the terminal set with SetDefault, or nil if there is
+// none.
+func Default() *Terminal {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	return defaultTerm
+}
+
+// New returns a Terminal reading the input of term and writing to it. It
+// starts in the cooked mode of a new terminal: canonical input with echo.
+func New(term js.Value) *Terminal {
+	t := &Terminal{term: term, d: discipline{termios: defaultTermios}, ready: make(chan struct{}, 1)}
+	onData := js.FuncOf(func(this js.Value, args []js.Value) any {
+		t.input(args[0].String())
+		return nil
+	})
+	onResize := js.FuncOf(func(this js.Value, args []js.Value) any {
+		t.resized(Winsize{Row: uint16(args[0].Get("rows").Int()), Col: uint16(args[0].Get("cols").Int())})
+		return nil
+	})
+	t.funcs = []js.Func{onData, onResize}
+	t.handles = []js.Value{term.Call("onData", onData), term.Call("onResize", onResize)}
+	return t
+}
+
+// input runs in the JavaScript event loop, so it must not block.
+func (t *Terminal) input(data string) {
+	t.mu.Lock()
+	echo := t.d.input([]byte(data))
+	ready := t.d.ready()
+	t.mu.Unlock()
+	if len(echo) > 0 {
+		t.term.Call("write", string(echo))
+	}
+	if ready {
+		t.wake()
+	}
+}
+
+func (t *Terminal) resized(ws Winsize) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, c := range t.notify {
+		select {
+		case c <- ws:
+		default:
+		}
+	}
+}
+
+func (t *Terminal) wake() {
+	select {
+	case t.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Read reads the input typed on the terminal, waiting for some to be
+// available: whole lines in canonical mode, and any byte otherwise. It
+// returns io.EOF once for each ^D typed on an empty line in canonical mode.
+func (t *Terminal) Read(p []byte) (int, error) {
+	for {
+		t.mu.Lock()
+		if t.closed {
+			t.mu.Unlock()
+			// Pass the wakeup of Close on to other readers.
+			t.wake()
+			return 0, ErrClosed
+		}
+		if t.d.ready() {
+			n, eof := t.d.read(p)
+			more := t.d.ready()
+			t.mu.Unlock()
+			if more {
+				// Let other readers in.
+				t.wake()
+			}
+			if eof {
+				return 0, io.EOF
+			}
+			return n, nil
+		}
+		t.mu.Unlock()
+		<-t.ready
+	}
+}
+
+// Write writes p to the terminal, translating newlines with OPOST and
+// ONLCR.
+func (t *Terminal) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return 0, ErrClosed
+	}
+	out := t.d.output(p)
+	t.mu.Unlock()
+	t.term.Call("write", string(out))
+	return len(p), nil
+}
+
+// Tcgetattr returns the terminal attributes of t.
+func (t *Terminal) Tcgetattr() (*Termios, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil, ErrClosed
+	}
+	termios := t.d.termios
+	return &termios, nil
+}
+
+// Tcsetattr sets the terminal attributes of t, taking effect immediately,
+// like TCSANOW. Leaving canonical mode makes the pending line available to
+// read.
+func (t *Terminal) Tcsetattr(termios *Termios) error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return ErrClosed
+	}
+	if termios.Lflag&ICANON == 0 && len(t.d.line) > 0 {
+		t.d.avail = append(t.d.avail, t.d.line...)
+		t.d.line = t.d.line[:0]
+	}
+	t.d.termios = *termios
+	ready := t.d.ready()
+	t.mu.Unlock()
+	if ready {
+		t.wake()
+	}
+	return nil
+}
+
+// GetWinsize returns the size of the terminal, like the TIOCGWINSZ ioctl.
+// The pixel sizes are not known and are 0.
+func (t *Terminal) GetWinsize() (*Winsize, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil, ErrClosed
+	}
+	return &Winsize{Row: uint16(t.term.Get("rows").Int()), Col: uint16(t.term.Get("cols").Int())}, nil
+}
+
+// NotifyResize causes the new size of the terminal to be sent on c when it
+// is resized, the equivalent of SIGWINCH. As with signal.Notify, the sends
+// do not block, so c should be buffered.
+func (t *Terminal) NotifyResize(c chan<- Winsize) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.notify = append(t.notify, c)
+}
+
+// Close stops reading the input of the terminal and releases its event
+// handlers. Blocked and later calls of Read return ErrClosed.
+func (t *Terminal) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return ErrClosed
+	}
+	t.closed = true
+	t.mu.Unlock()
+	for _, h := range t.handles {
+		h.Call("dispose")
+	}
+	for _, f := range t.funcs {
+		f.Release()
+	}
+	t.wake()
+	return nil
+}
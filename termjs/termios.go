@@ -0,0 +1,180 @@
+// Package termjs provides the terminal I/O of a program compiled to js/wasm
+// on top of an xterm.js terminal, or any object with the same onData,
+// onResize and write methods and cols and rows properties, on the page.
+//
+// A Terminal implements the equivalents of read and write on the terminal
+// device and of Tcgetattr, Tcsetattr and the TIOCGWINSZ ioctl, so that
+// terminal games built with this fork can run in the browser with raw-mode
+// key input and resize events:
+//
+//	t := termjs.New(js.Global().Get("term"))
+//	termios, _ := t.Tcgetattr()
+//	termjs.MakeRaw(termios)
+//	t.Tcsetattr(termios)
+//
+// The line discipline is the subset of the POSIX one that matters to
+// terminal programs: canonical input with erase and kill, echo, and the
+// translation of carriage returns on input and of newlines on output. There
+// are no signals in the browser, so with ISIG the interrupt and quit
+// characters only discard the pending line.
+package termjs
+
+import "unicode/utf8"
+
+// Termios holds the terminal attributes of a Terminal. The flags have the
+// values of their Linux counterparts; the others are ignored.
+type Termios struct {
+	Iflag uint32
+	Oflag uint32
+	Cflag uint32
+	Lflag uint32
+}
+
+// Input flags.
+const (
+	ICRNL = 0x100 // translate carriage return to newline on input
+)
+
+// Output flags.
+const (
+	OPOST = 0x1 // post-process output
+	ONLCR = 0x4 // translate newline to carriage return-newline on output
+)
+
+// Local flags.
+const (
+	ISIG   = 0x1 // discard the pending line on the interrupt and quit characters
+	ICANON = 0x2 // canonical input: line editing, reads return whole lines
+	ECHO   = 0x8 // echo input
+)
+
+// Control characters recognized in canonical mode.
+const (
+	vintr  = 0x03 // ^C
+	vquit  = 0x1c // ^\
+	veof   = 0x04 // ^D
+	vkill  = 0x15 // ^U
+	verase = 0x7f // DEL, sent by the backspace key of xterm.js
+	vbs    = 0x08 // ^H, also treated as erase
+)
+
+// defaultTermios are the attributes of a new Terminal, those of a cooked
+// terminal.
+var defaultTermios = Termios{
+	Iflag: ICRNL,
+	Oflag: OPOST | ONLCR,
+	Lflag: ISIG | ICANON | ECHO,
+}
+
+// MakeRaw sets t to raw mode, like cfmakeraw: input is available byte by
+// byte, without echo or translation, and output is not processed.
+func MakeRaw(t *Termios) {
+	t.Iflag &^= ICRNL
+	t.Oflag &^= OPOST
+	t.Lflag &^= ISIG | ICANON | ECHO
+}
+
+// Winsize is the size of a terminal, as returned by the TIOCGWINSZ ioctl.
+type Winsize struct {
+	Row    uint16
+	Col    uint16
+	Xpixel uint16
+	Ypixel uint16
+}
+
+// discipline is the line discipline of a Terminal, kept apart from the
+// JavaScript bindings. It is not safe for concurrent use.
+type discipline struct {
+	termios Termios
+	line    []byte // pending canonical input
+	avail   []byte // input ready to be read
+	eof     bool   // whether ^D was typed on an empty line
+}
+
+// input processes the data typed on the terminal, making it available to
+// read, and returns the text to echo.
+func (d *discipline) input(data []byte) (echo []byte) {
+	for _, c := range data {
+		if c == '\r' && d.termios.Iflag&ICRNL != 0 {
+			c = '\n'
+		}
+		if d.termios.Lflag&ICANON == 0 {
+			d.avail = append(d.avail, c)
+			if d.termios.Lflag&ECHO != 0 {
+				echo = append(echo, c)
+			}
+			continue
+		}
+		switch {
+		case (c == vintr || c == vquit) && d.termios.Lflag&ISIG != 0:
+			d.line = d.line[:0]
+			echo = d.echo(echo, "^"+string(rune(c)+'@')+"\n")
+		case c == verase || c == vbs:
+			if len(d.line) > 0 {
+				_, size := utf8.DecodeLastRune(d.line)
+				d.line = d.line[:len(d.line)-size]
+				echo = d.echo(echo, "\b \b")
+			}
+		case c == vkill:
+			for n := utf8.RuneCount(d.line); n > 0; n-- {
+				echo = d.echo(echo, "\b \b")
+			}
+			d.line = d.line[:0]
+		case c == veof:
+			if len(d.line) == 0 {
+				d.eof = true
+			}
+			d.avail = append(d.avail, d.line...)
+			d.line = d.line[:0]
+		case c == '\n':
+			d.avail = append(append(d.avail, d.line...), '\n')
+			d.line = d.line[:0]
+			echo = d.echo(echo, "\n")
+		default:
+			d.line = append(d.line, c)
+			// The byte itself: string(c) would encode it as a rune.
+			echo = d.echo(echo, string([]byte{c}))
+		}
+	}
+	return echo
+}
+
+// echo appends s to echo if ECHO is set, translating newlines as output.
+func (d *discipline) echo(echo []byte, s string) []byte {
+	if d.termios.Lflag&ECHO == 0 {
+		return echo
+	}
+	return append(echo, d.output([]byte(s))...)
+}
+
+// read moves the input available to p, reporting end of file once for each
+// ^D typed on an empty line.
+func (d *discipline) read(p []byte) (n int, eof bool) {
+	if len(d.avail) == 0 && d.eof {
+		d.eof = false
+		return 0, true
+	}
+	n = copy(p, d.avail)
+	d.avail = d.avail[n:]
+	return n, false
+}
+
+// ready reports whether read would return without waiting for input.
+func (d *discipline) ready() bool {
+	return len(d.avail) > 0 || d.eof
+}
+
+// output returns p as written to the terminal.
+func (d *discipline) output(p []byte) []byte {
+	if d.termios.Oflag&(OPOST|ONLCR) != OPOST|ONLCR {
+		return p
+	}
+	out := make([]byte, 0, len(p))
+	for _, c := range p {
+		if c == '\n' {
+			out = append(out, '\r')
+		}
+		out = append(out, c)
+	}
+	return out
+}
@@ -0,0 +1,66 @@
+package termjs
+
+import "testing"
+
+func TestCanonical(t *testing.T) {
+	d := discipline{termios: defaultTermios}
+	echo := d.input([]byte("lx\x7fs\r"))
+	if got, want := string(echo), "lx\b \bs\r\n"; got != want {
+		t.Errorf("echo = %q, want %q", got, want)
+	}
+	p := make([]byte, 16)
+	n, eof := d.read(p)
+	if got := string(p[:n]); got != "ls\n" || eof {
+		t.Errorf("read = %q, %v; want %q, false", got, eof, "ls\n")
+	}
+
+	d.input([]byte("abc\x15d\x03e"))
+	if d.ready() || string(d.line) != "e" {
+		t.Errorf("after kill and interrupt: ready %v, line %q; want false, %q", d.ready(), d.line, "e")
+	}
+	d.input([]byte("\x04\x04"))
+	if n, eof := d.read(p); string(p[:n]) != "e" || eof {
+		t.Errorf("read after ^D = %q, %v; want %q, false", p[:n], eof, "e")
+	}
+	if n, eof := d.read(p); n != 0 || !eof {
+		t.Errorf("read after ^D on an empty line = %d, %v; want 0, true", n, eof)
+	}
+}
+
+func TestCanonicalUTF8(t *testing.T) {
+	d := discipline{termios: defaultTermios}
+	if got, want := string(d.input([]byte("é世"))), "é世\r\n"; got != want {
+		t.Errorf("echo = %q, want %q", got, want)
+	}
+	p := make([]byte, 16)
+	if n, _ := d.read(p); string(p[:n]) != "é世\n" {
+		t.Errorf("read = %q, want %q", p[:n], "é世\n")
+	}
+}
+
+func TestRaw(t *testing.T) {
+	d := discipline{termios: defaultTermios}
+	MakeRaw(&d.termios)
+	if echo := d.input([]byte("q\r\x03\x7f")); len(echo) != 0 {
+		t.Errorf("raw mode echoed %q", echo)
+	}
+	p := make([]byte, 2)
+	n, _ := d.read(p)
+	if got := string(p[:n]); got != "q\r" {
+		t.Errorf("read = %q, want %q", got, "q\r")
+	}
+	n, _ = d.read(p)
+	if got := string(p[:n]); got != "\x03\x7f" {
+		t.Errorf("second read = %q, want %q", got, "\x03\x7f")
+	}
+	if got := string(d.output([]byte("a\nb"))); got != "a\nb" {
+		t.Errorf("raw output = %q, want %q", got, "a\nb")
+	}
+}
+
+func TestOutput(t *testing.T) {
+	d := discipline{termios: defaultTermios}
+	if got, want := string(d.output([]byte("a\nb\n"))), "a\r\nb\r\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
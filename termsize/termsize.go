@@ -0,0 +1,97 @@
+// Package termsize reports the size of a terminal and its changes in the
+// same way on every platform, which terminal games would otherwise each
+// implement three times:
+//
+//	ws, err := termsize.GetWinsize(int(os.Stdout.Fd()))
+//	...
+//	for ws := range termsize.WatchWinsize(int(os.Stdout.Fd())) {
+//		redraw(ws)
+//	}
+//
+// On unix systems the size is that of the TIOCGWINSZ ioctl, checked again
+// on every SIGWINCH. On Windows it is the window of the console screen
+// buffer, checked again every 200ms, so changes are reported up to that
+// late. The console has no resize signal, and WatchWinsize does not read
+// its WINDOW_BUFFER_SIZE_EVENT records: they come in the console input,
+// and reading them would take the key events in between from the program,
+// such as from a golang.org/x/sys/term.KeyReader. On js/wasm it is the size
+// of the terminal set with termjs.SetDefault, which must be called before
+// WatchWinsize.
+package termsize
+
+import "sync"
+
+// Winsize is the size of a terminal, in characters and, where known, in
+// pixels. It has the fields of unix.Winsize.
+type Winsize struct {
+	Row    uint16
+	Col    uint16
+	Xpixel uint16
+	Ypixel uint16
+}
+
+// WatchWinsize returns a channel receiving the size of the terminal fd
+// each time it changes. The channel holds the latest size only: sizes not
+// received before the next change are dropped. Watching lasts for the life
+// of the program, and all the calls for the same fd share one watcher.
+func WatchWinsize(fd int) <-chan Winsize {
+	c := make(chan Winsize, 1)
+	watchersMu.Lock()
+	defer watchersMu.Unlock()
+	w := watchers[fd]
+	if w == nil {
+		w = new(watcher)
+		check := changes()
+		// Get the size before returning, so that later changes are not missed.
+		last, _ := GetWinsize(fd)
+		go watch(func() (*Winsize, error) { return GetWinsize(fd) }, last, check, w)
+		watchers[fd] = w
+	}
+	w.add(c)
+	return c
+}
+
+var (
+	watchersMu sync.Mutex
+	watchers   = make(map[int]*watcher) // by fd
+)
+
+// A watcher sends the sizes of a terminal to the channels returned by
+// WatchWinsize for it.
+type watcher struct {
+	mu sync.Mutex
+	cs []chan Winsize
+}
+
+func (w *watcher) add(c chan Winsize) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.cs = append(w.cs, c)
+}
+
+// send sends ws on every channel of w, replacing the size not received
+// yet, if any.
+func (w *watcher) send(ws Winsize) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, c := range w.cs {
+		select {
+		case <-c:
+		default:
+		}
+		c <- ws
+	}
+}
+
+// watch sends the size returned by get to w each time it differs from the
+// last one, starting from last, checking it after every receive from check.
+func watch[T any](get func() (*Winsize, error), last *Winsize, check <-chan T, w *watcher) {
+	for range check {
+		ws, err := get()
+		if err != nil || last != nil && *ws == *last {
+			continue
+		}
+		last = ws
+		w.send(*ws)
+	}
+}
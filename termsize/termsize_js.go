@@ -0,0 +1,35 @@
+//go:build js && wasm
+
+package termsize
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/termjs"
+)
+
+// GetWinsize returns the size of the terminal set with termjs.SetDefault.
+// It fails with ENOTTY if there is none.
+func GetWinsize(fd int) (*Winsize, error) {
+	t := termjs.Default()
+	if t == nil {
+		return nil, syscall.ENOTTY
+	}
+	ws, err := t.GetWinsize()
+	if err != nil {
+		return nil, err
+	}
+	return (*Winsize)(ws), nil
+}
+
+// changes returns a channel receiving the resizes of the terminal set with
+// termjs.SetDefault, or nil if there is none.
+func changes() <-chan termjs.Winsize {
+	t := termjs.Default()
+	if t == nil {
+		return nil
+	}
+	c := make(chan termjs.Winsize, 1)
+	t.NotifyResize(c)
+	return c
+}
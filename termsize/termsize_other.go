@@ -0,0 +1,16 @@
+//go:build !unix && !windows && !(js && wasm)
+
+package termsize
+
+import "errors"
+
+// GetWinsize fails with errors.ErrUnsupported: there are no terminals on
+// this platform.
+func GetWinsize(fd int) (*Winsize, error) {
+	return nil, errors.ErrUnsupported
+}
+
+// changes returns nil: sizes never change.
+func changes() <-chan struct{} {
+	return nil
+}
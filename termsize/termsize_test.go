@@ -0,0 +1,49 @@
+package termsize
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWatch(t *testing.T) {
+	sizes := []Winsize{
+		{Row: 24, Col: 80},  // no change
+		{Row: 50, Col: 132}, // dropped: replaced before being received
+		{},                  // error
+		{Row: 25, Col: 80},
+	}
+	get := func() (*Winsize, error) {
+		ws := sizes[0]
+		sizes = sizes[1:]
+		if ws == (Winsize{}) {
+			return nil, errors.New("not a terminal")
+		}
+		return &ws, nil
+	}
+	check := make(chan struct{})
+	w := new(watcher)
+	c1, c2 := make(chan Winsize, 1), make(chan Winsize, 1)
+	w.add(c1)
+	w.add(c2)
+	done := make(chan struct{})
+	go func() {
+		watch(get, &Winsize{Row: 24, Col: 80}, check, w)
+		close(done)
+	}()
+	for range 4 {
+		check <- struct{}{}
+	}
+	close(check)
+	<-done
+
+	for i, c := range []chan Winsize{c1, c2} {
+		if ws := <-c; ws != (Winsize{Row: 25, Col: 80}) {
+			t.Errorf("channel %d: got %v, want the latest size 25x80", i, ws)
+		}
+		select {
+		case ws := <-c:
+			t.Errorf("channel %d: got %v after the latest size", i, ws)
+		default:
+		}
+	}
+}
@@ -0,0 +1,26 @@
+//go:build unix
+
+package termsize
+
+import (
+	"os"
+	"os/signal"
+
+	"golang.org/x/sys/unix"
+)
+
+// GetWinsize returns the size of the terminal fd.
+func GetWinsize(fd int) (*Winsize, error) {
+	ws, err := unix.IoctlGetWinsize(fd, unix.TIOCGWINSZ)
+	if err != nil {
+		return nil, err
+	}
+	return (*Winsize)(ws), nil
+}
+
+// changes returns a channel receiving SIGWINCH.
+func changes() <-chan os.Signal {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, unix.SIGWINCH)
+	return c
+}
@@ -0,0 +1,28 @@
+package termsize
+
+import (
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// pollInterval is how often WatchWinsize checks the size of the console.
+const pollInterval = 200 * time.Millisecond
+
+// GetWinsize returns the size of the window of the console screen buffer
+// fd, a console handle. The pixel sizes are not known and are 0.
+func GetWinsize(fd int) (*Winsize, error) {
+	var info windows.ConsoleScreenBufferInfo
+	if err := windows.GetConsoleScreenBufferInfo(windows.Handle(fd), &info); err != nil {
+		return nil, err
+	}
+	return &Winsize{
+		Row: uint16(info.Window.Bottom - info.Window.Top + 1),
+		Col: uint16(info.Window.Right - info.Window.Left + 1),
+	}, nil
+}
+
+// changes returns a channel receiving every pollInterval.
+func changes() <-chan time.Time {
+	return time.NewTicker(pollInterval).C
+}
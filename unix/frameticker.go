@@ -0,0 +1,45 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package unix
+
+import "time"
+
+// A FrameTicker delivers ticks at a fixed rate through a file descriptor,
+// a timerfd on Linux and a kqueue with an EVFILT_TIMER event on the BSDs.
+// The file descriptor becomes readable at each tick, so that it can be
+// registered with a Poller and frames timed in the same loop as input,
+// without the drift of sleeping for a frame after each one.
+//
+// Periods are rounded to milliseconds on DragonFly BSD, NetBSD and OpenBSD,
+// whose kqueue timers have no finer unit.
+type FrameTicker struct {
+	fd int
+}
+
+// NewFrameTicker returns a FrameTicker ticking every period, starting one
+// period from now. It fails with EINVAL if period is not positive.
+func NewFrameTicker(period time.Duration) (*FrameTicker, error) {
+	if period <= 0 {
+		return nil, EINVAL
+	}
+	fd, err := newFrameTicker()
+	if err != nil {
+		return nil, err
+	}
+	t := &FrameTicker{fd: fd}
+	if err := t.Reset(period); err != nil {
+		Close(fd)
+		return nil, err
+	}
+	return t, nil
+}
+
+// Fd returns the file descriptor of t, readable when ticks are pending.
+func (t *FrameTicker) Fd() int {
+	return t.fd
+}
+
+// Close closes the file descriptor of t.
+func (t *FrameTicker) Close() error {
+	return Close(t.fd)
+}
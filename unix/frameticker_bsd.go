@@ -0,0 +1,46 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package unix
+
+import "time"
+
+func newFrameTicker() (int, error) {
+	fd, err := Kqueue()
+	if err != nil {
+		return -1, err
+	}
+	CloseOnExec(fd)
+	return fd, nil
+}
+
+// Reset makes t tick every period from now on, dropping the pending ticks,
+// or stops it if period is 0.
+func (t *FrameTicker) Reset(period time.Duration) error {
+	var ev [1]Kevent_t
+	SetKevent(&ev[0], 0, EVFILT_TIMER, EV_DELETE)
+	if _, err := Kevent(t.fd, ev[:], nil, nil); err != nil && err != ENOENT {
+		return err
+	}
+	if period == 0 {
+		return nil
+	}
+	SetKevent(&ev[0], 0, EVFILT_TIMER, EV_ADD|EV_ENABLE)
+	ev[0].Fflags = frameTimerFflags
+	ev[0].Data = int64(max(period/frameTimerUnit, 1))
+	_, err := Kevent(t.fd, ev[:], nil, nil)
+	return err
+}
+
+// Read returns the number of ticks since the last call, which is more than
+// 1 if frames were missed. It fails with EAGAIN if there are none.
+func (t *FrameTicker) Read() (ticks uint64, err error) {
+	var ev [1]Kevent_t
+	n, err := Kevent(t.fd, nil, ev[:], &Timespec{})
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, EAGAIN
+	}
+	return uint64(ev[0].Data), nil
+}
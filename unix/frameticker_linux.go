@@ -0,0 +1,24 @@
+package unix
+
+import (
+	"time"
+	"unsafe"
+)
+
+func newFrameTicker() (int, error) {
+	return TimerfdCreate(CLOCK_MONOTONIC, TFD_CLOEXEC|TFD_NONBLOCK)
+}
+
+// Reset makes t tick every period from now on, dropping the pending ticks,
+// or stops it if period is 0.
+func (t *FrameTicker) Reset(period time.Duration) error {
+	ts := NsecToTimespec(int64(period))
+	return TimerfdSettime(t.fd, 0, &ItimerSpec{Interval: ts, Value: ts}, nil)
+}
+
+// Read returns the number of ticks since the last call, which is more than
+// 1 if frames were missed. It fails with EAGAIN if there are none.
+func (t *FrameTicker) Read() (ticks uint64, err error) {
+	_, err = Read(t.fd, (*[8]byte)(unsafe.Pointer(&ticks))[:])
+	return ticks, err
+}
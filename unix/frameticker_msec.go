@@ -0,0 +1,10 @@
+//go:build dragonfly || netbsd || openbsd
+
+package unix
+
+import "time"
+
+const (
+	frameTimerFflags = 0
+	frameTimerUnit   = time.Millisecond
+)
@@ -0,0 +1,10 @@
+//go:build darwin || freebsd
+
+package unix
+
+import "time"
+
+const (
+	frameTimerFflags = NOTE_NSECONDS
+	frameTimerUnit   = time.Nanosecond
+)
@@ -0,0 +1,60 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package unix_test
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestFrameTicker(t *testing.T) {
+	if _, err := unix.NewFrameTicker(0); err != unix.EINVAL {
+		t.Errorf("NewFrameTicker(0): got %v, want EINVAL", err)
+	}
+	ft, err := unix.NewFrameTicker(10 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ft.Close()
+	if _, err := ft.Read(); err != unix.EAGAIN {
+		t.Errorf("Read before the first tick: got %v, want EAGAIN", err)
+	}
+	p, err := unix.NewPoller()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if err := p.Add(ft.Fd(), unix.PollerRead); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	events, err := p.Wait(1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Fd != ft.Fd() {
+		t.Fatalf("Wait = %+v, want the ticker readable", events)
+	}
+	if d := time.Since(start); d < 5*time.Millisecond {
+		t.Errorf("first tick after %v, want about 10ms", d)
+	}
+	if ticks, err := ft.Read(); err != nil || ticks < 1 {
+		t.Errorf("Read = %d, %v; want at least 1 tick", ticks, err)
+	}
+
+	// Missed frames are counted.
+	time.Sleep(35 * time.Millisecond)
+	if ticks, err := ft.Read(); err != nil || ticks < 2 {
+		t.Errorf("Read after 35ms = %d, %v; want at least 2 ticks", ticks, err)
+	}
+
+	if err := ft.Reset(0); err != nil {
+		t.Fatal(err)
+	}
+	if events, err := p.Wait(30); err != nil || len(events) != 0 {
+		t.Errorf("Wait after stopping = %+v, %v; want no events", events, err)
+	}
+}
@@ -0,0 +1,193 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package unix
+
+import "sync"
+
+// A Poller waits for I/O events on a set of file descriptors, with epoll on
+// Linux and kqueue on the BSDs. It owns the epoll or kqueue file
+// descriptor, records the file descriptors registered with it and reuses
+// its event buffers across calls of Wait, so that waiting does not
+// allocate. Code using it builds unchanged on every system providing it.
+//
+// Add, Modify, Remove, Registered and Wake may be called concurrently with
+// Wait, but Wait must not be called concurrently with itself or Close.
+// Once p is closed, its methods fail with EBADF.
+type Poller struct {
+	fd  int       // -1 once closed
+	sys pollerSys // buffers of Wait
+	out []PollerEvent
+
+	mu         sync.Mutex
+	registered map[int]uint32 // events by file descriptor
+}
+
+// The events of a Poller. PollerRead and PollerWrite select the events a
+// file descriptor is registered for, optionally with PollerEdge and
+// PollerOneShot, and are reported by Wait along with PollerError and
+// PollerHangup. PollerTimer and PollerSignal are reported for the timers
+// and signals registered on the BSDs.
+const (
+	PollerRead    uint32 = 1 << iota // readable
+	PollerWrite                      // writable
+	PollerEdge                       // edge-triggered
+	PollerOneShot                    // reported once, until rearmed with Modify
+	PollerError                      // error condition
+	PollerHangup                     // hang up by the peer
+	PollerTimer                      // timer expiration
+	PollerSignal                     // signal delivery
+)
+
+// A PollerEvent is an event reported by Poller.Wait.
+type PollerEvent struct {
+	// Fd is the file descriptor of the event, or the id of the timer or
+	// the number of the signal.
+	Fd int
+
+	// Events holds the Poller* events that occurred.
+	Events uint32
+
+	// Data is the number of expirations of timers and of deliveries of
+	// signals since they were last reported. It is 0 for file
+	// descriptors.
+	Data int64
+}
+
+// Fd returns the epoll or kqueue file descriptor of p, which can itself be
+// waited for by another Poller, or -1 if p is closed.
+func (p *Poller) Fd() int {
+	return p.fd
+}
+
+// Add registers fd for events, a combination of PollerRead and
+// PollerWrite with optionally PollerEdge or PollerOneShot. With PollerEdge,
+// Wait only reports fd again after new data arrives or more room is made,
+// so fd must be read or written until EAGAIN. With PollerOneShot, fd is
+// reported once and must be rearmed with Modify. Add fails with EEXIST if
+// fd is already registered.
+func (p *Poller) Add(fd int, events uint32) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fd < 0 {
+		return EBADF
+	}
+	if _, ok := p.registered[fd]; ok {
+		return EEXIST
+	}
+	if err := p.add(fd, events); err != nil {
+		return err
+	}
+	p.registered[fd] = events
+	return nil
+}
+
+// Modify changes the events fd is registered for. It fails with ENOENT if
+// fd is not registered.
+func (p *Poller) Modify(fd int, events uint32) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fd < 0 {
+		return EBADF
+	}
+	old, ok := p.registered[fd]
+	if !ok {
+		return ENOENT
+	}
+	if err := p.modify(fd, old, events); err != nil {
+		return err
+	}
+	p.registered[fd] = events
+	return nil
+}
+
+// Remove unregisters fd. File descriptors are unregistered from the kernel
+// when they are closed, but Remove must still be called for p to forget
+// them.
+func (p *Poller) Remove(fd int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fd < 0 {
+		return EBADF
+	}
+	old, ok := p.registered[fd]
+	if !ok {
+		return ENOENT
+	}
+	delete(p.registered, fd)
+	err := p.remove(fd, old)
+	if err == EBADF || err == ENOENT {
+		// Already closed, and so unregistered.
+		return nil
+	}
+	return err
+}
+
+// Registered returns the events fd is registered for, and whether it is.
+func (p *Poller) Registered(fd int) (events uint32, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	events, ok = p.registered[fd]
+	return events, ok
+}
+
+// Wait waits up to msec milliseconds, or without limit if msec is
+// negative, for events on the registered file descriptors, and returns
+// them. The same file descriptor may be reported by more than one event.
+// The returned slice is only valid until the next call of Wait.
+//
+// Wait returns no events and no error if it times out, is interrupted by a
+// signal or is woken by Wake. When the buffers fill up, they grow for the
+// next call.
+func (p *Poller) Wait(msec int) ([]PollerEvent, error) {
+	if p.fd < 0 {
+		// Not under p.mu: Close is not called concurrently.
+		return nil, EBADF
+	}
+	p.out = p.out[:0]
+	full, err := p.wait(msec)
+	if err == EINTR {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	events := p.out
+	if full {
+		p.sys.grow()
+		p.out = make([]PollerEvent, 0, 2*cap(p.out))
+	}
+	return events, nil
+}
+
+// Wake makes the Wait blocked in another goroutine return, or the next
+// call of Wait if none is blocked, so that the goroutine polling can be
+// handed work without a file descriptor of its own. Wakes before the
+// return of Wait are coalesced into one.
+func (p *Poller) Wake() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fd < 0 {
+		return EBADF
+	}
+	return p.wake()
+}
+
+// Close closes the epoll or kqueue file descriptor of p. The registered
+// file descriptors are left open.
+func (p *Poller) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fd < 0 {
+		return EBADF
+	}
+	clear(p.registered)
+	p.closeWake()
+	err := Close(p.fd)
+	// The numbers may be reused by files opened from now on.
+	p.fd = -1
+	return err
+}
+
+// defaultPollerEvents is the initial size of the event buffers of a
+// Poller.
+const defaultPollerEvents = 128
@@ -0,0 +1,163 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package unix
+
+// pollerSys holds the kqueue events of a Poller, and what Wake needs.
+type pollerSys struct {
+	events  []Kevent_t
+	timeout Timespec // of Wait, kept here so that Wait does not allocate
+	pollerWake
+}
+
+func (s *pollerSys) grow() {
+	s.events = make([]Kevent_t, 2*len(s.events))
+}
+
+// NewPoller returns a Poller with a new close-on-exec kqueue file
+// descriptor.
+func NewPoller() (*Poller, error) {
+	kq, err := Kqueue()
+	if err != nil {
+		return nil, err
+	}
+	CloseOnExec(kq)
+	p := &Poller{
+		fd:         kq,
+		sys:        pollerSys{events: make([]Kevent_t, defaultPollerEvents)},
+		out:        make([]PollerEvent, 0, defaultPollerEvents),
+		registered: make(map[int]uint32),
+	}
+	if err := p.initWake(); err != nil {
+		Close(kq)
+		return nil, err
+	}
+	return p, nil
+}
+
+// kevent applies the change of flags to the filter of ident.
+func (p *Poller) kevent(ident, filter, flags int, data int64) error {
+	var change [1]Kevent_t
+	SetKevent(&change[0], ident, filter, flags)
+	change[0].Data = data
+	_, err := Kevent(p.fd, change[:], nil, nil)
+	return err
+}
+
+// keventFlags returns the kevent flags adding the filters of the Poller
+// events.
+func keventFlags(events uint32) int {
+	flags := EV_ADD | EV_ENABLE
+	if events&PollerEdge != 0 {
+		flags |= EV_CLEAR
+	}
+	if events&PollerOneShot != 0 {
+		flags |= EV_ONESHOT
+	}
+	return flags
+}
+
+func (p *Poller) add(fd int, events uint32) error {
+	return p.modify(fd, 0, events)
+}
+
+// modify deletes the filters of old not in events, and adds or changes
+// those of events. kqueue has a filter per direction, where epoll has one
+// registration per file descriptor.
+func (p *Poller) modify(fd int, old, events uint32) error {
+	for _, f := range [...]struct {
+		event  uint32
+		filter int
+	}{{PollerRead, EVFILT_READ}, {PollerWrite, EVFILT_WRITE}} {
+		var err error
+		switch {
+		case events&f.event != 0:
+			err = p.kevent(fd, f.filter, keventFlags(events), 0)
+		case old&f.event != 0:
+			// A one-shot filter is deleted once reported.
+			if err = p.kevent(fd, f.filter, EV_DELETE, 0); err == ENOENT {
+				err = nil
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Poller) remove(fd int, old uint32) error {
+	return p.modify(fd, old, 0)
+}
+
+// AddTimer registers a timer reported by Wait every msec milliseconds as a
+// PollerTimer event with Fd id, or once if oneShot. Timer ids are distinct
+// from file descriptors. Adding a timer again changes it.
+//
+// AddTimer is only available on the BSDs. A FrameTicker, or on Linux a
+// timer file descriptor made with TimerfdCreate, serves the same purpose.
+func (p *Poller) AddTimer(id, msec int, oneShot bool) error {
+	flags := EV_ADD | EV_ENABLE
+	if oneShot {
+		flags |= EV_ONESHOT
+	}
+	return p.kevent(id, EVFILT_TIMER, flags, int64(msec))
+}
+
+// RemoveTimer unregisters the timer id.
+func (p *Poller) RemoveTimer(id int) error {
+	return p.kevent(id, EVFILT_TIMER, EV_DELETE, 0)
+}
+
+// AddSignal registers sig, reported by Wait as a PollerSignal event with
+// Fd sig each time it is delivered to the process. The signal is also
+// delivered as usual: to be only reported by Wait, it must be ignored, as
+// with signal.Ignore, or handled, as with signal.Notify.
+//
+// AddSignal is only available on the BSDs.
+func (p *Poller) AddSignal(sig Signal) error {
+	return p.kevent(int(sig), EVFILT_SIGNAL, EV_ADD|EV_ENABLE, 0)
+}
+
+// RemoveSignal unregisters sig.
+func (p *Poller) RemoveSignal(sig Signal) error {
+	return p.kevent(int(sig), EVFILT_SIGNAL, EV_DELETE, 0)
+}
+
+// wait appends the events of Kevent to p.out, and reports whether they
+// filled the buffer.
+func (p *Poller) wait(msec int) (full bool, err error) {
+	timeout := &p.sys.timeout
+	if msec < 0 {
+		timeout = nil
+	} else {
+		*timeout = NsecToTimespec(int64(msec) * 1e6)
+	}
+	n, err := Kevent(p.fd, nil, p.sys.events, timeout)
+	if err != nil {
+		return false, err
+	}
+	for _, ev := range p.sys.events[:n] {
+		if p.woken(&ev) {
+			continue
+		}
+		e := PollerEvent{Fd: int(ev.Ident)}
+		switch int(ev.Filter) {
+		case EVFILT_READ:
+			e.Events = PollerRead
+		case EVFILT_WRITE:
+			e.Events = PollerWrite
+		case EVFILT_TIMER:
+			e.Events, e.Data = PollerTimer, int64(ev.Data)
+		case EVFILT_SIGNAL:
+			e.Events, e.Data = PollerSignal, int64(ev.Data)
+		}
+		if ev.Flags&EV_EOF != 0 {
+			e.Events |= PollerHangup
+		}
+		if ev.Flags&EV_ERROR != 0 {
+			e.Events |= PollerError
+		}
+		p.out = append(p.out, e)
+	}
+	return n == len(p.sys.events), nil
+}
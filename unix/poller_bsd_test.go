@@ -0,0 +1,49 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package unix_test
+
+import (
+	"os/signal"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestPollerTimer(t *testing.T) {
+	p, err := unix.NewPoller()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if err := p.AddTimer(7, 10, false); err != nil {
+		t.Fatal(err)
+	}
+	events, err := p.Wait(5000)
+	if err != nil || len(events) != 1 || events[0].Fd != 7 || events[0].Events != unix.PollerTimer || events[0].Data < 1 {
+		t.Fatalf("Wait = %+v, %v; want an expiration of timer 7", events, err)
+	}
+	if err := p.RemoveTimer(7); err != nil {
+		t.Fatal(err)
+	}
+	if events, _ := p.Wait(50); len(events) != 0 {
+		t.Errorf("Wait after RemoveTimer = %+v", events)
+	}
+}
+
+func TestPollerSignal(t *testing.T) {
+	p, err := unix.NewPoller()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	signal.Ignore(unix.SIGUSR1)
+	defer signal.Reset(unix.SIGUSR1)
+	if err := p.AddSignal(unix.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+	unix.Kill(unix.Getpid(), unix.SIGUSR1)
+	events, err := p.Wait(5000)
+	if err != nil || len(events) != 1 || events[0].Fd != int(unix.SIGUSR1) || events[0].Events != unix.PollerSignal {
+		t.Fatalf("Wait = %+v, %v; want a delivery of SIGUSR1", events, err)
+	}
+}
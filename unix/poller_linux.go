@@ -0,0 +1,118 @@
+//go:build linux
+
+package unix
+
+import "unsafe"
+
+// pollerSys holds the epoll events of a Poller, and the eventfd of Wake.
+type pollerSys struct {
+	events []EpollEvent
+	wakefd int
+}
+
+func (s *pollerSys) grow() {
+	s.events = make([]EpollEvent, 2*len(s.events))
+}
+
+// NewPoller returns a Poller with a new close-on-exec epoll file
+// descriptor, and an eventfd registered with it for Wake.
+func NewPoller() (*Poller, error) {
+	epfd, err := EpollCreate1(EPOLL_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	wakefd, err := Eventfd(0, EFD_CLOEXEC|EFD_NONBLOCK)
+	if err != nil {
+		Close(epfd)
+		return nil, err
+	}
+	if err := EpollCtl(epfd, EPOLL_CTL_ADD, wakefd, &EpollEvent{Events: EPOLLIN, Fd: int32(wakefd)}); err != nil {
+		Close(wakefd)
+		Close(epfd)
+		return nil, err
+	}
+	return &Poller{
+		fd:         epfd,
+		sys:        pollerSys{events: make([]EpollEvent, defaultPollerEvents), wakefd: wakefd},
+		out:        make([]PollerEvent, 0, defaultPollerEvents),
+		registered: make(map[int]uint32),
+	}, nil
+}
+
+func (p *Poller) wake() error {
+	one := uint64(1)
+	_, err := Write(p.sys.wakefd, (*[8]byte)(unsafe.Pointer(&one))[:])
+	if err == EAGAIN {
+		// The counter is full, so Wait is woken already.
+		return nil
+	}
+	return err
+}
+
+func (p *Poller) closeWake() {
+	Close(p.sys.wakefd)
+	p.sys.wakefd = -1
+}
+
+// epollEvents returns the epoll flags of the Poller events.
+func epollEvents(events uint32) uint32 {
+	var ev uint32
+	if events&PollerRead != 0 {
+		ev |= EPOLLIN | EPOLLRDHUP
+	}
+	if events&PollerWrite != 0 {
+		ev |= EPOLLOUT
+	}
+	if events&PollerEdge != 0 {
+		ev |= EPOLLET
+	}
+	if events&PollerOneShot != 0 {
+		ev |= EPOLLONESHOT
+	}
+	return ev
+}
+
+func (p *Poller) add(fd int, events uint32) error {
+	ev := EpollEvent{Events: epollEvents(events), Fd: int32(fd)}
+	return EpollCtl(p.fd, EPOLL_CTL_ADD, fd, &ev)
+}
+
+func (p *Poller) modify(fd int, old, events uint32) error {
+	ev := EpollEvent{Events: epollEvents(events), Fd: int32(fd)}
+	return EpollCtl(p.fd, EPOLL_CTL_MOD, fd, &ev)
+}
+
+func (p *Poller) remove(fd int, old uint32) error {
+	return EpollCtl(p.fd, EPOLL_CTL_DEL, fd, nil)
+}
+
+// wait appends the events of EpollWait to p.out, and reports whether they
+// filled the buffer.
+func (p *Poller) wait(msec int) (full bool, err error) {
+	n, err := EpollWait(p.fd, p.sys.events, msec)
+	if err != nil {
+		return false, err
+	}
+	for _, ev := range p.sys.events[:n] {
+		if int(ev.Fd) == p.sys.wakefd {
+			var count [8]byte
+			Read(p.sys.wakefd, count[:])
+			continue
+		}
+		var events uint32
+		if ev.Events&EPOLLIN != 0 {
+			events |= PollerRead
+		}
+		if ev.Events&EPOLLOUT != 0 {
+			events |= PollerWrite
+		}
+		if ev.Events&EPOLLERR != 0 {
+			events |= PollerError
+		}
+		if ev.Events&(EPOLLHUP|EPOLLRDHUP) != 0 {
+			events |= PollerHangup
+		}
+		p.out = append(p.out, PollerEvent{Fd: int(ev.Fd), Events: events})
+	}
+	return n == len(p.sys.events), nil
+}
@@ -0,0 +1,189 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package unix_test
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func newPipe(t *testing.T) (r, w int) {
+	var fds [2]int
+	if err := unix.Pipe(fds[:]); err != nil {
+		t.Fatal(err)
+	}
+	unix.SetNonblock(fds[0], true)
+	t.Cleanup(func() {
+		unix.Close(fds[0])
+		unix.Close(fds[1])
+	})
+	return fds[0], fds[1]
+}
+
+func TestPoller(t *testing.T) {
+	p, err := unix.NewPoller()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	r, w := newPipe(t)
+
+	if err := p.Add(r, unix.PollerRead); err != nil {
+		t.Fatal(err)
+	}
+	if events, ok := p.Registered(r); !ok || events != unix.PollerRead {
+		t.Errorf("Registered = %#x, %v; want PollerRead, true", events, ok)
+	}
+	if err := p.Add(r, unix.PollerRead); err != unix.EEXIST {
+		t.Errorf("second Add: got %v, want EEXIST", err)
+	}
+	if events, err := p.Wait(0); len(events) != 0 || err != nil {
+		t.Fatalf("Wait on an empty pipe = %v, %v", events, err)
+	}
+
+	unix.Write(w, []byte("x"))
+	events, err := p.Wait(-1)
+	if err != nil || len(events) != 1 || events[0].Fd != r || events[0].Events&unix.PollerRead == 0 {
+		t.Fatalf("Wait = %+v, %v; want PollerRead on %d", events, err, r)
+	}
+	// Level-triggered: reported until read.
+	if events, _ := p.Wait(0); len(events) != 1 {
+		t.Errorf("level-triggered Wait before reading = %+v, want the event again", events)
+	}
+
+	if err := p.Modify(r, unix.PollerWrite); err != nil {
+		t.Fatal(err)
+	}
+	if events, _ := p.Wait(0); len(events) != 0 {
+		t.Errorf("Wait after Modify to PollerWrite = %+v, want none for a read end", events)
+	}
+	if err := p.Remove(r); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Modify(r, unix.PollerRead); err != unix.ENOENT {
+		t.Errorf("Modify after Remove: got %v, want ENOENT", err)
+	}
+	if _, ok := p.Registered(r); ok {
+		t.Error("Registered after Remove")
+	}
+	if events, _ := p.Wait(0); len(events) != 0 {
+		t.Errorf("Wait after Remove = %+v", events)
+	}
+}
+
+func TestPollerEdgeTriggered(t *testing.T) {
+	p, err := unix.NewPoller()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	r, w := newPipe(t)
+	if err := p.Add(r, unix.PollerRead|unix.PollerEdge); err != nil {
+		t.Fatal(err)
+	}
+
+	unix.Write(w, []byte("x"))
+	if events, _ := p.Wait(-1); len(events) != 1 {
+		t.Fatalf("Wait = %+v, want one event", events)
+	}
+	if events, _ := p.Wait(0); len(events) != 0 {
+		t.Errorf("edge-triggered Wait without new data = %+v, want none", events)
+	}
+	unix.Write(w, []byte("y"))
+	if events, _ := p.Wait(-1); len(events) != 1 {
+		t.Errorf("Wait after new data = %+v, want one event", events)
+	}
+}
+
+func TestPollerWaitAllocs(t *testing.T) {
+	p, err := unix.NewPoller()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	r, w := newPipe(t)
+	p.Add(r, unix.PollerRead)
+	unix.Write(w, []byte("x"))
+	allocs := testing.AllocsPerRun(100, func() {
+		if events, err := p.Wait(0); len(events) != 1 || err != nil {
+			t.Fatalf("Wait = %+v, %v", events, err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Wait allocates %v times, want 0", allocs)
+	}
+}
+
+func TestPollerHangup(t *testing.T) {
+	p, err := unix.NewPoller()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	r, w := newPipe(t)
+	p.Add(r, unix.PollerRead)
+	unix.Close(w)
+	events, err := p.Wait(-1)
+	if err != nil || len(events) != 1 || events[0].Events&unix.PollerHangup == 0 {
+		t.Errorf("Wait after closing the write end = %+v, %v; want PollerHangup", events, err)
+	}
+}
+
+func TestPollerWake(t *testing.T) {
+	p, err := unix.NewPoller()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		p.Wake()
+	}()
+	if events, err := p.Wait(-1); err != nil || len(events) != 0 {
+		t.Errorf("Wait woken = %+v, %v; want no events", events, err)
+	}
+
+	// Wakes before Wait are coalesced into one.
+	p.Wake()
+	p.Wake()
+	if events, err := p.Wait(-1); err != nil || len(events) != 0 {
+		t.Errorf("Wait after Wake = %+v, %v; want no events", events, err)
+	}
+	start := time.Now()
+	if events, err := p.Wait(20); err != nil || len(events) != 0 {
+		t.Errorf("second Wait = %+v, %v; want no events", events, err)
+	}
+	if d := time.Since(start); d < 15*time.Millisecond {
+		t.Errorf("second Wait returned after %v, want a timeout of 20ms", d)
+	}
+}
+
+func TestPollerClosed(t *testing.T) {
+	p, err := unix.NewPoller()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, _ := newPipe(t)
+	p.Add(r, unix.PollerRead)
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if fd := p.Fd(); fd != -1 {
+		t.Errorf("Fd after Close = %d, want -1", fd)
+	}
+	for name, f := range map[string]func() error{
+		"Wake":   p.Wake,
+		"Wait":   func() error { _, err := p.Wait(0); return err },
+		"Add":    func() error { return p.Add(r, unix.PollerRead) },
+		"Modify": func() error { return p.Modify(r, unix.PollerWrite) },
+		"Remove": func() error { return p.Remove(r) },
+		"Close":  p.Close,
+	} {
+		if err := f(); err != unix.EBADF {
+			t.Errorf("%s after Close: got %v, want EBADF", name, err)
+		}
+	}
+}
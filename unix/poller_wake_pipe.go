@@ -0,0 +1,50 @@
+//go:build netbsd || openbsd
+
+package unix
+
+// pollerWake holds the pipe written to by Wake, as EVFILT_USER is missing.
+type pollerWake struct {
+	wakeR, wakeW int
+}
+
+func (p *Poller) initWake() error {
+	var fds [2]int
+	if err := Pipe2(fds[:], O_NONBLOCK|O_CLOEXEC); err != nil {
+		return err
+	}
+	if err := p.kevent(fds[0], EVFILT_READ, EV_ADD|EV_ENABLE, 0); err != nil {
+		Close(fds[0])
+		Close(fds[1])
+		return err
+	}
+	p.sys.wakeR, p.sys.wakeW = fds[0], fds[1]
+	return nil
+}
+
+func (p *Poller) wake() error {
+	_, err := Write(p.sys.wakeW, []byte{0})
+	if err == EAGAIN {
+		// The pipe is full, so Wait is woken already.
+		return nil
+	}
+	return err
+}
+
+// woken reports whether ev is the event of Wake, and drains the pipe if so.
+func (p *Poller) woken(ev *Kevent_t) bool {
+	if int(ev.Filter) != EVFILT_READ || int(ev.Ident) != p.sys.wakeR {
+		return false
+	}
+	var buf [64]byte
+	for {
+		if n, _ := Read(p.sys.wakeR, buf[:]); n <= 0 {
+			return true
+		}
+	}
+}
+
+func (p *Poller) closeWake() {
+	Close(p.sys.wakeR)
+	Close(p.sys.wakeW)
+	p.sys.wakeR, p.sys.wakeW = -1, -1
+}
@@ -0,0 +1,28 @@
+//go:build darwin || dragonfly || freebsd
+
+package unix
+
+// pollerWake is empty: Wake triggers an EVFILT_USER event of the kqueue.
+type pollerWake struct{}
+
+// wakeIdent is the ident of the EVFILT_USER event of Wake.
+const wakeIdent = 0
+
+func (p *Poller) initWake() error {
+	return p.kevent(wakeIdent, EVFILT_USER, EV_ADD|EV_CLEAR, 0)
+}
+
+func (p *Poller) wake() error {
+	var change [1]Kevent_t
+	SetKevent(&change[0], wakeIdent, EVFILT_USER, 0)
+	change[0].Fflags = NOTE_TRIGGER
+	_, err := Kevent(p.fd, change[:], nil, nil)
+	return err
+}
+
+// woken reports whether ev is the event of Wake.
+func (p *Poller) woken(ev *Kevent_t) bool {
+	return int(ev.Filter) == EVFILT_USER && ev.Ident == wakeIdent
+}
+
+func (p *Poller) closeWake() {}
@@ -0,0 +1,52 @@
+package unix
+
+import "unsafe"
+
+// SignalfdBlock blocks sigs on the calling thread and returns a signalfd
+// reporting them, created with flags, a combination of SFD_CLOEXEC and
+// SFD_NONBLOCK. The signalfd becomes readable when one of sigs is pending,
+// so it can be registered with a Poller and the signals handled in the same
+// loop as other events, with ReadSignalfd.
+//
+// A signal is only queued for the signalfd while it is blocked, and the Go
+// runtime starts its threads with signals unblocked: a signal directed at
+// the process, rather than at the thread, may be delivered to another
+// thread and handled by the Go runtime, as usual, instead. SignalfdBlock is
+// thus for goroutines locked to their thread with runtime.LockOSThread and
+// for the signals sent to it, as with Tgkill, or for processes whose other
+// threads block the signals too. The signals stay blocked when the
+// signalfd is closed; unblock them with PthreadSigmask.
+func SignalfdBlock(flags int, sigs ...Signal) (fd int, err error) {
+	var set, old Sigset_t
+	for _, sig := range sigs {
+		sigsetAdd(&set, sig)
+	}
+	if err := PthreadSigmask(SIG_BLOCK, &set, &old); err != nil {
+		return -1, err
+	}
+	fd, err = Signalfd(-1, &set, flags)
+	if err != nil {
+		PthreadSigmask(SIG_SETMASK, &old, nil)
+		return -1, err
+	}
+	return fd, nil
+}
+
+// ReadSignalfd reads the signals pending on the signalfd fd into infos,
+// and returns how many were read. infos must not be empty. Without
+// SFD_NONBLOCK, ReadSignalfd waits for a signal if none is pending.
+func ReadSignalfd(fd int, infos []SignalfdSiginfo) (n int, err error) {
+	size := int(unsafe.Sizeof(SignalfdSiginfo{}))
+	b := unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(infos))), len(infos)*size)
+	n, err = Read(fd, b)
+	if err != nil {
+		return 0, err
+	}
+	return n / size, nil
+}
+
+// sigsetAdd adds sig to set.
+func sigsetAdd(set *Sigset_t, sig Signal) {
+	bits := uint(unsafe.Sizeof(set.Val[0])) * 8
+	set.Val[uint(sig-1)/bits] |= 1 << (uint(sig-1) % bits)
+}
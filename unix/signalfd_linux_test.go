@@ -0,0 +1,50 @@
+package unix_test
+
+import (
+	"runtime"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestSignalfdBlock(t *testing.T) {
+	// The signal stays blocked on the thread, which the Go runtime
+	// discards when the test returns without unlocking it.
+	runtime.LockOSThread()
+
+	fd, err := unix.SignalfdBlock(unix.SFD_CLOEXEC|unix.SFD_NONBLOCK, unix.SIGUSR1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(fd)
+	p, err := unix.NewPoller()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if err := p.Add(fd, unix.PollerRead); err != nil {
+		t.Fatal(err)
+	}
+
+	infos := make([]unix.SignalfdSiginfo, 4)
+	if _, err := unix.ReadSignalfd(fd, infos); err != unix.EAGAIN {
+		t.Fatalf("ReadSignalfd with no signal pending: got %v, want EAGAIN", err)
+	}
+	if err := unix.Tgkill(unix.Getpid(), unix.Gettid(), unix.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+	events, err := p.Wait(1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Fd != fd || events[0].Events&unix.PollerRead == 0 {
+		t.Fatalf("Wait = %+v, want fd %d readable", events, fd)
+	}
+	n, err := unix.ReadSignalfd(fd, infos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || unix.Signal(infos[0].Signo) != unix.SIGUSR1 || int(infos[0].Pid) != unix.Getpid() {
+		t.Errorf("ReadSignalfd = %d, %+v; want SIGUSR1 from pid %d", n, infos[0], unix.Getpid())
+	}
+}
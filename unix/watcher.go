@@ -0,0 +1,64 @@
+//go:build darwin || linux
+
+package unix
+
+import "strings"
+
+// A WatchEvent is a change reported by a Watcher.
+type WatchEvent struct {
+	// Path is the path of the file or directory changed: the path of the
+	// watch, joined with the name of the file for the events on the files
+	// of a watched directory.
+	Path string
+
+	// Mask holds the Watch* flags of the event, which are IN_* flags on
+	// Linux. A rename within the watched directories is reported as a
+	// single event with both WatchMovedFrom and WatchMovedTo.
+	Mask uint32
+
+	// OldPath is the path of a renamed file before the rename, for the
+	// events with both WatchMovedFrom and WatchMovedTo.
+	OldPath string
+}
+
+// A dirEntry is an entry of a directory read by readDir.
+type dirEntry struct {
+	name string
+	ino  uint64
+	typ  uint32 // S_IFMT bits of the mode
+}
+
+// readDir returns the entries of the directory name, relative to dirfd,
+// without following symbolic links.
+func readDir(dirfd int, name string) ([]dirEntry, error) {
+	fd, err := Openat(dirfd, name, O_RDONLY|O_DIRECTORY|O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer Close(fd)
+	var names []string
+	buf := make([]byte, 4096)
+	for {
+		n, err := ReadDirent(fd, buf)
+		if err != nil {
+			return nil, err
+		}
+		if n <= 0 {
+			break
+		}
+		_, _, names = ParseDirent(buf[:n], -1, names)
+	}
+	entries := make([]dirEntry, 0, len(names))
+	for _, name := range names {
+		var st Stat_t
+		if Fstatat(fd, name, &st, AT_SYMLINK_NOFOLLOW) == nil {
+			entries = append(entries, dirEntry{name: name, ino: uint64(st.Ino), typ: uint32(st.Mode) & S_IFMT})
+		}
+	}
+	return entries, nil
+}
+
+// inTree reports whether p is below the directory dir.
+func inTree(p, dir string) bool {
+	return strings.HasPrefix(p, dir) && len(p) > len(dir) && (p[len(dir)] == '/' || dir == "/")
+}
@@ -0,0 +1,396 @@
+package unix
+
+import (
+	"maps"
+	"path"
+	"slices"
+)
+
+// A Watcher reports changes to files and directories with kqueue
+// EVFILT_VNODE events, with the API of the inotify Watcher of Linux. Each
+// watched file and directory is kept open, with O_EVTONLY, and so are the
+// files of the watched directories when WatchModify or WatchAttrib are
+// asked for, as kqueue only reports changes to open files. The changes to
+// directories are found by reading them again when they are written to, and
+// renames are paired by inode.
+//
+// The kqueue file descriptor can be registered with a Poller, as it is
+// readable when events are pending.
+//
+// The methods of a Watcher must not be called concurrently.
+type Watcher struct {
+	kq      int
+	kevents []Kevent_t
+	events  []WatchEvent
+	watches map[int]*watch // by file descriptor
+	fds     map[string]int // file descriptors by path
+
+	// The entries removed from and added to the directories read again by
+	// Read.
+	removed, added []watchChange
+}
+
+// A watch is a file or directory watched by a Watcher.
+type watch struct {
+	path      string
+	mask      uint32 // events asked for
+	recursive bool   // added with AddRecursive, or for one of its subdirectories
+	sub       bool   // added for a subdirectory of a recursive watch
+	child     bool   // added for a file of a watched directory
+	dir       bool
+	entries   map[string]dirEntry // of a directory, by name
+}
+
+// A watchChange is an entry removed from or added to a watched directory.
+type watchChange struct {
+	path      string
+	entry     dirEntry
+	mask      uint32 // of the watch of the directory
+	recursive bool   // of the watch of the directory
+	paired    bool   // with the other half of a rename
+}
+
+// The events of a Watcher, with the same names on Linux and Darwin. Files
+// moved into or out of the watched directories are reported as created or
+// deleted on Darwin, and there are no overflows.
+const (
+	WatchCreate     = 1 << iota // file created in a watched directory
+	WatchDelete                 // file deleted from a watched directory
+	WatchModify                 // file written
+	WatchAttrib                 // metadata changed
+	WatchMovedFrom              // file renamed from a watched directory
+	WatchMovedTo                // file renamed to a watched directory
+	WatchDeleteSelf             // watched file deleted
+	WatchMoveSelf               // watched file renamed
+	WatchIsDir                  // event about a directory
+	WatchIgnored                // watch removed as its file was deleted
+	WatchOverflow               // events lost
+)
+
+// vnodeNotes holds the EVFILT_VNODE notes of the files of a Watcher.
+const vnodeNotes = NOTE_DELETE | NOTE_WRITE | NOTE_EXTEND | NOTE_ATTRIB | NOTE_LINK | NOTE_RENAME | NOTE_REVOKE
+
+// NewWatcher returns a Watcher with a new close-on-exec kqueue file
+// descriptor.
+func NewWatcher() (*Watcher, error) {
+	kq, err := Kqueue()
+	if err != nil {
+		return nil, err
+	}
+	CloseOnExec(kq)
+	return &Watcher{
+		kq:      kq,
+		kevents: make([]Kevent_t, 64),
+		watches: make(map[int]*watch),
+		fds:     make(map[string]int),
+	}, nil
+}
+
+// Fd returns the kqueue file descriptor of w.
+func (w *Watcher) Fd() int {
+	return w.kq
+}
+
+// Add watches the file or directory at p for the events of mask, such as
+// WatchModify or WatchCreate|WatchDelete. Adding a path again replaces its
+// mask.
+func (w *Watcher) Add(p string, mask uint32) error {
+	return w.add(path.Clean(p), mask, false, false, false)
+}
+
+// AddRecursive watches the directory dir and its subdirectories for the
+// events of mask. Subdirectories created or moved into dir later are
+// watched once their event is read, so the changes made in them before
+// that are not reported. Symbolic links are not followed.
+func (w *Watcher) AddRecursive(dir string, mask uint32) error {
+	return w.add(path.Clean(dir), mask, true, false, false)
+}
+
+func (w *Watcher) add(p string, mask uint32, recursive, sub, child bool) error {
+	if fd, ok := w.fds[p]; ok {
+		wt := w.watches[fd]
+		if recursive && !wt.dir {
+			return ENOTDIR
+		}
+		if child || sub && !wt.sub && !wt.child {
+			// Watched already, or added with Add or AddRecursive.
+			return nil
+		}
+		wt.mask, wt.recursive, wt.sub, wt.child = mask, recursive, sub, false
+		w.watchEntries(wt)
+		return nil
+	}
+	flags := O_EVTONLY | O_CLOEXEC
+	if recursive || child {
+		flags |= O_SYMLINK
+	}
+	fd, err := Open(p, flags, 0)
+	if err != nil {
+		return err
+	}
+	var st Stat_t
+	if err := Fstat(fd, &st); err != nil {
+		Close(fd)
+		return err
+	}
+	dir := st.Mode&S_IFMT == S_IFDIR
+	if recursive && !dir {
+		Close(fd)
+		return ENOTDIR
+	}
+	var change [1]Kevent_t
+	SetKevent(&change[0], fd, EVFILT_VNODE, EV_ADD|EV_ENABLE|EV_CLEAR)
+	change[0].Fflags = vnodeNotes
+	if _, err := Kevent(w.kq, change[:], nil, nil); err != nil {
+		Close(fd)
+		return err
+	}
+	wt := &watch{path: p, mask: mask, recursive: recursive, sub: sub, child: child, dir: dir}
+	w.watches[fd] = wt
+	w.fds[p] = fd
+	if dir {
+		entries, err := readDir(fd, ".")
+		if err != nil {
+			w.forget(fd)
+			return err
+		}
+		wt.entries = make(map[string]dirEntry, len(entries))
+		for _, e := range entries {
+			wt.entries[e.name] = e
+		}
+		w.watchEntries(wt)
+	}
+	return nil
+}
+
+// watchEntries watches the entries of the directory of wt that need to be:
+// its subdirectories if wt is recursive, and its files if the events of wt
+// include changes to them.
+func (w *Watcher) watchEntries(wt *watch) {
+	for _, e := range wt.entries {
+		w.watchEntry(path.Join(wt.path, e.name), e, wt.mask, wt.recursive)
+	}
+}
+
+// watchEntry watches the entry e at p of a directory watched for mask,
+// if needed. Entries removed meanwhile are skipped.
+func (w *Watcher) watchEntry(p string, e dirEntry, mask uint32, recursive bool) {
+	switch {
+	case e.typ == S_IFDIR && recursive:
+		w.add(p, mask, true, true, false)
+	case e.typ == S_IFREG && mask&(WatchModify|WatchAttrib) != 0:
+		w.add(p, mask, false, false, true)
+	}
+}
+
+// Remove stops watching p and, if it is a directory, the files of p watched
+// for it and, if it was added with AddRecursive, its subdirectories. It
+// fails with ENOENT if p is not watched.
+func (w *Watcher) Remove(p string) error {
+	p = path.Clean(p)
+	if _, ok := w.fds[p]; !ok {
+		return ENOENT
+	}
+	w.removeTree(p)
+	return nil
+}
+
+// removeTree removes the watch of p and those of the files and
+// subdirectories below p added for the watches of directories.
+func (w *Watcher) removeTree(p string) {
+	for fd, wt := range w.watches {
+		if wt.path == p || (wt.sub || wt.child) && inTree(wt.path, p) {
+			w.forget(fd)
+		}
+	}
+}
+
+// renameTree changes the paths of the watches of dir and of the files
+// below it after dir is renamed to newDir.
+func (w *Watcher) renameTree(dir, newDir string) {
+	for fd, wt := range w.watches {
+		if wt.path == dir || inTree(wt.path, dir) {
+			if w.fds[wt.path] == fd {
+				delete(w.fds, wt.path)
+			}
+			wt.path = newDir + wt.path[len(dir):]
+			w.fds[wt.path] = fd
+		}
+	}
+}
+
+// forget closes the file descriptor of the watch fd, which removes its
+// events from the kqueue.
+func (w *Watcher) forget(fd int) {
+	if wt := w.watches[fd]; wt != nil && w.fds[wt.path] == fd {
+		delete(w.fds, wt.path)
+	}
+	delete(w.watches, fd)
+	Close(fd)
+}
+
+// Read reads the pending events of w, and fails with EAGAIN if there are
+// none. It may return no events, when only those w reads for its watched
+// directories were pending. The returned slice is only valid until the next
+// call of Read.
+//
+// The two halves of a rename are paired when they are read together: a
+// rename out of the watched directories is reported with WatchDelete, and
+// one into them with WatchCreate. WatchIgnored is reported after
+// WatchDeleteSelf for the watches added with Add or AddRecursive, which are
+// then removed.
+func (w *Watcher) Read() ([]WatchEvent, error) {
+	n, err := Kevent(w.kq, nil, w.kevents, &Timespec{})
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, EAGAIN
+	}
+	w.events, w.removed, w.added = w.events[:0], w.removed[:0], w.added[:0]
+	for _, ev := range w.kevents[:n] {
+		w.decode(int(ev.Ident), ev.Fflags)
+	}
+	w.pairRenames()
+	return w.events, nil
+}
+
+// emit appends an event to w.events if its watch asked for it.
+func (w *Watcher) emit(p string, mask, want uint32, oldPath string) {
+	if mask&want != 0 {
+		w.events = append(w.events, WatchEvent{Path: p, Mask: mask, OldPath: oldPath})
+	}
+}
+
+// decode handles the notes of the watch fd.
+func (w *Watcher) decode(fd int, notes uint32) {
+	wt := w.watches[fd]
+	if wt == nil {
+		// Removed by a previous event.
+		return
+	}
+	var isDir uint32
+	if wt.dir {
+		isDir = WatchIsDir
+	}
+	// The watches added for directories are reported by the events of the
+	// directories.
+	own := !wt.sub && !wt.child
+	switch {
+	case notes&NOTE_WRITE != 0 && wt.dir:
+		w.rescan(fd, wt)
+	case notes&(NOTE_WRITE|NOTE_EXTEND) != 0:
+		w.emit(wt.path, WatchModify, wt.mask, "")
+	}
+	// The link count of directories changes with their subdirectories.
+	if notes&NOTE_ATTRIB != 0 || notes&NOTE_LINK != 0 && !wt.dir {
+		w.emit(wt.path, WatchAttrib|isDir, wt.mask, "")
+	}
+	if notes&NOTE_RENAME != 0 && own {
+		w.emit(wt.path, WatchMoveSelf|isDir, wt.mask, "")
+	}
+	if notes&(NOTE_DELETE|NOTE_REVOKE) != 0 {
+		if own {
+			w.emit(wt.path, WatchDeleteSelf|isDir, wt.mask, "")
+			w.emit(wt.path, WatchIgnored, WatchIgnored, "")
+		}
+		w.forget(fd)
+	}
+}
+
+// rescan reads the directory of the watch fd again, and records the
+// entries removed and added since it was last read.
+func (w *Watcher) rescan(fd int, wt *watch) {
+	list, err := readDir(fd, ".")
+	if err != nil {
+		// Removed: NOTE_DELETE follows.
+		return
+	}
+	entries := make(map[string]dirEntry, len(list))
+	for _, e := range list {
+		entries[e.name] = e
+	}
+	for _, name := range slices.Sorted(maps.Keys(wt.entries)) {
+		old := wt.entries[name]
+		if e, ok := entries[name]; ok && e.ino == old.ino {
+			continue
+		}
+		p := path.Join(wt.path, name)
+		if fd, ok := w.fds[p]; ok && w.watches[fd].child {
+			w.forget(fd)
+		}
+		w.removed = append(w.removed, watchChange{path: p, entry: old, mask: wt.mask, recursive: wt.recursive})
+	}
+	for _, name := range slices.Sorted(maps.Keys(entries)) {
+		e := entries[name]
+		if old, ok := wt.entries[name]; ok && e.ino == old.ino {
+			continue
+		}
+		w.added = append(w.added, watchChange{path: path.Join(wt.path, name), entry: e, mask: wt.mask, recursive: wt.recursive})
+	}
+	wt.entries = entries
+}
+
+// pairRenames reports the entries removed and added by Read, pairing those
+// of the same inode as renames, and updates the watches below them.
+func (w *Watcher) pairRenames() {
+	for i := range w.removed {
+		r := &w.removed[i]
+		for j := range w.added {
+			a := &w.added[j]
+			if a.paired || a.entry.ino != r.entry.ino {
+				continue
+			}
+			r.paired, a.paired = true, true
+			mask := uint32(WatchMovedFrom | WatchMovedTo)
+			if a.entry.typ == S_IFDIR {
+				mask |= WatchIsDir
+			}
+			w.renameTree(r.path, a.path)
+			w.emit(a.path, mask, r.mask|a.mask, r.path)
+			w.watchEntry(a.path, a.entry, a.mask, a.recursive)
+			break
+		}
+	}
+	for _, r := range w.removed {
+		if r.paired || w.renamedTo(r.path) {
+			continue
+		}
+		mask := uint32(WatchDelete)
+		if r.entry.typ == S_IFDIR {
+			mask |= WatchIsDir
+			w.removeTree(r.path)
+		}
+		w.emit(r.path, mask, r.mask, "")
+	}
+	for _, a := range w.added {
+		if a.paired {
+			continue
+		}
+		mask := uint32(WatchCreate)
+		if a.entry.typ == S_IFDIR {
+			mask |= WatchIsDir
+		}
+		w.watchEntry(a.path, a.entry, a.mask, a.recursive)
+		w.emit(a.path, mask, a.mask, "")
+	}
+}
+
+// renamedTo reports whether a file was renamed to p, replacing the file
+// removed from p.
+func (w *Watcher) renamedTo(p string) bool {
+	for _, a := range w.added {
+		if a.paired && a.path == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Close closes the kqueue file descriptor of w and the files it watches.
+func (w *Watcher) Close() error {
+	for fd := range w.watches {
+		w.forget(fd)
+	}
+	return Close(w.kq)
+}
@@ -0,0 +1,304 @@
+package unix
+
+import (
+	"path"
+	"strings"
+	"unsafe"
+)
+
+// A Watcher reports changes to files and directories with inotify. It
+// keeps the paths of its watches, so that events carry the path of the file
+// they are about, decodes the events read from the inotify file descriptor,
+// pairs the two halves of renames by their cookie and, for directories added
+// with AddRecursive, watches their subdirectories, including those created
+// or moved in later.
+//
+// The inotify file descriptor is non-blocking and can be registered with a
+// Poller, as it is readable when events are pending.
+//
+// The methods of a Watcher must not be called concurrently.
+type Watcher struct {
+	fd      int
+	buf     []byte
+	events  []WatchEvent
+	meta    []watchEventMeta // of events
+	watches map[int]*watch   // by watch descriptor
+	wds     map[string]int   // watch descriptors by path
+}
+
+// A watch is a file or directory watched by a Watcher.
+type watch struct {
+	path      string
+	mask      uint32 // events asked for
+	recursive bool   // added with AddRecursive, or for one of its subdirectories
+	sub       bool   // added for a subdirectory of a recursive watch
+}
+
+// A watchEventMeta holds what Read needs to know of a WatchEvent after
+// decoding it.
+type watchEventMeta struct {
+	want      uint32 // events asked for by the watch
+	cookie    uint32 // of IN_MOVED_FROM, until paired
+	recursive bool   // of the watch
+}
+
+// The events of a Watcher, with the same names on Linux and Darwin. They
+// are the IN_* flags of inotify, and the other IN_* flags may be used too.
+const (
+	WatchCreate     = IN_CREATE      // file created in a watched directory
+	WatchDelete     = IN_DELETE      // file deleted from a watched directory
+	WatchModify     = IN_MODIFY      // file written
+	WatchAttrib     = IN_ATTRIB      // metadata changed
+	WatchMovedFrom  = IN_MOVED_FROM  // file renamed from a watched directory
+	WatchMovedTo    = IN_MOVED_TO    // file renamed to a watched directory
+	WatchDeleteSelf = IN_DELETE_SELF // watched file deleted
+	WatchMoveSelf   = IN_MOVE_SELF   // watched file renamed
+	WatchIsDir      = IN_ISDIR       // event about a directory
+	WatchIgnored    = IN_IGNORED     // watch removed by the kernel
+	WatchOverflow   = IN_Q_OVERFLOW  // events lost
+)
+
+// recursiveMask holds the events a Watcher needs for the directories of
+// recursive watches, to follow their subdirectories.
+const recursiveMask = IN_CREATE | IN_MOVE | IN_ONLYDIR | IN_DONT_FOLLOW
+
+// NewWatcher returns a Watcher with a new close-on-exec, non-blocking
+// inotify file descriptor.
+func NewWatcher() (*Watcher, error) {
+	fd, err := InotifyInit1(IN_CLOEXEC | IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	return &Watcher{
+		fd:      fd,
+		buf:     make([]byte, 64*(SizeofInotifyEvent+NAME_MAX+1)),
+		watches: make(map[int]*watch),
+		wds:     make(map[string]int),
+	}, nil
+}
+
+// Fd returns the inotify file descriptor of w.
+func (w *Watcher) Fd() int {
+	return w.fd
+}
+
+// Add watches the file or directory at p for the events of mask, such as
+// WatchModify or WatchCreate|WatchDelete. Adding a path again replaces its
+// mask.
+func (w *Watcher) Add(p string, mask uint32) error {
+	return w.add(path.Clean(p), mask, false, false)
+}
+
+// AddRecursive watches the directory dir and its subdirectories for the
+// events of mask. Subdirectories created or moved into dir later are
+// watched once their event is read, so the changes made in them before
+// that are not reported. Symbolic links are not followed.
+func (w *Watcher) AddRecursive(dir string, mask uint32) error {
+	return w.addTree(path.Clean(dir), mask, false)
+}
+
+func (w *Watcher) add(p string, mask uint32, recursive, sub bool) error {
+	sysMask := mask
+	if recursive {
+		sysMask |= recursiveMask
+	}
+	wd, err := InotifyAddWatch(w.fd, p, sysMask)
+	if err != nil {
+		return err
+	}
+	if old := w.watches[wd]; old != nil && w.wds[old.path] == wd {
+		// The same file under another path, as after a rename.
+		delete(w.wds, old.path)
+	}
+	w.watches[wd] = &watch{path: p, mask: mask, recursive: recursive, sub: sub}
+	w.wds[p] = wd
+	return nil
+}
+
+// addTree adds a recursive watch for dir and its subdirectories.
+func (w *Watcher) addTree(dir string, mask uint32, sub bool) error {
+	if err := w.add(dir, mask, true, sub); err != nil {
+		return err
+	}
+	names, err := subdirs(dir)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		// Subdirectories removed meanwhile are skipped.
+		if err := w.addTree(path.Join(dir, name), mask, true); err != nil && err != ENOENT && err != ENOTDIR {
+			return err
+		}
+	}
+	return nil
+}
+
+// subdirs returns the names of the subdirectories of dir.
+func subdirs(dir string) ([]string, error) {
+	entries, err := readDir(AT_FDCWD, dir)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, e := range entries {
+		if e.typ == S_IFDIR {
+			dirs = append(dirs, e.name)
+		}
+	}
+	return dirs, nil
+}
+
+// Remove stops watching p and, if it was added with AddRecursive, its
+// subdirectories. It fails with ENOENT if p is not watched.
+func (w *Watcher) Remove(p string) error {
+	p = path.Clean(p)
+	wd, ok := w.wds[p]
+	if !ok {
+		return ENOENT
+	}
+	if w.watches[wd].recursive {
+		w.removeTree(p)
+		return nil
+	}
+	w.forget(wd)
+	_, err := InotifyRmWatch(w.fd, uint32(wd))
+	return err
+}
+
+// removeTree removes the watch of dir and those of the subdirectories of
+// dir added for recursive watches.
+func (w *Watcher) removeTree(dir string) {
+	for wd, wt := range w.watches {
+		if wt.path == dir || wt.sub && inTree(wt.path, dir) {
+			w.forget(wd)
+			InotifyRmWatch(w.fd, uint32(wd))
+		}
+	}
+}
+
+// renameTree changes the paths of the watches of dir and of the files
+// below it after dir is renamed to newDir.
+func (w *Watcher) renameTree(dir, newDir string) {
+	for wd, wt := range w.watches {
+		if wt.path == dir || inTree(wt.path, dir) {
+			if w.wds[wt.path] == wd {
+				delete(w.wds, wt.path)
+			}
+			wt.path = newDir + wt.path[len(dir):]
+			w.wds[wt.path] = wd
+		}
+	}
+}
+
+// forget forgets the watch wd, removed from the kernel.
+func (w *Watcher) forget(wd int) {
+	if wt := w.watches[wd]; wt != nil && w.wds[wt.path] == wd {
+		delete(w.wds, wt.path)
+	}
+	delete(w.watches, wd)
+}
+
+// Read reads the pending events of w, and fails with EAGAIN if there are
+// none. It may return no events, when only those w reads for its recursive
+// watches were pending. The returned slice is only valid until the next
+// call of Read.
+//
+// The two halves of a rename are paired when they are read together, as
+// the kernel queues them at once: a rename out of the watched directories
+// is reported with WatchMovedFrom alone, and one into them with
+// WatchMovedTo alone. WatchOverflow, with an empty Path, means that events
+// were lost. WatchIgnored is reported when the kernel removes a watch added
+// with Add or AddRecursive, as its file was deleted or its file system
+// unmounted.
+func (w *Watcher) Read() ([]WatchEvent, error) {
+	n, err := Read(w.fd, w.buf)
+	if err != nil {
+		return nil, err
+	}
+	w.events, w.meta = w.events[:0], w.meta[:0]
+	for off := 0; off+SizeofInotifyEvent <= n; {
+		ev := (*InotifyEvent)(unsafe.Pointer(&w.buf[off]))
+		name := w.buf[off+SizeofInotifyEvent : off+SizeofInotifyEvent+int(ev.Len)]
+		off += SizeofInotifyEvent + int(ev.Len)
+		w.decode(ev, strings.TrimRight(string(name), "\x00"))
+	}
+	events := w.events[:0]
+	for i, e := range w.events {
+		m := w.meta[i]
+		if e.Mask&(IN_MOVE|IN_ISDIR) == IN_MOVED_FROM|IN_ISDIR && m.recursive {
+			// Moved out of the watched directories.
+			w.removeTree(e.Path)
+		}
+		if e.Mask&m.want != 0 {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+// decode appends the event ev about the file name, if any, to w.events,
+// and updates the watches of w.
+func (w *Watcher) decode(ev *InotifyEvent, name string) {
+	wt := w.watches[int(ev.Wd)]
+	if wt == nil {
+		// Overflow, or an event of a watch removed already.
+		if ev.Mask&IN_Q_OVERFLOW != 0 {
+			w.events = append(w.events, WatchEvent{Mask: IN_Q_OVERFLOW})
+			w.meta = append(w.meta, watchEventMeta{want: IN_Q_OVERFLOW})
+		}
+		return
+	}
+	p := wt.path
+	if name != "" {
+		p = path.Join(p, name)
+	}
+	m := watchEventMeta{want: wt.mask | IN_Q_OVERFLOW | IN_UNMOUNT, recursive: wt.recursive}
+	if !wt.sub {
+		m.want |= IN_IGNORED
+	}
+	switch {
+	case ev.Mask&IN_IGNORED != 0:
+		w.forget(int(ev.Wd))
+	case ev.Mask&IN_MOVED_FROM != 0:
+		m.cookie = ev.Cookie
+	case ev.Mask&IN_MOVED_TO != 0:
+		if i := w.movedFrom(ev.Cookie); i >= 0 {
+			from := &w.events[i]
+			from.Mask |= IN_MOVED_TO
+			from.OldPath, from.Path = from.Path, p
+			w.meta[i].want |= m.want
+			w.meta[i].cookie = 0
+			if ev.Mask&IN_ISDIR != 0 {
+				w.renameTree(from.OldPath, p)
+				if _, ok := w.wds[p]; wt.recursive && !ok {
+					w.addTree(p, wt.mask, true)
+				}
+			}
+			return
+		}
+	}
+	if wt.recursive && ev.Mask&IN_ISDIR != 0 && ev.Mask&(IN_CREATE|IN_MOVED_TO) != 0 {
+		w.addTree(p, wt.mask, true)
+	}
+	w.events = append(w.events, WatchEvent{Path: p, Mask: ev.Mask})
+	w.meta = append(w.meta, m)
+}
+
+// movedFrom returns the index in w.events of the IN_MOVED_FROM event of
+// cookie, or -1.
+func (w *Watcher) movedFrom(cookie uint32) int {
+	for i := len(w.meta) - 1; i >= 0; i-- {
+		if w.meta[i].cookie == cookie && cookie != 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// Close closes the inotify file descriptor of w, which removes its
+// watches.
+func (w *Watcher) Close() error {
+	clear(w.watches)
+	clear(w.wds)
+	return Close(w.fd)
+}
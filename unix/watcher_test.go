@@ -0,0 +1,127 @@
+//go:build darwin || linux
+
+package unix_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// readWatcher waits for the events of w and returns them, without the
+// directory flag.
+func readWatcher(t *testing.T, p *unix.Poller, w *unix.Watcher) []unix.WatchEvent {
+	t.Helper()
+	var all []unix.WatchEvent
+	for {
+		events, err := w.Read()
+		if err == unix.EAGAIN {
+			if len(all) > 0 {
+				return all
+			}
+			if events, err := p.Wait(1000); err != nil || len(events) == 0 {
+				t.Fatalf("Wait = %+v, %v; want the watcher readable", events, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range events {
+			e.Mask &^= unix.WatchIsDir
+			all = append(all, e)
+		}
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "a", "b"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	w, err := unix.NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	p, err := unix.NewPoller()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if err := p.Add(w.Fd(), unix.PollerRead); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Read(); err != unix.EAGAIN {
+		t.Fatalf("Read with no events: got %v, want EAGAIN", err)
+	}
+	if err := w.AddRecursive(dir, unix.WatchCreate|unix.WatchDelete|unix.WatchMovedFrom|unix.WatchMovedTo); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(step string, want ...unix.WatchEvent) {
+		t.Helper()
+		if got := readWatcher(t, p, w); !slices.Equal(got, want) {
+			t.Errorf("%s: events = %+v, want %+v", step, got, want)
+		}
+	}
+	path := func(elem ...string) string {
+		return filepath.Join(append([]string{dir}, elem...)...)
+	}
+
+	// Existing subdirectories are watched.
+	os.WriteFile(path("a", "b", "f"), nil, 0o644)
+	check("create in a/b", unix.WatchEvent{Path: path("a", "b", "f"), Mask: unix.WatchCreate})
+
+	// New subdirectories are watched once reported.
+	os.Mkdir(path("a", "c"), 0o755)
+	check("mkdir a/c", unix.WatchEvent{Path: path("a", "c"), Mask: unix.WatchCreate})
+	os.WriteFile(path("a", "c", "g"), nil, 0o644)
+	check("create in a/c", unix.WatchEvent{Path: path("a", "c", "g"), Mask: unix.WatchCreate})
+
+	// Renames are paired, and renamed directories keep being watched
+	// under their new path.
+	os.Rename(path("a", "b", "f"), path("a", "c", "f"))
+	check("rename a/b/f", unix.WatchEvent{Path: path("a", "c", "f"), OldPath: path("a", "b", "f"), Mask: unix.WatchMovedFrom | unix.WatchMovedTo})
+	os.Rename(path("a", "c"), path("d"))
+	check("rename a/c", unix.WatchEvent{Path: path("d"), OldPath: path("a", "c"), Mask: unix.WatchMovedFrom | unix.WatchMovedTo})
+	os.WriteFile(path("d", "h"), nil, 0o644)
+	check("create in d", unix.WatchEvent{Path: path("d", "h"), Mask: unix.WatchCreate})
+
+	// Directories moved out are no longer watched.
+	out := t.TempDir()
+	os.Rename(path("d"), filepath.Join(out, "d"))
+	movedOut := unix.WatchEvent{Path: path("d"), Mask: unix.WatchMovedFrom}
+	if runtime.GOOS == "darwin" {
+		movedOut.Mask = unix.WatchDelete
+	}
+	check("move d out", movedOut)
+	os.WriteFile(filepath.Join(out, "d", "i"), nil, 0o644)
+	os.WriteFile(path("j"), nil, 0o644)
+	check("write out of the tree", unix.WatchEvent{Path: path("j"), Mask: unix.WatchCreate})
+
+	if err := w.Remove(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Remove(dir); err != unix.ENOENT {
+		t.Errorf("second Remove: got %v, want ENOENT", err)
+	}
+	os.WriteFile(path("a", "b", "k"), nil, 0o644)
+	if events, err := p.Wait(50); err != nil {
+		t.Fatal(err)
+	} else if len(events) > 0 {
+		if events, err := w.Read(); err != nil || len(events) > 0 {
+			t.Errorf("Read after Remove = %+v, %v; want no events", events, err)
+		}
+	}
+
+	if err := w.Add(path("j"), unix.WatchModify); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(path("j"), []byte("x"), 0o644)
+	check("write j", unix.WatchEvent{Path: path("j"), Mask: unix.WatchModify})
+}
@@ -0,0 +1,113 @@
+package windows
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var (
+	procPeekConsoleInputW = modkernel32.NewProc("PeekConsoleInputW")
+	procReadConsoleInputW = modkernel32.NewProc("ReadConsoleInputW")
+)
+
+// InputRecord is an INPUT_RECORD, an event of the input of a console. Its
+// Event union is decoded by the methods of the event types, such as
+// KeyEvent for KEY_EVENT.
+type InputRecord struct {
+	EventType uint16
+	_         uint16
+	Event     [4]uint32
+}
+
+// KeyEventRecord is a KEY_EVENT_RECORD.
+type KeyEventRecord struct {
+	KeyDown         int32
+	RepeatCount     uint16
+	VirtualKeyCode  uint16
+	VirtualScanCode uint16
+	UnicodeChar     uint16
+	ControlKeyState uint32
+}
+
+// MouseEventRecord is a MOUSE_EVENT_RECORD. For MOUSE_WHEELED and
+// MOUSE_HWHEELED events, the high word of ButtonState is the signed
+// distance the wheel turned.
+type MouseEventRecord struct {
+	MousePosition   Coord
+	ButtonState     uint32
+	ControlKeyState uint32
+	EventFlags      uint32
+}
+
+// WindowBufferSizeRecord is a WINDOW_BUFFER_SIZE_RECORD.
+type WindowBufferSizeRecord struct {
+	Size Coord
+}
+
+// FocusEventRecord is a FOCUS_EVENT_RECORD.
+type FocusEventRecord struct {
+	SetFocus int32
+}
+
+// KeyEvent returns the key event of r, if it is a KEY_EVENT.
+func (r *InputRecord) KeyEvent() (KeyEventRecord, bool) {
+	if r.EventType != KEY_EVENT {
+		return KeyEventRecord{}, false
+	}
+	return *(*KeyEventRecord)(unsafe.Pointer(&r.Event)), true
+}
+
+// MouseEvent returns the mouse event of r, if it is a MOUSE_EVENT. The
+// console only reports mouse events with ENABLE_MOUSE_INPUT, and without
+// ENABLE_QUICK_EDIT_MODE.
+func (r *InputRecord) MouseEvent() (MouseEventRecord, bool) {
+	if r.EventType != MOUSE_EVENT {
+		return MouseEventRecord{}, false
+	}
+	return *(*MouseEventRecord)(unsafe.Pointer(&r.Event)), true
+}
+
+// WindowBufferSizeEvent returns the new size of the screen buffer of r,
+// if it is a WINDOW_BUFFER_SIZE_EVENT. The console only reports them with
+// ENABLE_WINDOW_INPUT.
+func (r *InputRecord) WindowBufferSizeEvent() (WindowBufferSizeRecord, bool) {
+	if r.EventType != WINDOW_BUFFER_SIZE_EVENT {
+		return WindowBufferSizeRecord{}, false
+	}
+	return *(*WindowBufferSizeRecord)(unsafe.Pointer(&r.Event)), true
+}
+
+// FocusEvent returns the focus event of r, if it is a FOCUS_EVENT.
+func (r *InputRecord) FocusEvent() (FocusEventRecord, bool) {
+	if r.EventType != FOCUS_EVENT {
+		return FocusEventRecord{}, false
+	}
+	return *(*FocusEventRecord)(unsafe.Pointer(&r.Event)), true
+}
+
+// ReadConsoleInput reads events of the input of console into records,
+// waiting for one if there are none, and removes them from the input. It
+// sets read to the number of events read.
+func ReadConsoleInput(console Handle, records []InputRecord, read *uint32) error {
+	return consoleInput(procReadConsoleInputW, console, records, read)
+}
+
+// PeekConsoleInput reads events of the input of console into records
+// without removing them from the input, and without waiting. It sets read
+// to the number of events read. The number of events pending is given by
+// GetNumberOfConsoleInputEvents.
+func PeekConsoleInput(console Handle, records []InputRecord, read *uint32) error {
+	return consoleInput(procPeekConsoleInputW, console, records, read)
+}
+
+func consoleInput(proc *LazyProc, console Handle, records []InputRecord, read *uint32) error {
+	var p *InputRecord
+	if len(records) > 0 {
+		p = &records[0]
+	}
+	r1, _, e1 := syscall.SyscallN(proc.Addr(), uintptr(console), uintptr(unsafe.Pointer(p)), uintptr(len(records)), uintptr(unsafe.Pointer(read)))
+	if r1 == 0 {
+		return errnoErr(e1)
+	}
+	return nil
+}
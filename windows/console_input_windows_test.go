@@ -0,0 +1,27 @@
+package windows_test
+
+import (
+	"testing"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+func TestInputRecord(t *testing.T) {
+	if size := unsafe.Sizeof(windows.InputRecord{}); size != 20 {
+		t.Errorf("InputRecord is %d bytes, want the 20 of an INPUT_RECORD", size)
+	}
+	r := windows.InputRecord{EventType: windows.MOUSE_EVENT}
+	*(*windows.MouseEventRecord)(unsafe.Pointer(&r.Event)) = windows.MouseEventRecord{
+		MousePosition: windows.Coord{X: 3, Y: 4},
+		ButtonState:   windows.FROM_LEFT_1ST_BUTTON_PRESSED,
+		EventFlags:    windows.DOUBLE_CLICK,
+	}
+	if _, ok := r.KeyEvent(); ok {
+		t.Errorf("KeyEvent of a MOUSE_EVENT succeeded")
+	}
+	m, ok := r.MouseEvent()
+	if !ok || m.MousePosition != (windows.Coord{X: 3, Y: 4}) || m.ButtonState != windows.FROM_LEFT_1ST_BUTTON_PRESSED || m.EventFlags != windows.DOUBLE_CLICK {
+		t.Errorf("MouseEvent = %+v, %v", m, ok)
+	}
+}
@@ -0,0 +1,104 @@
+package windows
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var (
+	procCreateConsoleScreenBuffer    = modkernel32.NewProc("CreateConsoleScreenBuffer")
+	procFillConsoleOutputAttribute   = modkernel32.NewProc("FillConsoleOutputAttribute")
+	procFillConsoleOutputCharacterW  = modkernel32.NewProc("FillConsoleOutputCharacterW")
+	procSetConsoleActiveScreenBuffer = modkernel32.NewProc("SetConsoleActiveScreenBuffer")
+	procWriteConsoleOutputW          = modkernel32.NewProc("WriteConsoleOutputW")
+)
+
+// The flag of CreateConsoleScreenBuffer.
+const CONSOLE_TEXTMODE_BUFFER = 0x1
+
+// Character attributes, the colors and styles of the cells of console
+// screen buffers. See
+// https://learn.microsoft.com/en-us/windows/console/console-screen-buffers#character-attributes
+const (
+	FOREGROUND_BLUE          = 0x0001
+	FOREGROUND_GREEN         = 0x0002
+	FOREGROUND_RED           = 0x0004
+	FOREGROUND_INTENSITY     = 0x0008
+	BACKGROUND_BLUE          = 0x0010
+	BACKGROUND_GREEN         = 0x0020
+	BACKGROUND_RED           = 0x0040
+	BACKGROUND_INTENSITY     = 0x0080
+	COMMON_LVB_REVERSE_VIDEO = 0x4000
+	COMMON_LVB_UNDERSCORE    = 0x8000
+)
+
+// CharInfo is a CHAR_INFO, a cell of a console screen buffer: a UTF-16
+// character and its attributes.
+type CharInfo struct {
+	Char       uint16
+	Attributes uint16
+}
+
+// coordArg returns c as passed by value to the console functions.
+func coordArg(c Coord) uintptr {
+	return uintptr(*(*uint32)(unsafe.Pointer(&c)))
+}
+
+// CreateConsoleScreenBuffer creates a console screen buffer, to draw a
+// frame in while another is displayed and then display it with
+// SetConsoleActiveScreenBuffer. access is a combination of GENERIC_READ and
+// GENERIC_WRITE, and shareMode of FILE_SHARE_READ and FILE_SHARE_WRITE.
+func CreateConsoleScreenBuffer(access, shareMode uint32, sa *SecurityAttributes) (Handle, error) {
+	r0, _, e1 := syscall.SyscallN(procCreateConsoleScreenBuffer.Addr(), uintptr(access), uintptr(shareMode), uintptr(unsafe.Pointer(sa)), CONSOLE_TEXTMODE_BUFFER, 0)
+	if Handle(r0) == InvalidHandle {
+		return InvalidHandle, errnoErr(e1)
+	}
+	return Handle(r0), nil
+}
+
+// SetConsoleActiveScreenBuffer displays the screen buffer console.
+func SetConsoleActiveScreenBuffer(console Handle) error {
+	r1, _, e1 := syscall.SyscallN(procSetConsoleActiveScreenBuffer.Addr(), uintptr(console))
+	if r1 == 0 {
+		return errnoErr(e1)
+	}
+	return nil
+}
+
+// WriteConsoleOutput copies the cells of buf, a rectangle of bufSize cells
+// stored row by row, from bufCoord to the region of the screen buffer
+// console, which it sets to the region actually written, clipped to the
+// screen buffer. It fails with ERROR_INVALID_PARAMETER if buf holds fewer
+// than bufSize cells.
+func WriteConsoleOutput(console Handle, buf []CharInfo, bufSize, bufCoord Coord, region *SmallRect) error {
+	if bufSize.X < 0 || bufSize.Y < 0 || len(buf) < int(bufSize.X)*int(bufSize.Y) || len(buf) == 0 {
+		return ERROR_INVALID_PARAMETER
+	}
+	r1, _, e1 := syscall.SyscallN(procWriteConsoleOutputW.Addr(), uintptr(console), uintptr(unsafe.Pointer(&buf[0])), coordArg(bufSize), coordArg(bufCoord), uintptr(unsafe.Pointer(region)))
+	if r1 == 0 {
+		return errnoErr(e1)
+	}
+	return nil
+}
+
+// FillConsoleOutputCharacter writes the UTF-16 character char to length
+// cells of the screen buffer console from coord, wrapping to the next
+// rows, and sets written to the number of cells written.
+func FillConsoleOutputCharacter(console Handle, char uint16, length uint32, coord Coord, written *uint32) error {
+	r1, _, e1 := syscall.SyscallN(procFillConsoleOutputCharacterW.Addr(), uintptr(console), uintptr(char), uintptr(length), coordArg(coord), uintptr(unsafe.Pointer(written)))
+	if r1 == 0 {
+		return errnoErr(e1)
+	}
+	return nil
+}
+
+// FillConsoleOutputAttribute sets the attributes of length cells of the
+// screen buffer console from coord, wrapping to the next rows, and sets
+// written to the number of cells changed.
+func FillConsoleOutputAttribute(console Handle, attribute uint16, length uint32, coord Coord, written *uint32) error {
+	r1, _, e1 := syscall.SyscallN(procFillConsoleOutputAttribute.Addr(), uintptr(console), uintptr(attribute), uintptr(length), coordArg(coord), uintptr(unsafe.Pointer(written)))
+	if r1 == 0 {
+		return errnoErr(e1)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package windows_test
+
+import (
+	"testing"
+
+	"golang.org/x/sys/windows"
+)
+
+func TestConsoleScreenBuffer(t *testing.T) {
+	var region windows.SmallRect
+	if err := windows.WriteConsoleOutput(0, make([]windows.CharInfo, 3), windows.Coord{X: 2, Y: 2}, windows.Coord{}, &region); err != windows.ERROR_INVALID_PARAMETER {
+		t.Errorf("WriteConsoleOutput with a short buffer: got %v, want ERROR_INVALID_PARAMETER", err)
+	}
+
+	buf, err := windows.CreateConsoleScreenBuffer(windows.GENERIC_READ|windows.GENERIC_WRITE, 0, nil)
+	if err != nil {
+		t.Skipf("CreateConsoleScreenBuffer: %v", err)
+	}
+	defer windows.CloseHandle(buf)
+	var written uint32
+	if err := windows.FillConsoleOutputCharacter(buf, '.', 10, windows.Coord{}, &written); err != nil || written != 10 {
+		t.Errorf("FillConsoleOutputCharacter = %d, %v; want 10 cells", written, err)
+	}
+	if err := windows.FillConsoleOutputAttribute(buf, windows.FOREGROUND_GREEN, 10, windows.Coord{}, &written); err != nil || written != 10 {
+		t.Errorf("FillConsoleOutputAttribute = %d, %v; want 10 cells", written, err)
+	}
+	cells := []windows.CharInfo{{'o', windows.FOREGROUND_RED}, {'k', windows.FOREGROUND_RED}}
+	region = windows.SmallRect{Left: 1, Top: 1, Right: 2, Bottom: 1}
+	if err := windows.WriteConsoleOutput(buf, cells, windows.Coord{X: 2, Y: 1}, windows.Coord{}, &region); err != nil {
+		t.Fatal(err)
+	}
+	if want := (windows.SmallRect{Left: 1, Top: 1, Right: 2, Bottom: 1}); region != want {
+		t.Errorf("region written = %+v, want %+v", region, want)
+	}
+	if err := windows.SetConsoleCursorPosition(buf, windows.Coord{X: 3, Y: 1}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,19 @@
+package windows
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// UpdatePseudoConsole sets the PROC_THREAD_ATTRIBUTE_PSEUDOCONSOLE
+// attribute of al to the pseudo console console, made with
+// CreatePseudoConsole, so that the process created with al, with
+// EXTENDED_STARTUPINFO_PRESENT, runs on it. The value of this attribute is
+// the handle itself rather than a pointer, which Update cannot take.
+func (al *ProcThreadAttributeListContainer) UpdatePseudoConsole(console Handle) error {
+	r1, _, e1 := syscall.SyscallN(procUpdateProcThreadAttribute.Addr(), uintptr(unsafe.Pointer(al.data)), 0, PROC_THREAD_ATTRIBUTE_PSEUDOCONSOLE, uintptr(console), unsafe.Sizeof(console), 0, 0)
+	if r1 == 0 {
+		return errnoErr(e1)
+	}
+	return nil
+}
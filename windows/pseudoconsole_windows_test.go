@@ -0,0 +1,39 @@
+package windows_test
+
+import (
+	"testing"
+
+	"golang.org/x/sys/windows"
+)
+
+func TestPseudoConsole(t *testing.T) {
+	var inR, inW, outR, outW windows.Handle
+	if err := windows.CreatePipe(&inR, &inW, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer windows.CloseHandle(inW)
+	if err := windows.CreatePipe(&outR, &outW, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer windows.CloseHandle(outR)
+	var console windows.Handle
+	err := windows.CreatePseudoConsole(windows.Coord{X: 80, Y: 24}, inR, outW, 0, &console)
+	windows.CloseHandle(inR)
+	windows.CloseHandle(outW)
+	if err != nil {
+		t.Skipf("CreatePseudoConsole: %v", err)
+	}
+	defer windows.ClosePseudoConsole(console)
+	if err := windows.ResizePseudoConsole(console, windows.Coord{X: 100, Y: 30}); err != nil {
+		t.Errorf("ResizePseudoConsole: %v", err)
+	}
+
+	attrs, err := windows.NewProcThreadAttributeList(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer attrs.Delete()
+	if err := attrs.UpdatePseudoConsole(console); err != nil {
+		t.Errorf("UpdatePseudoConsole: %v", err)
+	}
+}
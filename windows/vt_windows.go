@@ -0,0 +1,49 @@
+package windows
+
+// EnableVirtualTerminal makes the console output h process virtual
+// terminal sequences, such as the ANSI sequences of colors and cursor
+// movements, with ENABLE_VIRTUAL_TERMINAL_PROCESSING, and wrap lines and
+// feed them like a unix terminal, with DISABLE_NEWLINE_AUTO_RETURN if the
+// console supports it. It returns a function putting h back in its
+// previous mode.
+//
+// EnableVirtualTerminal fails if h is not a console, or if the console
+// does not support virtual terminal sequences, as before Windows 10.
+func EnableVirtualTerminal(h Handle) (restore func() error, err error) {
+	var mode uint32
+	if err := GetConsoleMode(h, &mode); err != nil {
+		return nil, err
+	}
+	err = SetConsoleMode(h, mode|ENABLE_VIRTUAL_TERMINAL_PROCESSING|DISABLE_NEWLINE_AUTO_RETURN)
+	if err != nil {
+		// Consoles that predate DISABLE_NEWLINE_AUTO_RETURN reject it.
+		err = SetConsoleMode(h, mode|ENABLE_VIRTUAL_TERMINAL_PROCESSING)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return func() error { return SetConsoleMode(h, mode) }, nil
+}
+
+// ProbeVTSupport reports whether the console of the standard output
+// processes virtual terminal sequences, or can be made to with
+// EnableVirtualTerminal. The mode of the console is left unchanged. It
+// reports false if the standard output is not a console.
+func ProbeVTSupport() bool {
+	h, err := GetStdHandle(STD_OUTPUT_HANDLE)
+	if err != nil {
+		return false
+	}
+	var mode uint32
+	if GetConsoleMode(h, &mode) != nil {
+		return false
+	}
+	if mode&ENABLE_VIRTUAL_TERMINAL_PROCESSING != 0 {
+		return true
+	}
+	if SetConsoleMode(h, mode|ENABLE_VIRTUAL_TERMINAL_PROCESSING) != nil {
+		return false
+	}
+	SetConsoleMode(h, mode)
+	return true
+}
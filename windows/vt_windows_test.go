@@ -0,0 +1,49 @@
+package windows_test
+
+import (
+	"os"
+	"testing"
+
+	"golang.org/x/sys/windows"
+)
+
+func TestEnableVirtualTerminal(t *testing.T) {
+	// A file is not a console.
+	f, err := os.CreateTemp(t.TempDir(), "vt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := windows.EnableVirtualTerminal(windows.Handle(f.Fd())); err == nil {
+		t.Errorf("EnableVirtualTerminal on a file succeeded")
+	}
+
+	out, err := windows.GetStdHandle(windows.STD_OUTPUT_HANDLE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mode uint32
+	if windows.GetConsoleMode(out, &mode) != nil {
+		t.Skip("the standard output is not a console")
+	}
+	if !windows.ProbeVTSupport() {
+		t.Skip("the console does not support virtual terminal sequences")
+	}
+	restore, err := windows.EnableVirtualTerminal(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vtMode uint32
+	windows.GetConsoleMode(out, &vtMode)
+	if vtMode&windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING == 0 {
+		t.Errorf("mode = %#x, want ENABLE_VIRTUAL_TERMINAL_PROCESSING", vtMode)
+	}
+	if err := restore(); err != nil {
+		t.Fatal(err)
+	}
+	var restored uint32
+	windows.GetConsoleMode(out, &restored)
+	if restored != mode {
+		t.Errorf("restored mode = %#x, want %#x", restored, mode)
+	}
+}